@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// RunCommand runs a one-off command given on the command line, e.g.
+//
+//	ewallet-transaction reconcile -start 2024-01-01 -end 2024-01-02
+func RunCommand(name string, args []string) {
+	switch name {
+	case "reconcile":
+		runReconcile(args)
+	default:
+		log.Fatalf("unknown command %q", name)
+	}
+}
+
+// runReconcile prints the reconciliation report of the window as JSON and
+// exits with status 1 when it has discrepancies.
+func runReconcile(args []string) {
+	var (
+		start, end time.Time
+		err        error
+	)
+
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	startFlag := fs.String("start", "", "start of the window, 2006-01-02 or RFC 3339")
+	endFlag := fs.String("end", "", "end of the window (exclusive), defaults to one day after start")
+	_ = fs.Parse(args)
+
+	start, err = parseCommandTime(*startFlag)
+	if err != nil {
+		log.Fatal("invalid -start: ", err)
+	}
+
+	end = start.AddDate(0, 0, 1)
+	if *endFlag != "" {
+		end, err = parseCommandTime(*endFlag)
+		if err != nil {
+			log.Fatal("invalid -end: ", err)
+		}
+	}
+
+	d := dependencyInject()
+
+	report, err := d.ReconciliationService.Reconcile(context.Background(), start, end)
+	if err != nil {
+		log.Fatal("failed to reconcile: ", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal("failed to write report: ", err)
+	}
+
+	if report.HasDiscrepancies() {
+		os.Exit(1)
+	}
+}
+
+func parseCommandTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("value is required")
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"ewallet-transaction/external/proto/transaction"
 	"ewallet-transaction/helpers"
 	"log"
 	"net"
@@ -9,12 +10,19 @@ import (
 )
 
 func ServeGRPC() {
+	d := dependencyInject()
+
 	lis, err := net.Listen("tcp", ":"+helpers.GetEnv("GRPC_PORT", "7000"))
 	if err != nil {
 		log.Fatal("failed to listen grpc port: ", err)
 	}
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(d.ValidateTokenUnary),
+		grpc.StreamInterceptor(d.ValidateTokenStream),
+	)
+
+	transaction.RegisterTransactionServiceServer(s, d.TransactionGrpc)
 
 	if err := s.Serve(lis); err != nil {
 		log.Fatal("failed to serve grpc port: ", err)
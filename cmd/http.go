@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"ewallet-transaction/constants"
 	"ewallet-transaction/external"
+	"ewallet-transaction/external/proto/transaction"
 	"ewallet-transaction/helpers"
 	"ewallet-transaction/internal/api"
+	"ewallet-transaction/internal/events"
 	"ewallet-transaction/internal/interfaces"
 	"ewallet-transaction/internal/repository"
 	"ewallet-transaction/internal/services"
 	"log"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,11 +27,43 @@ func ServeHTTP() {
 	r.GET("/health", d.HealthcheckApi.HealthcheckHandlerHttp)
 
 	transactionV1 := r.Group("/transaction/v1")
-	transactionV1.POST("/create", d.ValidateToken, d.TransactionApi.CreateTransaction)
-	transactionV1.POST("/refund", d.ValidateToken, d.TransactionApi.RefundTransaction)
+	transactionV1.POST("/create", d.ValidateToken, d.Idempotency, d.TransactionApi.CreateTransaction)
+	transactionV1.POST("/refund", d.ValidateToken, d.Idempotency, d.TransactionApi.RefundTransaction)
 	transactionV1.PUT("/update-status/:reference", d.ValidateToken, d.TransactionApi.UpdateStatusTransaction)
 	transactionV1.GET("/:reference", d.ValidateToken, d.TransactionApi.GetTransactionDetail)
+	transactionV1.GET("/:reference/history", d.ValidateToken, d.TransactionApi.GetTransactionStatusHistory)
 	transactionV1.GET("/", d.ValidateToken, d.TransactionApi.GetTransaction)
+	transactionV1.GET("/export", d.ValidateToken, d.TransactionApi.ExportTransactions)
+	transactionV1.POST("/batch", d.ValidateToken, d.Idempotency, d.TransactionBatchApi.CreateBatch)
+	transactionV1.GET("/batch/:batch_id", d.ValidateToken, d.TransactionBatchApi.GetBatch)
+
+	webhookV1 := transactionV1.Group("/webhooks", d.ValidateToken)
+	webhookV1.POST("", d.WebhookApi.CreateWebhook)
+	webhookV1.GET("", d.WebhookApi.GetWebhooks)
+	webhookV1.DELETE("/:id", d.WebhookApi.DeleteWebhook)
+	webhookV1.GET("/:id/deliveries", d.WebhookApi.GetWebhookDeliveries)
+
+	scheduleV1 := transactionV1.Group("/schedules", d.ValidateToken)
+	scheduleV1.POST("", d.ScheduledTransactionApi.CreateSchedule)
+	scheduleV1.GET("", d.ScheduledTransactionApi.GetSchedules)
+	scheduleV1.GET("/:id", d.ScheduledTransactionApi.GetSchedule)
+	scheduleV1.GET("/:id/runs", d.ScheduledTransactionApi.GetScheduleRuns)
+	scheduleV1.POST("/:id/pause", d.ScheduledTransactionApi.PauseSchedule)
+	scheduleV1.POST("/:id/resume", d.ScheduledTransactionApi.ResumeSchedule)
+	scheduleV1.POST("/:id/cancel", d.ScheduledTransactionApi.CancelSchedule)
+
+	reviewV1 := transactionV1.Group("/reviews", d.ValidateToken, d.RequireRole(constants.RoleOperator))
+	reviewV1.GET("", d.FraudApi.GetPendingReviews)
+	reviewV1.GET("/:reference", d.FraudApi.GetFraudEvaluations)
+	reviewV1.POST("/:reference/approve", d.FraudApi.ApproveTransaction)
+	reviewV1.POST("/:reference/reject", d.FraudApi.RejectTransaction)
+
+	adminV1 := transactionV1.Group("/admin", d.ValidateToken, d.RequireRole(constants.RoleAdmin))
+	adminV1.GET("/notifications/dead-letters", d.NotificationApi.GetDeadLetters)
+	adminV1.POST("/notifications/dead-letters/:id/redrive", d.NotificationApi.RedriveDeadLetter)
+	adminV1.GET("/limits/users/:user_id", d.TransactionLimitApi.GetUserLimits)
+	adminV1.PUT("/limits/users/:user_id/:transaction_type", d.TransactionLimitApi.SetUserLimit)
+	adminV1.DELETE("/limits/users/:user_id/:transaction_type", d.TransactionLimitApi.DeleteUserLimit)
 
 	err := r.Run(":" + helpers.GetEnv("PORT", "8080"))
 	if err != nil {
@@ -33,12 +72,58 @@ func ServeHTTP() {
 }
 
 type Dependency struct {
-	HealthcheckApi interfaces.IHealthcheckAPI
-	TransactionApi interfaces.ITransactionAPI
-	External       interfaces.IExternal
+	HealthcheckApi     interfaces.IHealthcheckAPI
+	TransactionApi     interfaces.ITransactionAPI
+	TransactionService interfaces.ITransactionService
+	TransactionGrpc    transaction.TransactionServiceServer
+	External           interfaces.IExternal
+
+	IdempotencyService interfaces.IIdempotencyService
+
+	NotificationApi     interfaces.INotificationAPI
+	NotificationService interfaces.INotificationService
+
+	ReconciliationService interfaces.IReconciliationService
+
+	WebhookApi     interfaces.IWebhookAPI
+	WebhookService interfaces.IWebhookService
+
+	ScheduledTransactionApi     interfaces.IScheduledTransactionAPI
+	ScheduledTransactionService interfaces.IScheduledTransactionService
+
+	TransactionBatchApi     interfaces.ITransactionBatchAPI
+	TransactionBatchService interfaces.ITransactionBatchService
+
+	TransactionLimitApi interfaces.ITransactionLimitAPI
+
+	FraudApi     interfaces.IFraudAPI
+	FraudService interfaces.IFraudService
+
+	EventBus          *events.InProcessPublisher
+	EventRelayService interfaces.IEventRelayService
 }
 
+var (
+	dependency     Dependency
+	dependencyOnce sync.Once
+)
+
+// dependencyInject builds the dependencies once, so the HTTP and gRPC
+// servers and the workers share the reference generator and the event bus.
 func dependencyInject() Dependency {
+	dependencyOnce.Do(func() {
+		dependency = newDependency()
+	})
+	return dependency
+}
+
+func newDependency() Dependency {
+	// calls on behalf of another user and recovery go through the system
+	// token, without it they would be sent unauthenticated
+	if helpers.GetEnv("WALLET_SYSTEM_TOKEN", "") == "" {
+		log.Fatal("WALLET_SYSTEM_TOKEN is required")
+	}
+
 	healthcheckSvc := &services.Healthcheck{}
 	healthcheckAPI := &api.Healthcheck{
 		HealthcheckServices: healthcheckSvc,
@@ -50,17 +135,225 @@ func dependencyInject() Dependency {
 		DB: helpers.DB,
 	}
 
-	transactionSvc := &services.TransactionService{
+	balanceOperationRepo := &repository.BalanceOperationRepo{
+		DB: helpers.DB,
+	}
+
+	notificationOutboxRepo := &repository.NotificationOutboxRepo{
+		DB: helpers.DB,
+	}
+	notificationSvc := &services.NotificationService{
+		NotificationOutboxRepo: notificationOutboxRepo,
+		External:               external,
+	}
+	notificationAPI := &api.NotificationAPI{
+		NotificationService: notificationSvc,
+	}
+
+	referenceGenerator, err := helpers.NewReferenceGenerator()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactionStatusHistoryRepo := &repository.TransactionStatusHistoryRepo{
+		DB: helpers.DB,
+	}
+
+	webhookRepo := &repository.WebhookRepo{
+		DB: helpers.DB,
+	}
+	webhookSvc := &services.WebhookService{
+		WebhookRepo: webhookRepo,
+		External:    external,
+	}
+	webhookAPI := &api.WebhookAPI{
+		WebhookService: webhookSvc,
+	}
+
+	eventOutboxRepo := &repository.EventOutboxRepo{
+		DB: helpers.DB,
+	}
+
+	// events are always written to the outbox in the transaction of the
+	// change, the relay hands them to EVENT_PUBLISHER once committed
+	eventPublisher := &events.OutboxPublisher{
+		EventOutboxRepo: eventOutboxRepo,
+	}
+	eventBus := events.NewInProcessPublisher()
+
+	var eventRelaySvc interfaces.IEventRelayService
+	switch backend := helpers.GetEnv("EVENT_PUBLISHER", "http"); backend {
+	case "http":
+		url := helpers.GetEnv("EVENT_RELAY_URL", "")
+		if url == "" {
+			helpers.Logger.Warn("EVENT_RELAY_URL is empty, events are kept in the outbox until it is set")
+			break
+		}
+		eventRelaySvc = &services.EventRelayService{
+			EventOutboxRepo: eventOutboxRepo,
+			Publisher: &events.HTTPPublisher{
+				URL:   url,
+				Token: helpers.GetEnv("EVENT_RELAY_TOKEN", ""),
+			},
+		}
+	case "inprocess":
+		eventRelaySvc = &services.EventRelayService{
+			EventOutboxRepo: eventOutboxRepo,
+			Publisher:       eventBus,
+		}
+	default:
+		log.Fatalf("unknown EVENT_PUBLISHER %q", backend)
+	}
+
+	stateMachines, err := services.LoadStateMachines(helpers.GetEnv("TRANSACTION_STATE_MACHINE_FILE", ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactionLimits, err := services.LoadTransactionLimits(helpers.GetEnv("TRANSACTION_LIMIT_FILE", ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	feeRules, err := services.LoadFeeRules(helpers.GetEnv("FEE_RULES_FILE", ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	batchSyncMaxItems, err := strconv.Atoi(helpers.GetEnv("BATCH_SYNC_MAX_ITEMS", "100"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactionLimitLocation, err := time.LoadLocation(helpers.GetEnv("TRANSACTION_LIMIT_TIMEZONE", "Local"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactionLimitRepo := &repository.TransactionLimitRepo{
+		DB: helpers.DB,
+	}
+	transactionLimitSvc := &services.TransactionLimitService{
+		TransactionLimitRepo: transactionLimitRepo,
+		TransactionRepo:      transactionRepo,
+		Limits:               transactionLimits,
+		Location:             transactionLimitLocation,
+	}
+	transactionLimitAPI := &api.TransactionLimitAPI{
+		TransactionLimitService: transactionLimitSvc,
+	}
+
+	fraudRepo := &repository.FraudRepo{
+		DB: helpers.DB,
+	}
+	fraudSvc := &services.FraudService{
+		FraudRepo:       fraudRepo,
 		TransactionRepo: transactionRepo,
-		External:        external,
+		RulesFile:       helpers.GetEnv("FRAUD_RULES_FILE", ""),
+	}
+	err = fraudSvc.ReloadRules(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactionSvc := &services.TransactionService{
+		TransactionRepo:        transactionRepo,
+		BalanceOperationRepo:   balanceOperationRepo,
+		NotificationOutboxRepo: notificationOutboxRepo,
+		External:               external,
+		ReferenceGenerator:     referenceGenerator,
+
+		TransactionStatusHistoryRepo: transactionStatusHistoryRepo,
+		WebhookRepo:                  webhookRepo,
+		EventPublisher:               eventPublisher,
+		StateMachines:                stateMachines,
+		TransactionLimitService:      transactionLimitSvc,
+		FraudService:                 fraudSvc,
+		FraudRepo:                    fraudRepo,
+		FeeRules:                     feeRules,
 	}
 	transactionAPI := &api.TransactionAPI{
 		TransactionService: transactionSvc,
 	}
+	idempotencyRepo := &repository.IdempotencyRepo{
+		DB: helpers.DB,
+	}
+	idempotencySvc := &services.IdempotencyService{
+		IdempotencyRepo: idempotencyRepo,
+	}
+
+	reconciliationSvc := &services.ReconciliationService{
+		TransactionRepo:              transactionRepo,
+		TransactionStatusHistoryRepo: transactionStatusHistoryRepo,
+		External:                     external,
+		StateMachines:                stateMachines,
+	}
+
+	scheduledTransactionRepo := &repository.ScheduledTransactionRepo{
+		DB: helpers.DB,
+	}
+	scheduledTransactionSvc := &services.ScheduledTransactionService{
+		ScheduledTransactionRepo: scheduledTransactionRepo,
+		TransactionService:       transactionSvc,
+	}
+	scheduledTransactionAPI := &api.ScheduledTransactionAPI{
+		ScheduledTransactionService: scheduledTransactionSvc,
+	}
+
+	transactionBatchRepo := &repository.TransactionBatchRepo{
+		DB: helpers.DB,
+	}
+	transactionBatchSvc := &services.TransactionBatchService{
+		TransactionBatchRepo: transactionBatchRepo,
+		TransactionRepo:      transactionRepo,
+		TransactionService:   transactionSvc,
+		External:             external,
+		ReferenceGenerator:   referenceGenerator,
+		StateMachines:        stateMachines,
+		SyncMaxItems:         batchSyncMaxItems,
+	}
+	transactionBatchAPI := &api.TransactionBatchAPI{
+		TransactionBatchService: transactionBatchSvc,
+	}
+
+	fraudAPI := &api.FraudAPI{
+		FraudService:       fraudSvc,
+		TransactionService: transactionSvc,
+	}
+
+	transactionGrpc := &api.TransactionGrpcAPI{
+		TransactionService: transactionSvc,
+	}
 
 	return Dependency{
-		HealthcheckApi: healthcheckAPI,
-		TransactionApi: transactionAPI,
-		External:       external,
+		HealthcheckApi:     healthcheckAPI,
+		TransactionApi:     transactionAPI,
+		TransactionService: transactionSvc,
+		TransactionGrpc:    transactionGrpc,
+		External:           external,
+
+		IdempotencyService: idempotencySvc,
+
+		NotificationApi:     notificationAPI,
+		NotificationService: notificationSvc,
+
+		ReconciliationService: reconciliationSvc,
+
+		WebhookApi:     webhookAPI,
+		WebhookService: webhookSvc,
+
+		ScheduledTransactionApi:     scheduledTransactionAPI,
+		ScheduledTransactionService: scheduledTransactionSvc,
+
+		TransactionBatchApi:     transactionBatchAPI,
+		TransactionBatchService: transactionBatchSvc,
+
+		TransactionLimitApi: transactionLimitAPI,
+
+		FraudApi:     fraudAPI,
+		FraudService: fraudSvc,
+
+		EventBus:          eventBus,
+		EventRelayService: eventRelaySvc,
 	}
 }
@@ -1,10 +1,22 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"ewallet-transaction/constants"
 	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func (d *Dependency) ValidateToken(c *gin.Context) {
@@ -29,6 +41,163 @@ func (d *Dependency) ValidateToken(c *gin.Context) {
 	tokenData.Token = auth
 
 	c.Set("token", tokenData)
+	c.Request = c.Request.WithContext(models.ContextWithTokenData(c.Request.Context(), tokenData))
 
 	c.Next()
 }
+
+func (d *Dependency) ValidateTokenUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := d.validateTokenGrpc(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (d *Dependency) ValidateTokenStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := d.validateTokenGrpc(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &tokenServerStream{ServerStream: ss, ctx: ctx})
+}
+
+func (d *Dependency) validateTokenGrpc(ctx context.Context) (context.Context, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 || auth[0] == "" {
+		return ctx, status.Error(codes.Unauthenticated, "unauthorized empty")
+	}
+
+	tokenData, err := d.External.ValidateToken(ctx, auth[0])
+	if err != nil {
+		log.Error(err)
+		return ctx, status.Error(codes.Unauthenticated, "unauthorized empty")
+	}
+
+	tokenData.Token = auth[0]
+
+	return models.ContextWithTokenData(ctx, tokenData), nil
+}
+
+// tokenServerStream overrides the stream context so streaming handlers see
+// the token data put there by ValidateTokenStream.
+type tokenServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tokenServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key header and body. It must run after ValidateToken.
+func (d *Dependency) Idempotency(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	idempotencyKey := c.Request.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		c.Next()
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		c.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Error("failed to read request body: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.Sum256([]byte(c.Request.Method + " " + c.FullPath() + "\n" + string(body)))
+	requestHash := hex.EncodeToString(hash[:])
+
+	key, replay, err := d.IdempotencyService.Begin(c.Request.Context(), int(tokenData.UserID), idempotencyKey, requestHash)
+	if err != nil {
+		if errors.Is(err, constants.ErrIdempotencyKeyMismatch) || errors.Is(err, constants.ErrIdempotencyKeyInProgress) {
+			helpers.SendResponseHTTP(c, http.StatusConflict, err.Error(), nil)
+			c.Abort()
+			return
+		}
+		log.Error("failed to begin idempotent request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		c.Abort()
+		return
+	}
+
+	if replay {
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(key.ResponseCode, "application/json; charset=utf-8", []byte(key.ResponseBody))
+		c.Abort()
+		return
+	}
+
+	writer := &bodyCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	stop := d.IdempotencyService.KeepAlive(c.Request.Context(), key)
+	defer stop()
+
+	c.Next()
+
+	err = d.IdempotencyService.Complete(context.WithoutCancel(c.Request.Context()), key, writer.Status(), writer.body.String())
+	if err != nil {
+		log.Error("failed to complete idempotent request: ", err)
+	}
+}
+
+// bodyCaptureWriter keeps a copy of the response body so it can be stored
+// for idempotent replays.
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// RequireRole only lets through users having one of roles. It must run after
+// ValidateToken.
+func (d *Dependency) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+		if !ok {
+			helpers.SendResponseHTTP(c, http.StatusUnauthorized, constants.ErrUnauthorized, nil)
+			c.Abort()
+			return
+		}
+
+		if !tokenData.HasRole(roles...) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, constants.ErrForbidden, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,141 @@
+// Command walletstub is an in-memory stand-in for the wallet service, used to
+// run the transaction service and the reconciliation locally. Point
+// WALLET_HOST at it and keep the default endpoint paths:
+//
+//	go run ./cmd/walletstub -addr :8082
+//
+// Entries can be added directly through the credit and debit endpoints to
+// simulate discrepancies, and the history endpoint lists them.
+package main
+
+import (
+	"ewallet-transaction/external"
+	"ewallet-transaction/internal/models"
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type stub struct {
+	mu       sync.Mutex
+	entries  []external.WalletHistoryEntry
+	seen     map[string]bool
+	balances map[int]models.Money
+}
+
+func main() {
+	addr := flag.String("addr", ":8082", "listen address")
+	flag.Parse()
+
+	s := &stub{
+		seen:     map[string]bool{},
+		balances: map[int]models.Money{},
+	}
+
+	r := gin.Default()
+	r.PUT("/wallet/v1/balance/credit", s.updateBalance("CREDIT"))
+	r.PUT("/wallet/v1/balance/debit", s.updateBalance("DEBIT"))
+	r.GET("/wallet/v1/history", s.history)
+
+	if err := r.Run(*addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// updateBalance accepts every user, like the real wallet it rejects a
+// reference it has already seen with 409 and a debit larger than the balance
+// with 400.
+func (s *stub) updateBalance(walletTransactionType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req external.UpdateBalance
+		if err := c.ShouldBindJSON(&req); err != nil || req.Reference == "" || req.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.seen[req.Reference] {
+			c.JSON(http.StatusConflict, gin.H{"message": "reference already used"})
+			return
+		}
+
+		balance := s.balances[req.UserID]
+		if walletTransactionType == "DEBIT" {
+			if balance < req.Amount {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "insufficient balance"})
+				return
+			}
+			balance -= req.Amount
+		} else {
+			balance += req.Amount
+		}
+
+		s.seen[req.Reference] = true
+		s.balances[req.UserID] = balance
+		s.entries = append(s.entries, external.WalletHistoryEntry{
+			Reference:             req.Reference,
+			Amount:                req.Amount,
+			Currency:              req.Currency,
+			WalletTransactionType: walletTransactionType,
+			UserID:                req.UserID,
+			Date:                  time.Now(),
+		})
+
+		resp := external.UpdateBalanceResponse{Message: "success"}
+		resp.Data.Balance = balance
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
+func (s *stub) history(c *gin.Context) {
+	start, err := time.Parse(time.RFC3339, c.Query("start_time"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid start_time"})
+		return
+	}
+	end, err := time.Parse(time.RFC3339, c.Query("end_time"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid end_time"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if page < 1 || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid page"})
+		return
+	}
+
+	reference := c.Query("reference")
+
+	s.mu.Lock()
+	entries := []external.WalletHistoryEntry{}
+	for _, entry := range s.entries {
+		if reference != "" && entry.Reference != reference {
+			continue
+		}
+		if !entry.Date.Before(start) && entry.Date.Before(end) {
+			entries = append(entries, entry)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+
+	from := min((page-1)*limit, len(entries))
+	to := min(from+limit, len(entries))
+
+	c.JSON(http.StatusOK, external.WalletHistoryResponse{
+		Message: "success",
+		Data:    entries[from:to],
+	})
+}
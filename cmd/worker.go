@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"ewallet-transaction/helpers"
+	"time"
+)
+
+func ServeWorker() {
+	d := dependencyInject()
+
+	go runPeriodically("balance operation recovery", "BALANCE_RECOVERY_INTERVAL", "1m", d.TransactionService.RecoverBalanceOperations)
+	go runPeriodically("transaction expiry", "TRANSACTION_EXPIRY_INTERVAL", "1m", d.TransactionService.ExpirePendingTransactions)
+	go runPeriodically("notification dispatcher", "NOTIFICATION_DISPATCH_INTERVAL", "10s", d.NotificationService.DispatchNotifications)
+	go runPeriodically("webhook dispatcher", "WEBHOOK_DISPATCH_INTERVAL", "10s", d.WebhookService.DispatchWebhookDeliveries)
+	if d.EventRelayService != nil {
+		go runPeriodically("event relay", "EVENT_RELAY_INTERVAL", "5s", d.EventRelayService.RelayEvents)
+	}
+	go runPeriodically("scheduled transactions", "SCHEDULE_RUN_INTERVAL", "1m", d.ScheduledTransactionService.RunDueSchedules)
+	go runPeriodically("transaction batches", "BATCH_PROCESS_INTERVAL", "10s", d.TransactionBatchService.ProcessPendingBatches)
+	go runPeriodically("fraud rule reload", "FRAUD_RULES_RELOAD_INTERVAL", "30s", d.FraudService.ReloadRules)
+	go runPeriodically("idempotency key purge", "IDEMPOTENCY_PURGE_INTERVAL", "1h", d.IdempotencyService.PurgeExpiredKeys)
+	go runPeriodically("reconciliation", "RECONCILIATION_INTERVAL", "1h", d.ReconciliationService.ReconcileRecent)
+}
+
+// runPeriodically runs job once at startup and then every interval read from
+// the env key, logging failures instead of stopping.
+func runPeriodically(name, envKey, defaultInterval string, job func(ctx context.Context) error) {
+	var (
+		log = helpers.Logger
+	)
+
+	interval, err := time.ParseDuration(helpers.GetEnv(envKey, defaultInterval))
+	if err != nil || interval <= 0 {
+		log.Warnf("invalid %s, using default %s", envKey, defaultInterval)
+		interval, _ = time.ParseDuration(defaultInterval)
+	}
+
+	log.Infof("start %s worker every %s", name, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(context.Background()); err != nil {
+			log.Errorf("%s worker failed: %v", name, err)
+		}
+		<-ticker.C
+	}
+}
@@ -1,11 +1,54 @@
 package constants
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 const (
 	SuccessMessage      = "success"
 	ErrFailedBadRequest = "data tidak sesuai"
 	ErrServerError      = "terjadi kesalahan pada server"
+	ErrUnauthorized     = "unauthorized"
+	ErrForbidden        = "akses ditolak"
+)
+
+const (
+	// ErrCodeLimitExceeded is returned alongside the message when a
+	// transaction is over one of the limits of the user
+	ErrCodeLimitExceeded = "LIMIT_EXCEEDED"
+)
+
+var (
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key sudah digunakan untuk request yang berbeda")
+	ErrIdempotencyKeyInProgress = errors.New("request dengan idempotency key yang sama sedang diproses")
+	ErrDeadLetterNotFound       = errors.New("notifikasi gagal tidak ditemukan")
+	ErrRefundAmountExceeded     = errors.New("jumlah refund melebihi sisa transaksi")
+	ErrInvalidTransactionFilter = errors.New("filter transaksi tidak sesuai")
+	ErrExportTooLarge           = errors.New("transaksi yang diekspor terlalu banyak, persempit filter")
+	ErrTransactionForbidden     = errors.New("akses ke transaksi ditolak")
+	ErrRefundAmountPrecision    = errors.New("jumlah refund tidak sesuai dengan mata uang transaksi")
+	ErrWebhookNotFound          = errors.New("webhook tidak ditemukan")
+	ErrWebhookForbidden         = errors.New("hanya operator dan sistem yang dapat menerima webhook semua pengguna")
+	ErrInvalidWebhookFilter     = errors.New("filter webhook tidak sesuai")
+	ErrInvalidWebhookURL        = errors.New("url webhook harus https dan mengarah ke alamat publik")
+	ErrInvalidTransferRecipient = errors.New("penerima transfer tidak sesuai")
+	ErrUserNotFound             = errors.New("pengguna tidak ditemukan")
+	ErrInvalidBankAccount       = errors.New("rekening tujuan tidak sesuai")
+	ErrInvalidSchedule          = errors.New("jadwal transaksi tidak sesuai")
+	ErrScheduleNotFound         = errors.New("jadwal transaksi tidak ditemukan")
+	ErrScheduleStatus           = errors.New("status jadwal transaksi tidak dapat diubah")
+	ErrScheduleRunMissed        = errors.New("jadwal transaksi terlewat dan tidak dijalankan")
+	ErrTransactionLimitNotFound = errors.New("batas transaksi pengguna tidak ditemukan")
+	ErrTransactionUnderReview   = errors.New("transaksi sedang ditinjau")
+	ErrTransactionStatusChanged = errors.New("status transaksi sudah berubah")
+	ErrFraudReviewNotFound      = errors.New("tinjauan transaksi tidak ditemukan")
+	ErrInvalidBatch             = errors.New("batch transaksi tidak sesuai")
+	ErrBatchNotFound            = errors.New("batch transaksi tidak ditemukan")
+	ErrBatchWalletTransaction   = errors.New("transaksi yang langsung mengubah saldo tidak dapat dibuat dalam batch ALL_OR_NOTHING")
+	ErrBatchItemCancelled       = errors.New("transaksi tidak dibuat karena transaksi lain dalam batch gagal")
+	ErrBatchItemInterrupted     = errors.New("pemrosesan transaksi terhenti, periksa transaksi dengan batch_id ini")
+	ErrBalanceOperationPending  = errors.New("perubahan saldo sedang diproses, periksa kembali status transaksi")
 )
 
 const (
@@ -13,26 +56,190 @@ const (
 	TransactionStatusSuccess  = "SUCCESS"
 	TransactionStatusFailed   = "FAILED"
 	TransactionStatusReversed = "REVERSED"
+
+	// TransactionStatusUnderReview holds a transaction flagged by the fraud
+	// rules until an operator approves or rejects it
+	TransactionStatusUnderReview = "UNDER_REVIEW"
 )
 
 const (
-	TransactionTypeTopup    = "TOPUP"
-	TransactionTypePurchase = "PURCHASE"
-	TransactionTypeRefund   = "REFUND"
+	TransactionTypeTopup      = "TOPUP"
+	TransactionTypePurchase   = "PURCHASE"
+	TransactionTypeRefund     = "REFUND"
+	TransactionTypeTransfer   = "TRANSFER"
+	TransactionTypeWithdrawal = "WITHDRAWAL"
 )
 
 var MapTransactionType = map[string]bool{
-	TransactionTypeTopup:    true,
-	TransactionTypePurchase: true,
-	TransactionTypeRefund:   true,
+	TransactionTypeTopup:      true,
+	TransactionTypePurchase:   true,
+	TransactionTypeRefund:     true,
+	TransactionTypeTransfer:   true,
+	TransactionTypeWithdrawal: true,
 }
 
-var MapTransactionStatusFlow = map[string][]string{
-	TransactionStatusPending: {TransactionStatusSuccess, TransactionStatusFailed},
-	TransactionStatusSuccess: {TransactionStatusReversed},
-	TransactionStatusFailed:  {TransactionStatusSuccess},
+const (
+	TransactionDirectionIn  = "IN"
+	TransactionDirectionOut = "OUT"
+)
+
+var MapTransactionStatus = map[string]bool{
+	TransactionStatusPending:  true,
+	TransactionStatusSuccess:  true,
+	TransactionStatusFailed:   true,
+	TransactionStatusReversed: true,
+
+	TransactionStatusUnderReview: true,
 }
 
+const (
+	BalanceOperationCredit = "CREDIT"
+	BalanceOperationDebit  = "DEBIT"
+)
+
+const (
+	BalanceOperationActionUpdateStatus = "UPDATE_STATUS"
+	BalanceOperationActionRefund       = "REFUND"
+	BalanceOperationActionTransfer     = "TRANSFER"
+	BalanceOperationActionCreate       = "CREATE"
+	// BalanceOperationActionFee is a status change that charges or returns
+	// a fee, with one op for the amount and one for the fee.
+	BalanceOperationActionFee = "FEE"
+)
+
+const (
+	BalanceOperationStatusPending     = "PENDING"
+	BalanceOperationStatusApplied     = "APPLIED"
+	BalanceOperationStatusCompleted   = "COMPLETED"
+	BalanceOperationStatusFailed      = "FAILED"
+	BalanceOperationStatusCompensated = "COMPENSATED"
+)
+
+const (
+	NotificationOutboxStatusPending = "PENDING"
+	NotificationOutboxStatusSent    = "SENT"
+	NotificationOutboxStatusDead    = "DEAD"
+)
+
+const (
+	EventOutboxStatusPending   = "PENDING"
+	EventOutboxStatusPublished = "PUBLISHED"
+	EventOutboxStatusDead      = "DEAD"
+)
+
+const (
+	ScheduleStatusActive    = "ACTIVE"
+	ScheduleStatusPaused    = "PAUSED"
+	ScheduleStatusCancelled = "CANCELLED"
+	ScheduleStatusCompleted = "COMPLETED"
+)
+
+const (
+	ScheduleRunStatusSuccess = "SUCCESS"
+	ScheduleRunStatusFailed  = "FAILED"
+	ScheduleRunStatusSkipped = "SKIPPED"
+)
+
+const (
+	FraudConditionAmountOver       = "amount_over"
+	FraudConditionVelocity         = "velocity"
+	FraudConditionAfterTransaction = "after_transaction"
+	FraudConditionNewUser          = "new_user"
+)
+
+const (
+	FraudRuleActionReview = "REVIEW"
+	FraudRuleActionLog    = "LOG"
+)
+
+const (
+	FraudDecisionAllow  = "ALLOW"
+	FraudDecisionReview = "REVIEW"
+)
+
+const (
+	FraudReviewStatusPending  = "PENDING"
+	FraudReviewStatusApproved = "APPROVED"
+	FraudReviewStatusRejected = "REJECTED"
+)
+
+const (
+	WebhookDeliveryStatusPending = "PENDING"
+	WebhookDeliveryStatusSent    = "SENT"
+	WebhookDeliveryStatusDead    = "DEAD"
+)
+
+const (
+	WebhookEventTransactionStatusChanged = "transaction.status_changed"
+
+	WebhookHeaderID        = "X-Webhook-Id"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	SystemUsername = "system"
+)
+
+const (
+	RoleUser     = "user"
+	RoleOperator = "operator"
+	RoleSystem   = "system"
+	RoleAdmin    = "admin"
+)
+
 const (
 	MaximumReversalDuration = time.Hour * 24
 )
+
+const (
+	DefaultTransactionPageSize = 20
+)
+
+const (
+	// MaxReferenceAttempts is how many references are tried before giving
+	// up on creating a transaction
+	MaxReferenceAttempts = 3
+)
+
+const (
+	// ExpiryBatchSize is how many transactions of each type are expired in
+	// one run of the expiry worker
+	ExpiryBatchSize = 100
+)
+
+const (
+	BatchModeAllOrNothing = "ALL_OR_NOTHING"
+	BatchModeBestEffort   = "BEST_EFFORT"
+)
+
+const (
+	BatchStatusPending    = "PENDING"
+	BatchStatusProcessing = "PROCESSING"
+	BatchStatusCompleted  = "COMPLETED"
+	BatchStatusFailed     = "FAILED"
+)
+
+const (
+	BatchItemStatusPending    = "PENDING"
+	BatchItemStatusProcessing = "PROCESSING"
+	BatchItemStatusSuccess    = "SUCCESS"
+	BatchItemStatusFailed     = "FAILED"
+)
+
+const (
+	// ScheduleBatchSize is how many due schedules are run in one run of the
+	// schedule worker
+	ScheduleBatchSize = 100
+	// MaxSkippedScheduleRuns is how many runs of one schedule missed while
+	// the worker was down are recorded as skipped
+	MaxSkippedScheduleRuns = 100
+)
+
+const (
+	// MaxBatchItems is how many transactions one batch can hold
+	MaxBatchItems = 10000
+	// BatchProcessSize is how many batches are processed in one run of the
+	// batch worker
+	BatchProcessSize = 10
+)
@@ -126,6 +126,7 @@ type UserData struct {
 	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
 	FullName      string                 `protobuf:"bytes,3,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
 	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	Roles         []string               `protobuf:"bytes,5,rep,name=roles,proto3" json:"roles,omitempty"` // The roles granted to the user, e.g. user, operator, system, admin
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -188,6 +189,13 @@ func (x *UserData) GetEmail() string {
 	return ""
 }
 
+func (x *UserData) GetRoles() []string {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
 var File_token_validation_proto protoreflect.FileDescriptor
 
 const file_token_validation_proto_rawDesc = "" +
@@ -197,12 +205,13 @@ const file_token_validation_proto_rawDesc = "" +
 	"\x05token\x18\x01 \x01(\tR\x05token\"X\n" +
 	"\rTokenResponse\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\x12-\n" +
-	"\x04data\x18\x02 \x01(\v2\x19.tokenvalidation.UserDataR\x04data\"r\n" +
+	"\x04data\x18\x02 \x01(\v2\x19.tokenvalidation.UserDataR\x04data\"\x88\x01\n" +
 	"\bUserData\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\x03R\x06userId\x12\x1a\n" +
 	"\busername\x18\x02 \x01(\tR\busername\x12\x1b\n" +
 	"\tfull_name\x18\x03 \x01(\tR\bfullName\x12\x14\n" +
-	"\x05email\x18\x04 \x01(\tR\x05email2a\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\x12\x14\n" +
+	"\x05roles\x18\x05 \x03(\tR\x05roles2a\n" +
 	"\x0fTokenValidation\x12N\n" +
 	"\rValidateToken\x12\x1d.tokenvalidation.TokenRequest\x1a\x1e.tokenvalidation.TokenResponseB\x13Z\x11./tokenvalidationb\x06proto3"
 
@@ -0,0 +1,1280 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v6.32.1
+// source: transaction.proto
+
+package transaction
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// The transaction data
+type Transaction struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId            int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Amount            string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"` // Decimal with two places, e.g. "1500.00"
+	TransactionType   string                 `protobuf:"bytes,4,opt,name=transaction_type,json=transactionType,proto3" json:"transaction_type,omitempty"`
+	TransactionStatus string                 `protobuf:"bytes,5,opt,name=transaction_status,json=transactionStatus,proto3" json:"transaction_status,omitempty"`
+	Reference         string                 `protobuf:"bytes,6,opt,name=reference,proto3" json:"reference,omitempty"`
+	Description       string                 `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`
+	AdditionalInfo    string                 `protobuf:"bytes,8,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"`
+	Date              string                 `protobuf:"bytes,9,opt,name=date,proto3" json:"date,omitempty"`                                                     // Creation time formatted as RFC 3339
+	ParentReference   string                 `protobuf:"bytes,10,opt,name=parent_reference,json=parentReference,proto3" json:"parent_reference,omitempty"`       // The purchase reference a refund belongs to
+	RefundedAmount    *string                `protobuf:"bytes,11,opt,name=refunded_amount,json=refundedAmount,proto3,oneof" json:"refunded_amount,omitempty"`    // Only set for purchase details
+	RemainingAmount   *string                `protobuf:"bytes,12,opt,name=remaining_amount,json=remainingAmount,proto3,oneof" json:"remaining_amount,omitempty"` // Only set for purchase details
+	Currency          string                 `protobuf:"bytes,13,opt,name=currency,proto3" json:"currency,omitempty"`                                            // ISO 4217 code, e.g. "IDR"
+	RecipientUserId   int64                  `protobuf:"varint,14,opt,name=recipient_user_id,json=recipientUserId,proto3" json:"recipient_user_id,omitempty"`    // Only set for transfers
+	Direction         string                 `protobuf:"bytes,15,opt,name=direction,proto3" json:"direction,omitempty"`                                          // IN or OUT for transfers, seen from the caller
+	BankAccount       *BankAccount           `protobuf:"bytes,16,opt,name=bank_account,json=bankAccount,proto3" json:"bank_account,omitempty"`                   // Only set for withdrawals
+	Channel           string                 `protobuf:"bytes,17,opt,name=channel,proto3" json:"channel,omitempty"`                                              // How the transaction was made, e.g. "app"
+	FeeAmount         string                 `protobuf:"bytes,18,opt,name=fee_amount,json=feeAmount,proto3" json:"fee_amount,omitempty"`                         // Charged on top of amount, or returned by a refund
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_transaction_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Transaction) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Transaction) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Transaction) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *Transaction) GetTransactionType() string {
+	if x != nil {
+		return x.TransactionType
+	}
+	return ""
+}
+
+func (x *Transaction) GetTransactionStatus() string {
+	if x != nil {
+		return x.TransactionStatus
+	}
+	return ""
+}
+
+func (x *Transaction) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *Transaction) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Transaction) GetAdditionalInfo() string {
+	if x != nil {
+		return x.AdditionalInfo
+	}
+	return ""
+}
+
+func (x *Transaction) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *Transaction) GetParentReference() string {
+	if x != nil {
+		return x.ParentReference
+	}
+	return ""
+}
+
+func (x *Transaction) GetRefundedAmount() string {
+	if x != nil && x.RefundedAmount != nil {
+		return *x.RefundedAmount
+	}
+	return ""
+}
+
+func (x *Transaction) GetRemainingAmount() string {
+	if x != nil && x.RemainingAmount != nil {
+		return *x.RemainingAmount
+	}
+	return ""
+}
+
+func (x *Transaction) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Transaction) GetRecipientUserId() int64 {
+	if x != nil {
+		return x.RecipientUserId
+	}
+	return 0
+}
+
+func (x *Transaction) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+func (x *Transaction) GetBankAccount() *BankAccount {
+	if x != nil {
+		return x.BankAccount
+	}
+	return nil
+}
+
+func (x *Transaction) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *Transaction) GetFeeAmount() string {
+	if x != nil {
+		return x.FeeAmount
+	}
+	return ""
+}
+
+// The bank account a withdrawal is paid out to
+type BankAccount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BankCode      string                 `protobuf:"bytes,1,opt,name=bank_code,json=bankCode,proto3" json:"bank_code,omitempty"`
+	AccountNumber string                 `protobuf:"bytes,2,opt,name=account_number,json=accountNumber,proto3" json:"account_number,omitempty"`
+	AccountName   string                 `protobuf:"bytes,3,opt,name=account_name,json=accountName,proto3" json:"account_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BankAccount) Reset() {
+	*x = BankAccount{}
+	mi := &file_transaction_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BankAccount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BankAccount) ProtoMessage() {}
+
+func (x *BankAccount) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BankAccount.ProtoReflect.Descriptor instead.
+func (*BankAccount) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BankAccount) GetBankCode() string {
+	if x != nil {
+		return x.BankCode
+	}
+	return ""
+}
+
+func (x *BankAccount) GetAccountNumber() string {
+	if x != nil {
+		return x.AccountNumber
+	}
+	return ""
+}
+
+func (x *BankAccount) GetAccountName() string {
+	if x != nil {
+		return x.AccountName
+	}
+	return ""
+}
+
+// The request message to create a transaction
+type CreateTransactionRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Amount          string                 `protobuf:"bytes,1,opt,name=amount,proto3" json:"amount,omitempty"` // Decimal with at most two places, e.g. "1500.50"
+	TransactionType string                 `protobuf:"bytes,2,opt,name=transaction_type,json=transactionType,proto3" json:"transaction_type,omitempty"`
+	Description     string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	AdditionalInfo  string                 `protobuf:"bytes,4,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"`
+	Currency        string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`                                         // ISO 4217 code, defaults to IDR
+	RecipientUserId int64                  `protobuf:"varint,6,opt,name=recipient_user_id,json=recipientUserId,proto3" json:"recipient_user_id,omitempty"` // Required for TRANSFER
+	BankAccount     *BankAccount           `protobuf:"bytes,7,opt,name=bank_account,json=bankAccount,proto3" json:"bank_account,omitempty"`                // Required for WITHDRAWAL
+	Channel         string                 `protobuf:"bytes,8,opt,name=channel,proto3" json:"channel,omitempty"`                                           // Optional, fees may differ per channel
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CreateTransactionRequest) Reset() {
+	*x = CreateTransactionRequest{}
+	mi := &file_transaction_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateTransactionRequest) ProtoMessage() {}
+
+func (x *CreateTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateTransactionRequest.ProtoReflect.Descriptor instead.
+func (*CreateTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateTransactionRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *CreateTransactionRequest) GetTransactionType() string {
+	if x != nil {
+		return x.TransactionType
+	}
+	return ""
+}
+
+func (x *CreateTransactionRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *CreateTransactionRequest) GetAdditionalInfo() string {
+	if x != nil {
+		return x.AdditionalInfo
+	}
+	return ""
+}
+
+func (x *CreateTransactionRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *CreateTransactionRequest) GetRecipientUserId() int64 {
+	if x != nil {
+		return x.RecipientUserId
+	}
+	return 0
+}
+
+func (x *CreateTransactionRequest) GetBankAccount() *BankAccount {
+	if x != nil {
+		return x.BankAccount
+	}
+	return nil
+}
+
+func (x *CreateTransactionRequest) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+// The result of a created transaction
+type CreateTransactionData struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Reference         string                 `protobuf:"bytes,1,opt,name=reference,proto3" json:"reference,omitempty"`
+	TransactionStatus string                 `protobuf:"bytes,2,opt,name=transaction_status,json=transactionStatus,proto3" json:"transaction_status,omitempty"`
+	FeeAmount         string                 `protobuf:"bytes,3,opt,name=fee_amount,json=feeAmount,proto3" json:"fee_amount,omitempty"` // Decimal with two places, e.g. "2500.00"
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CreateTransactionData) Reset() {
+	*x = CreateTransactionData{}
+	mi := &file_transaction_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateTransactionData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateTransactionData) ProtoMessage() {}
+
+func (x *CreateTransactionData) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateTransactionData.ProtoReflect.Descriptor instead.
+func (*CreateTransactionData) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateTransactionData) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *CreateTransactionData) GetTransactionStatus() string {
+	if x != nil {
+		return x.TransactionStatus
+	}
+	return ""
+}
+
+func (x *CreateTransactionData) GetFeeAmount() string {
+	if x != nil {
+		return x.FeeAmount
+	}
+	return ""
+}
+
+// The response message after creating or refunding a transaction
+type CreateTransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"` // Message indicating success or failure
+	Data          *CreateTransactionData `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	ErrorCode     string                 `protobuf:"bytes,3,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"` // Set when the failure has a code, e.g. LIMIT_EXCEEDED
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateTransactionResponse) Reset() {
+	*x = CreateTransactionResponse{}
+	mi := &file_transaction_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateTransactionResponse) ProtoMessage() {}
+
+func (x *CreateTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateTransactionResponse.ProtoReflect.Descriptor instead.
+func (*CreateTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateTransactionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CreateTransactionResponse) GetData() *CreateTransactionData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *CreateTransactionResponse) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+// The request message to update the status of a transaction
+type UpdateStatusTransactionRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Reference         string                 `protobuf:"bytes,1,opt,name=reference,proto3" json:"reference,omitempty"`
+	TransactionStatus string                 `protobuf:"bytes,2,opt,name=transaction_status,json=transactionStatus,proto3" json:"transaction_status,omitempty"`
+	AdditionalInfo    string                 `protobuf:"bytes,3,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *UpdateStatusTransactionRequest) Reset() {
+	*x = UpdateStatusTransactionRequest{}
+	mi := &file_transaction_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateStatusTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateStatusTransactionRequest) ProtoMessage() {}
+
+func (x *UpdateStatusTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateStatusTransactionRequest.ProtoReflect.Descriptor instead.
+func (*UpdateStatusTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateStatusTransactionRequest) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *UpdateStatusTransactionRequest) GetTransactionStatus() string {
+	if x != nil {
+		return x.TransactionStatus
+	}
+	return ""
+}
+
+func (x *UpdateStatusTransactionRequest) GetAdditionalInfo() string {
+	if x != nil {
+		return x.AdditionalInfo
+	}
+	return ""
+}
+
+// The response message after updating the status of a transaction
+type UpdateStatusTransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"` // Message indicating success or failure
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateStatusTransactionResponse) Reset() {
+	*x = UpdateStatusTransactionResponse{}
+	mi := &file_transaction_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateStatusTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateStatusTransactionResponse) ProtoMessage() {}
+
+func (x *UpdateStatusTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateStatusTransactionResponse.ProtoReflect.Descriptor instead.
+func (*UpdateStatusTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UpdateStatusTransactionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// The request message to list transactions, every field is optional
+type GetTransactionRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	TransactionType   string                 `protobuf:"bytes,1,opt,name=transaction_type,json=transactionType,proto3" json:"transaction_type,omitempty"`
+	TransactionStatus string                 `protobuf:"bytes,2,opt,name=transaction_status,json=transactionStatus,proto3" json:"transaction_status,omitempty"`
+	StartDate         string                 `protobuf:"bytes,3,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"` // Formatted as 2006-01-02
+	EndDate           string                 `protobuf:"bytes,4,opt,name=end_date,json=endDate,proto3" json:"end_date,omitempty"`       // Formatted as 2006-01-02, inclusive
+	MinAmount         string                 `protobuf:"bytes,5,opt,name=min_amount,json=minAmount,proto3" json:"min_amount,omitempty"`
+	MaxAmount         string                 `protobuf:"bytes,6,opt,name=max_amount,json=maxAmount,proto3" json:"max_amount,omitempty"`
+	Sort              string                 `protobuf:"bytes,7,opt,name=sort,proto3" json:"sort,omitempty"`     // asc or desc, defaults to desc
+	Cursor            string                 `protobuf:"bytes,8,opt,name=cursor,proto3" json:"cursor,omitempty"` // next_cursor of the previous page
+	Limit             int32                  `protobuf:"varint,9,opt,name=limit,proto3" json:"limit,omitempty"`
+	Currency          string                 `protobuf:"bytes,10,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *GetTransactionRequest) Reset() {
+	*x = GetTransactionRequest{}
+	mi := &file_transaction_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionRequest) ProtoMessage() {}
+
+func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetTransactionRequest) GetTransactionType() string {
+	if x != nil {
+		return x.TransactionType
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetTransactionStatus() string {
+	if x != nil {
+		return x.TransactionStatus
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetEndDate() string {
+	if x != nil {
+		return x.EndDate
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetMinAmount() string {
+	if x != nil {
+		return x.MinAmount
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetMaxAmount() string {
+	if x != nil {
+		return x.MaxAmount
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetTransactionRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// The response message containing the transactions of the user
+type GetTransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"` // Message indicating success or failure
+	Data          []*Transaction         `protobuf:"bytes,2,rep,name=data,proto3" json:"data,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"` // Empty on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionResponse) Reset() {
+	*x = GetTransactionResponse{}
+	mi := &file_transaction_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionResponse) ProtoMessage() {}
+
+func (x *GetTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionResponse.ProtoReflect.Descriptor instead.
+func (*GetTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetTransactionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GetTransactionResponse) GetData() []*Transaction {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *GetTransactionResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+// The request message to get a transaction detail
+type GetTransactionDetailRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reference     string                 `protobuf:"bytes,1,opt,name=reference,proto3" json:"reference,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionDetailRequest) Reset() {
+	*x = GetTransactionDetailRequest{}
+	mi := &file_transaction_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionDetailRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionDetailRequest) ProtoMessage() {}
+
+func (x *GetTransactionDetailRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionDetailRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionDetailRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetTransactionDetailRequest) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+// The response message containing the transaction detail
+type GetTransactionDetailResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"` // Message indicating success or failure
+	Data          *Transaction           `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionDetailResponse) Reset() {
+	*x = GetTransactionDetailResponse{}
+	mi := &file_transaction_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionDetailResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionDetailResponse) ProtoMessage() {}
+
+func (x *GetTransactionDetailResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionDetailResponse.ProtoReflect.Descriptor instead.
+func (*GetTransactionDetailResponse) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetTransactionDetailResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GetTransactionDetailResponse) GetData() *Transaction {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// The request message to refund a transaction
+type RefundTransactionRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Reference      string                 `protobuf:"bytes,1,opt,name=reference,proto3" json:"reference,omitempty"`
+	Description    string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	AdditionalInfo string                 `protobuf:"bytes,3,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"`
+	Amount         string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"` // Optional, the remaining amount is refunded when empty
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RefundTransactionRequest) Reset() {
+	*x = RefundTransactionRequest{}
+	mi := &file_transaction_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundTransactionRequest) ProtoMessage() {}
+
+func (x *RefundTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundTransactionRequest.ProtoReflect.Descriptor instead.
+func (*RefundTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RefundTransactionRequest) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *RefundTransactionRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *RefundTransactionRequest) GetAdditionalInfo() string {
+	if x != nil {
+		return x.AdditionalInfo
+	}
+	return ""
+}
+
+func (x *RefundTransactionRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+// A status change of a transaction, from_status is empty for its creation
+type TransactionStatusHistory struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Id                   int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	TransactionReference string                 `protobuf:"bytes,2,opt,name=transaction_reference,json=transactionReference,proto3" json:"transaction_reference,omitempty"`
+	FromStatus           string                 `protobuf:"bytes,3,opt,name=from_status,json=fromStatus,proto3" json:"from_status,omitempty"`
+	ToStatus             string                 `protobuf:"bytes,4,opt,name=to_status,json=toStatus,proto3" json:"to_status,omitempty"`
+	ActorUserId          int64                  `protobuf:"varint,5,opt,name=actor_user_id,json=actorUserId,proto3" json:"actor_user_id,omitempty"`
+	Actor                string                 `protobuf:"bytes,6,opt,name=actor,proto3" json:"actor,omitempty"`
+	AdditionalInfo       string                 `protobuf:"bytes,7,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"` // The part of additional_info added by the change
+	Date                 string                 `protobuf:"bytes,8,opt,name=date,proto3" json:"date,omitempty"`                                           // Formatted as RFC 3339
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *TransactionStatusHistory) Reset() {
+	*x = TransactionStatusHistory{}
+	mi := &file_transaction_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionStatusHistory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionStatusHistory) ProtoMessage() {}
+
+func (x *TransactionStatusHistory) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionStatusHistory.ProtoReflect.Descriptor instead.
+func (*TransactionStatusHistory) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *TransactionStatusHistory) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *TransactionStatusHistory) GetTransactionReference() string {
+	if x != nil {
+		return x.TransactionReference
+	}
+	return ""
+}
+
+func (x *TransactionStatusHistory) GetFromStatus() string {
+	if x != nil {
+		return x.FromStatus
+	}
+	return ""
+}
+
+func (x *TransactionStatusHistory) GetToStatus() string {
+	if x != nil {
+		return x.ToStatus
+	}
+	return ""
+}
+
+func (x *TransactionStatusHistory) GetActorUserId() int64 {
+	if x != nil {
+		return x.ActorUserId
+	}
+	return 0
+}
+
+func (x *TransactionStatusHistory) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *TransactionStatusHistory) GetAdditionalInfo() string {
+	if x != nil {
+		return x.AdditionalInfo
+	}
+	return ""
+}
+
+func (x *TransactionStatusHistory) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+// The response message containing the status history of a transaction
+type GetTransactionStatusHistoryResponse struct {
+	state         protoimpl.MessageState      `protogen:"open.v1"`
+	Message       string                      `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"` // Message indicating success or failure
+	Data          []*TransactionStatusHistory `protobuf:"bytes,2,rep,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionStatusHistoryResponse) Reset() {
+	*x = GetTransactionStatusHistoryResponse{}
+	mi := &file_transaction_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionStatusHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionStatusHistoryResponse) ProtoMessage() {}
+
+func (x *GetTransactionStatusHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionStatusHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetTransactionStatusHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetTransactionStatusHistoryResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GetTransactionStatusHistoryResponse) GetData() []*TransactionStatusHistory {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_transaction_proto protoreflect.FileDescriptor
+
+const file_transaction_proto_rawDesc = "" +
+	"\n" +
+	"\x11transaction.proto\x12\vtransaction\"\xb3\x05\n" +
+	"\vTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\tR\x06amount\x12)\n" +
+	"\x10transaction_type\x18\x04 \x01(\tR\x0ftransactionType\x12-\n" +
+	"\x12transaction_status\x18\x05 \x01(\tR\x11transactionStatus\x12\x1c\n" +
+	"\treference\x18\x06 \x01(\tR\treference\x12 \n" +
+	"\vdescription\x18\a \x01(\tR\vdescription\x12'\n" +
+	"\x0fadditional_info\x18\b \x01(\tR\x0eadditionalInfo\x12\x12\n" +
+	"\x04date\x18\t \x01(\tR\x04date\x12)\n" +
+	"\x10parent_reference\x18\n" +
+	" \x01(\tR\x0fparentReference\x12,\n" +
+	"\x0frefunded_amount\x18\v \x01(\tH\x00R\x0erefundedAmount\x88\x01\x01\x12.\n" +
+	"\x10remaining_amount\x18\f \x01(\tH\x01R\x0fremainingAmount\x88\x01\x01\x12\x1a\n" +
+	"\bcurrency\x18\r \x01(\tR\bcurrency\x12*\n" +
+	"\x11recipient_user_id\x18\x0e \x01(\x03R\x0frecipientUserId\x12\x1c\n" +
+	"\tdirection\x18\x0f \x01(\tR\tdirection\x12;\n" +
+	"\fbank_account\x18\x10 \x01(\v2\x18.transaction.BankAccountR\vbankAccount\x12\x18\n" +
+	"\achannel\x18\x11 \x01(\tR\achannel\x12\x1d\n" +
+	"\n" +
+	"fee_amount\x18\x12 \x01(\tR\tfeeAmountB\x12\n" +
+	"\x10_refunded_amountB\x13\n" +
+	"\x11_remaining_amount\"t\n" +
+	"\vBankAccount\x12\x1b\n" +
+	"\tbank_code\x18\x01 \x01(\tR\bbankCode\x12%\n" +
+	"\x0eaccount_number\x18\x02 \x01(\tR\raccountNumber\x12!\n" +
+	"\faccount_name\x18\x03 \x01(\tR\vaccountName\"\xc7\x02\n" +
+	"\x18CreateTransactionRequest\x12\x16\n" +
+	"\x06amount\x18\x01 \x01(\tR\x06amount\x12)\n" +
+	"\x10transaction_type\x18\x02 \x01(\tR\x0ftransactionType\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12'\n" +
+	"\x0fadditional_info\x18\x04 \x01(\tR\x0eadditionalInfo\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12*\n" +
+	"\x11recipient_user_id\x18\x06 \x01(\x03R\x0frecipientUserId\x12;\n" +
+	"\fbank_account\x18\a \x01(\v2\x18.transaction.BankAccountR\vbankAccount\x12\x18\n" +
+	"\achannel\x18\b \x01(\tR\achannel\"\x83\x01\n" +
+	"\x15CreateTransactionData\x12\x1c\n" +
+	"\treference\x18\x01 \x01(\tR\treference\x12-\n" +
+	"\x12transaction_status\x18\x02 \x01(\tR\x11transactionStatus\x12\x1d\n" +
+	"\n" +
+	"fee_amount\x18\x03 \x01(\tR\tfeeAmount\"\x8c\x01\n" +
+	"\x19CreateTransactionResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x126\n" +
+	"\x04data\x18\x02 \x01(\v2\".transaction.CreateTransactionDataR\x04data\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\x03 \x01(\tR\terrorCode\"\x96\x01\n" +
+	"\x1eUpdateStatusTransactionRequest\x12\x1c\n" +
+	"\treference\x18\x01 \x01(\tR\treference\x12-\n" +
+	"\x12transaction_status\x18\x02 \x01(\tR\x11transactionStatus\x12'\n" +
+	"\x0fadditional_info\x18\x03 \x01(\tR\x0eadditionalInfo\";\n" +
+	"\x1fUpdateStatusTransactionResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"\xc7\x02\n" +
+	"\x15GetTransactionRequest\x12)\n" +
+	"\x10transaction_type\x18\x01 \x01(\tR\x0ftransactionType\x12-\n" +
+	"\x12transaction_status\x18\x02 \x01(\tR\x11transactionStatus\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x03 \x01(\tR\tstartDate\x12\x19\n" +
+	"\bend_date\x18\x04 \x01(\tR\aendDate\x12\x1d\n" +
+	"\n" +
+	"min_amount\x18\x05 \x01(\tR\tminAmount\x12\x1d\n" +
+	"\n" +
+	"max_amount\x18\x06 \x01(\tR\tmaxAmount\x12\x12\n" +
+	"\x04sort\x18\a \x01(\tR\x04sort\x12\x16\n" +
+	"\x06cursor\x18\b \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05limit\x18\t \x01(\x05R\x05limit\x12\x1a\n" +
+	"\bcurrency\x18\n" +
+	" \x01(\tR\bcurrency\"\x81\x01\n" +
+	"\x16GetTransactionResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12,\n" +
+	"\x04data\x18\x02 \x03(\v2\x18.transaction.TransactionR\x04data\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\";\n" +
+	"\x1bGetTransactionDetailRequest\x12\x1c\n" +
+	"\treference\x18\x01 \x01(\tR\treference\"f\n" +
+	"\x1cGetTransactionDetailResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12,\n" +
+	"\x04data\x18\x02 \x01(\v2\x18.transaction.TransactionR\x04data\"\x9b\x01\n" +
+	"\x18RefundTransactionRequest\x12\x1c\n" +
+	"\treference\x18\x01 \x01(\tR\treference\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12'\n" +
+	"\x0fadditional_info\x18\x03 \x01(\tR\x0eadditionalInfo\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\"\x94\x02\n" +
+	"\x18TransactionStatusHistory\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x123\n" +
+	"\x15transaction_reference\x18\x02 \x01(\tR\x14transactionReference\x12\x1f\n" +
+	"\vfrom_status\x18\x03 \x01(\tR\n" +
+	"fromStatus\x12\x1b\n" +
+	"\tto_status\x18\x04 \x01(\tR\btoStatus\x12\"\n" +
+	"\ractor_user_id\x18\x05 \x01(\x03R\vactorUserId\x12\x14\n" +
+	"\x05actor\x18\x06 \x01(\tR\x05actor\x12'\n" +
+	"\x0fadditional_info\x18\a \x01(\tR\x0eadditionalInfo\x12\x12\n" +
+	"\x04date\x18\b \x01(\tR\x04date\"z\n" +
+	"#GetTransactionStatusHistoryResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x129\n" +
+	"\x04data\x18\x02 \x03(\v2%.transaction.TransactionStatusHistoryR\x04data2\x95\x05\n" +
+	"\x12TransactionService\x12b\n" +
+	"\x11CreateTransaction\x12%.transaction.CreateTransactionRequest\x1a&.transaction.CreateTransactionResponse\x12t\n" +
+	"\x17UpdateStatusTransaction\x12+.transaction.UpdateStatusTransactionRequest\x1a,.transaction.UpdateStatusTransactionResponse\x12Y\n" +
+	"\x0eGetTransaction\x12\".transaction.GetTransactionRequest\x1a#.transaction.GetTransactionResponse\x12k\n" +
+	"\x14GetTransactionDetail\x12(.transaction.GetTransactionDetailRequest\x1a).transaction.GetTransactionDetailResponse\x12b\n" +
+	"\x11RefundTransaction\x12%.transaction.RefundTransactionRequest\x1a&.transaction.CreateTransactionResponse\x12y\n" +
+	"\x1bGetTransactionStatusHistory\x12(.transaction.GetTransactionDetailRequest\x1a0.transaction.GetTransactionStatusHistoryResponseB\x0fZ\r./transactionb\x06proto3"
+
+var (
+	file_transaction_proto_rawDescOnce sync.Once
+	file_transaction_proto_rawDescData []byte
+)
+
+func file_transaction_proto_rawDescGZIP() []byte {
+	file_transaction_proto_rawDescOnce.Do(func() {
+		file_transaction_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_transaction_proto_rawDesc), len(file_transaction_proto_rawDesc)))
+	})
+	return file_transaction_proto_rawDescData
+}
+
+var file_transaction_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_transaction_proto_goTypes = []any{
+	(*Transaction)(nil),                         // 0: transaction.Transaction
+	(*BankAccount)(nil),                         // 1: transaction.BankAccount
+	(*CreateTransactionRequest)(nil),            // 2: transaction.CreateTransactionRequest
+	(*CreateTransactionData)(nil),               // 3: transaction.CreateTransactionData
+	(*CreateTransactionResponse)(nil),           // 4: transaction.CreateTransactionResponse
+	(*UpdateStatusTransactionRequest)(nil),      // 5: transaction.UpdateStatusTransactionRequest
+	(*UpdateStatusTransactionResponse)(nil),     // 6: transaction.UpdateStatusTransactionResponse
+	(*GetTransactionRequest)(nil),               // 7: transaction.GetTransactionRequest
+	(*GetTransactionResponse)(nil),              // 8: transaction.GetTransactionResponse
+	(*GetTransactionDetailRequest)(nil),         // 9: transaction.GetTransactionDetailRequest
+	(*GetTransactionDetailResponse)(nil),        // 10: transaction.GetTransactionDetailResponse
+	(*RefundTransactionRequest)(nil),            // 11: transaction.RefundTransactionRequest
+	(*TransactionStatusHistory)(nil),            // 12: transaction.TransactionStatusHistory
+	(*GetTransactionStatusHistoryResponse)(nil), // 13: transaction.GetTransactionStatusHistoryResponse
+}
+var file_transaction_proto_depIdxs = []int32{
+	1,  // 0: transaction.Transaction.bank_account:type_name -> transaction.BankAccount
+	1,  // 1: transaction.CreateTransactionRequest.bank_account:type_name -> transaction.BankAccount
+	3,  // 2: transaction.CreateTransactionResponse.data:type_name -> transaction.CreateTransactionData
+	0,  // 3: transaction.GetTransactionResponse.data:type_name -> transaction.Transaction
+	0,  // 4: transaction.GetTransactionDetailResponse.data:type_name -> transaction.Transaction
+	12, // 5: transaction.GetTransactionStatusHistoryResponse.data:type_name -> transaction.TransactionStatusHistory
+	2,  // 6: transaction.TransactionService.CreateTransaction:input_type -> transaction.CreateTransactionRequest
+	5,  // 7: transaction.TransactionService.UpdateStatusTransaction:input_type -> transaction.UpdateStatusTransactionRequest
+	7,  // 8: transaction.TransactionService.GetTransaction:input_type -> transaction.GetTransactionRequest
+	9,  // 9: transaction.TransactionService.GetTransactionDetail:input_type -> transaction.GetTransactionDetailRequest
+	11, // 10: transaction.TransactionService.RefundTransaction:input_type -> transaction.RefundTransactionRequest
+	9,  // 11: transaction.TransactionService.GetTransactionStatusHistory:input_type -> transaction.GetTransactionDetailRequest
+	4,  // 12: transaction.TransactionService.CreateTransaction:output_type -> transaction.CreateTransactionResponse
+	6,  // 13: transaction.TransactionService.UpdateStatusTransaction:output_type -> transaction.UpdateStatusTransactionResponse
+	8,  // 14: transaction.TransactionService.GetTransaction:output_type -> transaction.GetTransactionResponse
+	10, // 15: transaction.TransactionService.GetTransactionDetail:output_type -> transaction.GetTransactionDetailResponse
+	4,  // 16: transaction.TransactionService.RefundTransaction:output_type -> transaction.CreateTransactionResponse
+	13, // 17: transaction.TransactionService.GetTransactionStatusHistory:output_type -> transaction.GetTransactionStatusHistoryResponse
+	12, // [12:18] is the sub-list for method output_type
+	6,  // [6:12] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_transaction_proto_init() }
+func file_transaction_proto_init() {
+	if File_transaction_proto != nil {
+		return
+	}
+	file_transaction_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_transaction_proto_rawDesc), len(file_transaction_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_transaction_proto_goTypes,
+		DependencyIndexes: file_transaction_proto_depIdxs,
+		MessageInfos:      file_transaction_proto_msgTypes,
+	}.Build()
+	File_transaction_proto = out.File
+	file_transaction_proto_goTypes = nil
+	file_transaction_proto_depIdxs = nil
+}
@@ -45,6 +45,7 @@ func (*External) ValidateToken(ctx context.Context, token string) (models.TokenD
 	resp.Username = response.Data.Username
 	resp.FullName = response.Data.FullName
 	resp.Email = response.Data.Email
+	resp.Roles = response.Data.Roles
 
 	return resp, nil
 
@@ -0,0 +1,63 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+type UserResponse struct {
+	Message string `json:"message"`
+	Data    User   `json:"data"`
+}
+
+// GetUser looks up userID in the user management service with the system
+// token. It returns constants.ErrUserNotFound when the user does not exist.
+func (e *External) GetUser(ctx context.Context, userID int) (User, error) {
+	var (
+		resp User
+	)
+
+	endpoint := helpers.GetEnv("UMS_HOST", "") + helpers.GetEnv("UMS_ENDPOINT_USER", "") + "/" + strconv.Itoa(userID)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to create new http request")
+	}
+
+	httpReq.Header.Set("Authorization", helpers.GetEnv("UMS_SYSTEM_TOKEN", ""))
+
+	client := &http.Client{}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to connect ums")
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode == http.StatusNotFound {
+		return resp, constants.ErrUserNotFound
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("got error response from ums: %d", httpResp.StatusCode)
+	}
+
+	result := &UserResponse{}
+	err = json.NewDecoder(httpResp.Body).Decode(result)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to read response body")
+	}
+
+	return result.Data, nil
+}
@@ -5,92 +5,174 @@ import (
 	"context"
 	"encoding/json"
 	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type UpdateBalance struct {
-	Reference string  `json:"reference"`
-	Amount    float64 `json:"amount"`
+	Reference string       `json:"reference"`
+	Amount    models.Money `json:"amount"`
+	Currency  string       `json:"currency"`
+	// UserID tells the wallet whose balance to update when the call is made
+	// with the system token instead of the user's own token.
+	UserID int `json:"user_id,omitempty"`
 }
 
 type UpdateBalanceResponse struct {
 	Message string `json:"message"`
 	Data    struct {
-		Balance float64 `json:"balance"`
+		Balance models.Money `json:"balance"`
 	} `json:"data"`
 }
 
+var (
+	// ErrWalletOutcomeUnknown is returned when the wallet may have applied a
+	// call without confirming it: the connection failed, timed out or the
+	// wallet answered with a server error.
+	ErrWalletOutcomeUnknown = errors.New("wallet outcome unknown")
+	// ErrWalletDuplicateReference is returned when the wallet already has an
+	// entry with the reference of the call.
+	ErrWalletDuplicateReference = errors.New("wallet reference already used")
+)
+
 func (e *External) CreditBalance(ctx context.Context, token string, req UpdateBalance) (*UpdateBalanceResponse, error) {
-	payload, err := json.Marshal(req)
+	return e.updateBalance(ctx, token, helpers.GetEnv("WALLET_ENDPOINT_CREDIT", ""), req)
+}
 
+func (e *External) DebitBalance(ctx context.Context, token string, req UpdateBalance) (*UpdateBalanceResponse, error) {
+	return e.updateBalance(ctx, token, helpers.GetEnv("WALLET_ENDPOINT_DEBIT", ""), req)
+}
+
+// updateBalance makes a credit or a debit. Any other error than
+// ErrWalletOutcomeUnknown means the wallet rejected the call and the balance
+// did not change.
+func (e *External) updateBalance(ctx context.Context, token, endpoint string, req UpdateBalance) (*UpdateBalanceResponse, error) {
+	payload, err := json.Marshal(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal json")
 	}
 
-	url := helpers.GetEnv("WALLET_HOST", "") + helpers.GetEnv("WALLET_ENDPOINT_CREDIT", "")
-	fmt.Printf("endpoint %s", url)
-	httpReq, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(payload))
+	timeout, err := time.ParseDuration(helpers.GetEnv("WALLET_TIMEOUT", "10s"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse wallet timeout")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, helpers.GetEnv("WALLET_HOST", "")+endpoint, bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create new http request")
 	}
 
 	httpReq.Header.Set("Authorization", token)
 
-	client := &http.Client{}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to connect wallet service")
+		return nil, fmt.Errorf("%w: failed to connect wallet service: %v", ErrWalletOutcomeUnknown, err)
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return nil, ErrWalletDuplicateReference
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: got error response from wallet service: %d", ErrWalletOutcomeUnknown, resp.StatusCode)
+	case resp.StatusCode != http.StatusCreated:
 		return nil, fmt.Errorf("got error response from wallet service: %d", resp.StatusCode)
 	}
 
+	// the call is applied at this point, the balance in the body is only
+	// informative
 	result := &UpdateBalanceResponse{}
 	err = json.NewDecoder(resp.Body).Decode(result)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read response body")
+		helpers.Logger.Warn("failed to read wallet response body: ", err)
 	}
-	defer resp.Body.Close()
 
 	return result, nil
 }
 
-func (e *External) DebitBalance(ctx context.Context, token string, req UpdateBalance) (*UpdateBalanceResponse, error) {
-	payload, err := json.Marshal(req)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to marshal json")
-	}
+// WalletHistoryRequest selects the wallet entries made in [StartTime, EndTime),
+// only the ones with Reference when it is set.
+type WalletHistoryRequest struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Reference string
+}
 
-	url := helpers.GetEnv("WALLET_HOST", "") + helpers.GetEnv("WALLET_ENDPOINT_DEBIT", "")
-	fmt.Printf("endpoint %s", url)
-	httpReq, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create new http request")
-	}
+type WalletHistoryEntry struct {
+	Reference             string       `json:"reference"`
+	Amount                models.Money `json:"amount"`
+	Currency              string       `json:"currency"`
+	WalletTransactionType string       `json:"wallet_transaction_type"`
+	UserID                int          `json:"user_id"`
+	Date                  time.Time    `json:"date"`
+}
 
-	httpReq.Header.Set("Authorization", token)
+type WalletHistoryResponse struct {
+	Message string               `json:"message"`
+	Data    []WalletHistoryEntry `json:"data"`
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to connect wallet service")
-	}
+// GetWalletHistory returns every wallet entry of the window, following the
+// pages of the wallet history endpoint.
+func (e *External) GetWalletHistory(ctx context.Context, token string, req WalletHistoryRequest) ([]WalletHistoryEntry, error) {
+	const limit = 100
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("got error response from wallet service: %d", resp.StatusCode)
-	}
+	var (
+		resp []WalletHistoryEntry
+	)
 
-	result := &UpdateBalanceResponse{}
-	err = json.NewDecoder(resp.Body).Decode(result)
+	timeout, err := time.ParseDuration(helpers.GetEnv("WALLET_TIMEOUT", "10s"))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read response body")
+		return nil, errors.Wrap(err, "failed to parse wallet timeout")
 	}
-	defer resp.Body.Close()
 
-	return result, nil
+	endpoint := helpers.GetEnv("WALLET_HOST", "") + helpers.GetEnv("WALLET_ENDPOINT_HISTORY", "")
+	client := &http.Client{Timeout: timeout}
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("start_time", req.StartTime.Format(time.RFC3339Nano))
+		query.Set("end_time", req.EndTime.Format(time.RFC3339Nano))
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(limit))
+		if req.Reference != "" {
+			query.Set("reference", req.Reference)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create new http request")
+		}
+
+		httpReq.Header.Set("Authorization", token)
+
+		httpResp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect wallet service")
+		}
+
+		if httpResp.StatusCode != http.StatusOK {
+			httpResp.Body.Close()
+			return nil, fmt.Errorf("got error response from wallet service: %d", httpResp.StatusCode)
+		}
+
+		result := &WalletHistoryResponse{}
+		err = json.NewDecoder(httpResp.Body).Decode(result)
+		httpResp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read response body")
+		}
+
+		resp = append(resp, result.Data...)
+		if len(result.Data) < limit {
+			return resp, nil
+		}
+	}
 }
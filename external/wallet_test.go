@@ -0,0 +1,35 @@
+package external
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ewallet-transaction/helpers"
+)
+
+// A wallet history call that does not answer gives up after WALLET_TIMEOUT.
+func TestGetWalletHistoryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	helpers.Env["WALLET_HOST"] = server.URL
+	helpers.Env["WALLET_TIMEOUT"] = "50ms"
+	defer delete(helpers.Env, "WALLET_HOST")
+	defer delete(helpers.Env, "WALLET_TIMEOUT")
+
+	start := time.Now()
+	_, err := (&External{}).GetWalletHistory(context.Background(), "token", WalletHistoryRequest{})
+	if err == nil {
+		t.Fatal("GetWalletHistory() error = nil, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetWalletHistory() returned after %v, want about 50ms", elapsed)
+	}
+}
@@ -0,0 +1,141 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"ewallet-transaction/helpers"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWebhookURLNotAllowed is returned for a webhook URL that is not https or
+// whose host is, or resolves to, an address that is not public.
+var ErrWebhookURLNotAllowed = errors.New("webhook url not allowed")
+
+// nonPublicPrefixes are the ranges refused on top of the private, loopback,
+// link-local, multicast and unspecified addresses.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// ValidateWebhookURL checks that rawURL is https and that its host only
+// resolves to public addresses. SendWebhook checks the address again when it
+// connects, so a host that resolves elsewhere later is still refused.
+// WEBHOOK_ALLOW_PRIVATE=true lifts both checks for local development.
+func (*External) ValidateWebhookURL(ctx context.Context, rawURL string) error {
+	if allowPrivateWebhooks() {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return ErrWebhookURLNotAllowed
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return errors.Wrap(ErrWebhookURLNotAllowed, err.Error())
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return errors.Wrapf(ErrWebhookURLNotAllowed, "%s resolves to %s", u.Hostname(), addr)
+		}
+	}
+
+	return nil
+}
+
+// SendWebhook posts payload to url and returns the response status. Any
+// status other than 2xx is returned as an error too. Redirects are not
+// followed.
+func (*External) SendWebhook(ctx context.Context, rawURL string, header map[string]string, payload []byte) (int, error) {
+	timeout, err := time.ParseDuration(helpers.GetEnv("WEBHOOK_TIMEOUT", "10s"))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse webhook timeout")
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateWebhooks() {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme != "https" {
+			return 0, ErrWebhookURLNotAllowed
+		}
+		dialer.Control = checkWebhookDial
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create new http request")
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, val := range header {
+		httpReq.Header.Set(key, val)
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       dialer.DialContext,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(httpReq)
+	if errors.Is(err, ErrWebhookURLNotAllowed) {
+		return 0, ErrWebhookURLNotAllowed
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to connect webhook")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("got error response from webhook: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// checkWebhookDial refuses connections to addresses that are not public, it
+// runs after the host is resolved so it sees the address actually dialed.
+func checkWebhookDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !isPublicAddr(addrPort.Addr()) {
+		return errors.Wrapf(ErrWebhookURLNotAllowed, "address %s", address)
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func allowPrivateWebhooks() bool {
+	return helpers.GetEnv("WEBHOOK_ALLOW_PRIVATE", "false") == "true"
+}
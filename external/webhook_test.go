@@ -0,0 +1,45 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://203.0.114.10/hook", false},
+		{"http://203.0.114.10/hook", true},
+		{"https://127.0.0.1/hook", true},
+		{"https://10.1.2.3/hook", true},
+		{"https://192.168.1.1/hook", true},
+		{"https://169.254.169.254/latest/meta-data", true},
+		{"https://100.64.0.1/hook", true},
+		{"https://0.0.0.0/hook", true},
+		{"https://[::1]/hook", true},
+		{"https://[fd00::1]/hook", true},
+		{"https://[::ffff:127.0.0.1]/hook", true},
+		{"https://[fe80::1]/hook", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := (&External{}).ValidateWebhookURL(context.Background(), tt.url)
+			if tt.wantErr != errors.Is(err, ErrWebhookURLNotAllowed) || (!tt.wantErr && err != nil) {
+				t.Errorf("ValidateWebhookURL(%s) error = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A host that resolved to a public address when the webhook was created is
+// still refused when it points somewhere private at delivery time.
+func TestSendWebhookRefusesPrivateAddress(t *testing.T) {
+	_, err := (&External{}).SendWebhook(context.Background(), "https://127.0.0.1:1/hook", nil, []byte("{}"))
+	if !errors.Is(err, ErrWebhookURLNotAllowed) {
+		t.Errorf("SendWebhook() error = %v, want %v", err, ErrWebhookURLNotAllowed)
+	}
+}
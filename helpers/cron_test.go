@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+		"@every",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := ParseCron(expr)
+			if err == nil {
+				t.Errorf("ParseCron(%q) error = nil, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2026, 1, 7, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "every minute",
+			expr: "* * * * *",
+			from: from.Add(15 * time.Second),
+			want: []time.Time{
+				time.Date(2026, 1, 7, 10, 31, 0, 0, time.UTC),
+				time.Date(2026, 1, 7, 10, 32, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "list",
+			expr: "0,15,45 10 * * *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 7, 10, 45, 0, 0, time.UTC),
+				time.Date(2026, 1, 8, 10, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 8, 10, 15, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "range",
+			expr: "0 9-11 * * *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 7, 11, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 8, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 8, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "step",
+			expr: "*/20 * * * *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 7, 10, 40, 0, 0, time.UTC),
+				time.Date(2026, 1, 7, 11, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 7, 11, 20, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "step from a value",
+			expr: "10/25 * * * *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 7, 10, 35, 0, 0, time.UTC),
+				time.Date(2026, 1, 7, 11, 10, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "step over a range",
+			expr: "0 8-18/4 * * *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 7, 12, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 7, 16, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 8, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "day of month or day of week",
+			expr: "0 0 10 * 1",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "day of week only",
+			expr: "0 0 * * 1",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "day of month only",
+			expr: "0 0 10 * *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "7 is Sunday",
+			expr: "0 0 * * 7",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "0 is Sunday",
+			expr: "0 0 * * 0",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "weekdays",
+			expr: "0 9 * * 1-5",
+			from: time.Date(2026, 1, 9, 10, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 12, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 13, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "month",
+			expr: "0 0 1 3,6 *",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: from,
+			want: []time.Time{
+				time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "never",
+			expr: "0 0 30 2 *",
+			from: from,
+			want: []time.Time{{}},
+		},
+		{
+			name: "shorthand",
+			expr: "@monthly",
+			from: from,
+			want: []time.Time{
+				time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "location",
+			expr: "0 9 * * *",
+			from: time.Date(2026, 1, 7, 3, 0, 0, 0, time.FixedZone("WIB", 7*60*60)),
+			want: []time.Time{
+				time.Date(2026, 1, 7, 9, 0, 0, 0, time.FixedZone("WIB", 7*60*60)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+			}
+
+			next := tt.from
+			for _, want := range tt.want {
+				next = cron.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("Next() = %v, want %v", next, want)
+				}
+			}
+		})
+	}
+}
@@ -22,11 +22,74 @@ func SetupMySQL() {
 		GetEnv("DB_NAME", ""),
 	)
 
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+		TranslateError: true,
+	})
 	if err != nil {
 		log.Fatal("failed to connect database: ", err)
 	}
 	logrus.Info("successfully connect to database...")
 
-	DB.AutoMigrate(&models.Transaction{})
+	err = migrateTransactionReferences(DB)
+	if err != nil {
+		log.Fatal("failed to migrate transaction references: ", err)
+	}
+
+	err = DB.AutoMigrate(&models.Transaction{}, &models.IdempotencyKey{}, &models.BalanceOperation{}, &models.NotificationOutbox{}, &models.TransactionStatusHistory{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.EventOutbox{}, &models.ScheduledTransaction{}, &models.ScheduledTransactionRun{}, &models.UserTransactionLimit{}, &models.TransactionLimitLock{}, &models.FraudEvaluation{}, &models.TransactionBatch{}, &models.TransactionBatchItem{})
+	if err != nil {
+		log.Fatal("failed to migrate database: ", err)
+	}
+}
+
+// migrateTransactionReferences prepares a database from before references
+// were unique for the unique index. Legacy refunds, all referenced
+// REFUND-<purchase>, get their purchase as parent_reference, and of each set
+// of rows sharing a reference the oldest keeps it while the others are
+// renamed <reference>-DUP<id> and logged for review.
+func migrateTransactionReferences(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&models.Transaction{}) {
+		return nil
+	}
+	if !m.HasColumn(&models.Transaction{}, "ParentReference") {
+		err := m.AddColumn(&models.Transaction{}, "ParentReference")
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Transaction{}).
+			Where("transaction_type = ? AND reference LIKE ? AND (parent_reference IS NULL OR parent_reference = '')", "REFUND", "REFUND-%").
+			UpdateColumn("parent_reference", gorm.Expr("SUBSTRING(reference, 8)")).Error
+		if err != nil {
+			return err
+		}
+		if m.HasIndex(&models.Transaction{}, "Reference") {
+			return nil
+		}
+
+		var duplicates []string
+		err = tx.Model(&models.Transaction{}).Group("reference").Having("COUNT(*) > 1").Pluck("reference", &duplicates).Error
+		if err != nil {
+			return err
+		}
+
+		for _, reference := range duplicates {
+			var ids []int
+			err = tx.Model(&models.Transaction{}).Where("reference = ?", reference).Order("id ASC").Pluck("id", &ids).Error
+			if err != nil {
+				return err
+			}
+			for _, id := range ids[1:] {
+				renamed := fmt.Sprintf("%s-DUP%d", reference, id)
+				err = tx.Model(&models.Transaction{}).Where("id = ?", id).UpdateColumn("reference", renamed).Error
+				if err != nil {
+					return err
+				}
+				logrus.Warnf("transaction %d shared reference %s, renamed to %s", id, reference, renamed)
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	referenceTimeFormat   = "20060102150405.000"
+	referenceMaxNodeID    = 999
+	referenceMaxSequence  = 9999
+	referenceNodeIDEnvKey = "REFERENCE_NODE_ID"
+)
+
+// ReferenceGenerator makes fixed width numeric references built from the
+// creation time in milliseconds, a node ID and a per millisecond sequence,
+// e.g. 20240102150405123 007 0001 (without the spaces). Since every part has
+// a fixed width, references sort in the order they were generated, and
+// nodes with different IDs never produce the same reference.
+type ReferenceGenerator struct {
+	NodeID     int
+	CheckDigit bool
+
+	mu       sync.Mutex
+	lastTime time.Time
+	sequence int
+}
+
+// NewReferenceGenerator reads the node ID from REFERENCE_NODE_ID, which
+// must be unique per running instance. "auto" derives it from the hostname,
+// which can give two instances the same ID and is only meant for a single
+// instance or development.
+func NewReferenceGenerator() (*ReferenceGenerator, error) {
+	checkDigit, err := strconv.ParseBool(GetEnv("REFERENCE_CHECK_DIGIT", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REFERENCE_CHECK_DIGIT: %w", err)
+	}
+
+	nodeID, err := referenceNodeID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReferenceGenerator{
+		NodeID:     nodeID,
+		CheckDigit: checkDigit,
+	}, nil
+}
+
+func referenceNodeID() (int, error) {
+	value := GetEnv(referenceNodeIDEnvKey, "")
+	if value == "" {
+		return 0, fmt.Errorf("%s is required, set it to a unique 0-%d per instance or to auto for a single instance", referenceNodeIDEnvKey, referenceMaxNodeID)
+	}
+	if value != "auto" {
+		nodeID, err := strconv.Atoi(value)
+		if err != nil || nodeID < 0 || nodeID > referenceMaxNodeID {
+			return 0, fmt.Errorf("invalid %s %q, expected 0-%d or auto", referenceNodeIDEnvKey, value, referenceMaxNodeID)
+		}
+		return nodeID, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get hostname for reference node ID: %w", err)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	return int(h.Sum32() % (referenceMaxNodeID + 1)), nil
+}
+
+func (g *ReferenceGenerator) Generate() string {
+	g.mu.Lock()
+	now := time.Now().Truncate(time.Millisecond)
+	// never go back in time, a clock adjustment would otherwise break the
+	// ordering and could repeat references
+	if !now.After(g.lastTime) {
+		now = g.lastTime
+		g.sequence++
+		if g.sequence > referenceMaxSequence {
+			now = now.Add(time.Millisecond)
+			g.sequence = 0
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTime = now
+	sequence := g.sequence
+	g.mu.Unlock()
+
+	formatted := now.Format(referenceTimeFormat)
+	reference := fmt.Sprintf("%s%s%03d%04d", formatted[:14], formatted[15:], g.NodeID, sequence)
+	if g.CheckDigit {
+		reference += strconv.Itoa(luhnCheckDigit(reference))
+	}
+	return reference
+}
+
+// luhnCheckDigit returns the digit that makes digits followed by it pass the
+// Luhn check.
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchBodySize caps uploads at about 1 KB per item.
+const maxBatchBodySize = constants.MaxBatchItems << 10
+
+// batchCSVColumns are the columns a CSV upload may have, in any order. Only
+// amount, transaction_type and description are required.
+var batchCSVColumns = map[string]bool{
+	"user_id":           true,
+	"transaction_type":  true,
+	"amount":            true,
+	"currency":          true,
+	"description":       true,
+	"additional_info":   true,
+	"channel":           true,
+	"recipient_user_id": true,
+	"bank_code":         true,
+	"account_number":    true,
+	"account_name":      true,
+}
+
+type TransactionBatchAPI struct {
+	TransactionBatchService interfaces.ITransactionBatchService
+}
+
+// CreateBatch takes a JSON array of transactions, or a CSV file sent as the
+// body or as the "file" field of a form. The mode query parameter is
+// ALL_OR_NOTHING or BEST_EFFORT, the default. Small batches are answered
+// with their result, larger ones with 202 and the batch_id to follow them.
+func (api *TransactionBatchAPI) CreateBatch(c *gin.Context) {
+	var (
+		log = helpers.Logger
+		req = models.CreateTransactionBatch{
+			Mode: strings.ToUpper(c.DefaultQuery("mode", constants.BatchModeBestEffort)),
+		}
+		err error
+	)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodySize)
+
+	switch c.ContentType() {
+	case "application/json":
+		req.Items, err = parseBatchJSON(c.Request.Body)
+	case "text/csv":
+		req.Items, err = parseBatchCSV(c.Request.Body)
+	case "multipart/form-data":
+		file, _, ferr := c.Request.FormFile("file")
+		if ferr != nil {
+			err = ferr
+			break
+		}
+		defer file.Close()
+		req.Items, err = parseBatchCSV(file)
+	default:
+		err = fmt.Errorf("unsupported content type %q", c.ContentType())
+	}
+	if err != nil {
+		log.Error("failed to parse request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.TransactionBatchService.CreateBatch(c.Request.Context(), tokenData, req)
+	if err != nil {
+		log.Error("failed to create transaction batch: ", err)
+		if errors.Is(err, constants.ErrInvalidBatch) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	if resp.Status == constants.BatchStatusPending {
+		helpers.SendResponseHTTP(c, http.StatusAccepted, constants.SuccessMessage, resp)
+		return
+	}
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *TransactionBatchAPI) GetBatch(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.TransactionBatchService.GetBatch(c.Request.Context(), tokenData, c.Param("batch_id"))
+	if err != nil {
+		if errors.Is(err, constants.ErrBatchNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		log.Error("failed to get transaction batch: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+// parseBatchJSON reads a JSON array of transactions. An element that does
+// not fit a transaction fails only its own item.
+func parseBatchJSON(r io.Reader) ([]models.TransactionBatchItem, error) {
+	var elements []json.RawMessage
+	err := json.NewDecoder(r).Decode(&elements)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]models.TransactionBatchItem, len(elements))
+	for i, element := range elements {
+		items[i].Line = i + 1
+		err = json.Unmarshal(element, &items[i].Request)
+		if err != nil {
+			items[i].Error = fmt.Sprintf("%s: %v", constants.ErrFailedBadRequest, err)
+		}
+	}
+	return items, nil
+}
+
+// parseBatchCSV reads a CSV file with a header row. A row with a value that
+// cannot be parsed fails only its own item, an unknown column the upload.
+func parseBatchCSV(r io.Reader) ([]models.TransactionBatchItem, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !batchCSVColumns[column] {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		header[i] = column
+	}
+
+	var items []models.TransactionBatchItem
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item := models.TransactionBatchItem{Line: len(items) + 1}
+		row := map[string]string{}
+		for i, column := range header {
+			row[column] = strings.TrimSpace(record[i])
+		}
+		err = parseBatchCSVRow(row, &item.Request)
+		if err != nil {
+			item.Error = fmt.Sprintf("%s: %v", constants.ErrFailedBadRequest, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func parseBatchCSVRow(row map[string]string, trx *models.Transaction) error {
+	var err error
+
+	if row["user_id"] != "" {
+		trx.UserID, err = strconv.Atoi(row["user_id"])
+		if err != nil {
+			return fmt.Errorf("invalid user_id %q", row["user_id"])
+		}
+	}
+	if row["recipient_user_id"] != "" {
+		trx.RecipientUserID, err = strconv.Atoi(row["recipient_user_id"])
+		if err != nil {
+			return fmt.Errorf("invalid recipient_user_id %q", row["recipient_user_id"])
+		}
+	}
+	trx.Amount, err = models.ParseMoney(row["amount"])
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", row["amount"])
+	}
+
+	trx.TransactionType = strings.ToUpper(row["transaction_type"])
+	trx.Currency = strings.ToUpper(row["currency"])
+	trx.Description = row["description"]
+	trx.AddtionalInfo = row["additional_info"]
+	trx.Channel = row["channel"]
+
+	if row["bank_code"] != "" || row["account_number"] != "" || row["account_name"] != "" {
+		trx.BankAccount = &models.BankAccount{
+			BankCode:      row["bank_code"],
+			AccountNumber: row["account_number"],
+			AccountName:   row["account_name"],
+		}
+	}
+	return nil
+}
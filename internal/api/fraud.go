@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FraudAPI struct {
+	FraudService       interfaces.IFraudService
+	TransactionService interfaces.ITransactionService
+}
+
+func (api *FraudAPI) GetPendingReviews(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		log.Error("invalid limit: ", c.Query("limit"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		log.Error("invalid offset: ", c.Query("offset"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	resp, err := api.FraudService.GetPendingReviews(c.Request.Context(), limit, offset)
+	if err != nil {
+		log.Error("failed to get pending reviews: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *FraudAPI) GetFraudEvaluations(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	resp, err := api.FraudService.GetFraudEvaluations(c.Request.Context(), c.Param("reference"))
+	if err != nil {
+		log.Error("failed to get fraud evaluations: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *FraudAPI) ApproveTransaction(c *gin.Context) {
+	api.reviewTransaction(c, true)
+}
+
+func (api *FraudAPI) RejectTransaction(c *gin.Context) {
+	api.reviewTransaction(c, false)
+}
+
+// reviewTransaction approves or rejects the held transaction in the path.
+func (api *FraudAPI) reviewTransaction(c *gin.Context, approve bool) {
+	var (
+		log = helpers.Logger
+		req models.ReviewTransaction
+	)
+
+	// the note is optional, so is the body
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Error("failed to parse request: ", err)
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.TransactionService.ReviewTransaction(c.Request.Context(), tokenData, c.Param("reference"), approve, req.Note)
+	if err != nil {
+		log.Error("failed to review transaction: ", err)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			helpers.SendResponseHTTP(c, http.StatusAccepted, constants.ErrBalanceOperationPending.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrFraudReviewNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		var limitExceeded *models.LimitExceededError
+		if errors.As(err, &limitExceeded) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), limitExceeded)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
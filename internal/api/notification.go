@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationAPI struct {
+	NotificationService interfaces.INotificationService
+}
+
+func (api *NotificationAPI) GetDeadLetters(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		log.Error("invalid limit: ", c.Query("limit"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		log.Error("invalid offset: ", c.Query("offset"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	resp, err := api.NotificationService.GetDeadLetters(c.Request.Context(), limit, offset)
+	if err != nil {
+		log.Error("failed to get dead letters: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *NotificationAPI) RedriveDeadLetter(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Error("invalid id: ", c.Param("id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	err = api.NotificationService.RedriveDeadLetter(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, constants.ErrDeadLetterNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		log.Error("failed to redrive dead letter: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, nil)
+}
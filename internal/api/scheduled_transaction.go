@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduledTransactionAPI struct {
+	ScheduledTransactionService interfaces.IScheduledTransactionService
+}
+
+func (api *ScheduledTransactionAPI) CreateSchedule(c *gin.Context) {
+	var (
+		log = helpers.Logger
+		req models.CreateScheduledTransaction
+	)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("failed to parse request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.ScheduledTransactionService.CreateSchedule(c.Request.Context(), tokenData, req)
+	if err != nil {
+		log.Error("failed to create scheduled transaction: ", err)
+		if errors.Is(err, constants.ErrInvalidSchedule) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusCreated, constants.SuccessMessage, resp)
+}
+
+func (api *ScheduledTransactionAPI) GetSchedules(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.ScheduledTransactionService.GetSchedules(c.Request.Context(), tokenData)
+	if err != nil {
+		log.Error("failed to get scheduled transactions: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *ScheduledTransactionAPI) GetSchedule(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Error("invalid id: ", c.Param("id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.ScheduledTransactionService.GetSchedule(c.Request.Context(), tokenData, id)
+	if err != nil {
+		if errors.Is(err, constants.ErrScheduleNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		log.Error("failed to get scheduled transaction: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *ScheduledTransactionAPI) GetScheduleRuns(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Error("invalid id: ", c.Param("id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		log.Error("invalid limit: ", c.Query("limit"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		log.Error("invalid offset: ", c.Query("offset"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.ScheduledTransactionService.GetScheduleRuns(c.Request.Context(), tokenData, id, limit, offset)
+	if err != nil {
+		if errors.Is(err, constants.ErrScheduleNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		log.Error("failed to get scheduled transaction runs: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *ScheduledTransactionAPI) PauseSchedule(c *gin.Context) {
+	api.changeSchedule(c, "pause", api.ScheduledTransactionService.PauseSchedule)
+}
+
+func (api *ScheduledTransactionAPI) ResumeSchedule(c *gin.Context) {
+	api.changeSchedule(c, "resume", api.ScheduledTransactionService.ResumeSchedule)
+}
+
+func (api *ScheduledTransactionAPI) CancelSchedule(c *gin.Context) {
+	api.changeSchedule(c, "cancel", api.ScheduledTransactionService.CancelSchedule)
+}
+
+// changeSchedule runs one of the status changes of the schedule in the path.
+func (api *ScheduledTransactionAPI) changeSchedule(c *gin.Context, action string, change func(ctx context.Context, tokenData models.TokenData, id int) error) {
+	var (
+		log = helpers.Logger
+	)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Error("invalid id: ", c.Param("id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	err = change(c.Request.Context(), tokenData, id)
+	if err != nil {
+		if errors.Is(err, constants.ErrScheduleNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrScheduleStatus) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		log.Errorf("failed to %s scheduled transaction: %v", action, err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, nil)
+}
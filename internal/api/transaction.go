@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"ewallet-transaction/constants"
 	"ewallet-transaction/helpers"
 	"ewallet-transaction/internal/interfaces"
@@ -54,10 +55,30 @@ func (api *TransactionAPI) CreateTransaction(c *gin.Context) {
 	req.UserID = int(tokenData.UserID)
 	req.CreatedBy = tokenData.Username
 	req.UpdatedBy = tokenData.Username
+	req.UserEmail = tokenData.Email
+	req.UserFullName = tokenData.FullName
 
-	resp, err := api.TransactionService.CreateTransaction(c.Request.Context(), &req)
+	resp, err := api.TransactionService.CreateTransaction(c.Request.Context(), tokenData, &req)
 	if err != nil {
 		log.Error("failed to create transaction: ", err)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			helpers.SendResponseHTTP(c, http.StatusAccepted, constants.ErrBalanceOperationPending.Error(), resp)
+			return
+		}
+		if errors.Is(err, constants.ErrInvalidTransferRecipient) || errors.Is(err, constants.ErrInvalidBankAccount) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		var limitExceeded *models.LimitExceededError
+		if errors.As(err, &limitExceeded) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), limitExceeded)
+			return
+		}
 		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
 		return
 	}
@@ -101,6 +122,23 @@ func (api *TransactionAPI) UpdateStatusTransaction(c *gin.Context) {
 	err := api.TransactionService.UpdateStatusTransaction(c.Request.Context(), tokenData, &req)
 	if err != nil {
 		log.Error("failed to update transaction: ", err)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			helpers.SendResponseHTTP(c, http.StatusAccepted, constants.ErrBalanceOperationPending.Error(), nil)
+			return
+		}
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrTransactionForbidden) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrTransactionUnderReview) || errors.Is(err, constants.ErrTransactionStatusChanged) {
+			helpers.SendResponseHTTP(c, http.StatusConflict, err.Error(), nil)
+			return
+		}
 		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
 		return
 	}
@@ -110,9 +148,21 @@ func (api *TransactionAPI) UpdateStatusTransaction(c *gin.Context) {
 
 func (api *TransactionAPI) GetTransaction(c *gin.Context) {
 	var (
-		log = helpers.Logger
+		log    = helpers.Logger
+		filter models.TransactionFilter
 	)
 
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		log.Error("failed to parse request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
 	token, ok := c.Get("token")
 	if !ok {
 		log.Error("failed to get token")
@@ -127,9 +177,15 @@ func (api *TransactionAPI) GetTransaction(c *gin.Context) {
 		return
 	}
 
-	resp, err := api.TransactionService.GetTransaction(c.Request.Context(), int(tokenData.UserID))
+	filter.UserID = int(tokenData.UserID)
+
+	resp, err := api.TransactionService.GetTransaction(c.Request.Context(), filter)
 	if err != nil {
 		log.Error("failed to get transaction: ", err)
+		if errors.Is(err, constants.ErrInvalidTransactionFilter) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
 		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
 		return
 	}
@@ -157,16 +213,60 @@ func (api *TransactionAPI) GetTransactionDetail(c *gin.Context) {
 		return
 	}
 
-	_, ok = token.(models.TokenData)
+	tokenData, ok := token.(models.TokenData)
 	if !ok {
 		log.Error("failed to parse token data")
 		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
 		return
 	}
 
-	resp, err := api.TransactionService.GetTransactionDetail(c.Request.Context(), reference)
+	resp, err := api.TransactionService.GetTransactionDetail(c.Request.Context(), tokenData, reference)
 	if err != nil {
 		log.Error("failed to get transaction detail: ", err)
+		if errors.Is(err, constants.ErrTransactionForbidden) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, err.Error(), nil)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *TransactionAPI) GetTransactionStatusHistory(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	reference := c.Param("reference")
+	if reference == "" {
+		log.Error("failed to get reference")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	token, ok := c.Get("token")
+	if !ok {
+		log.Error("failed to get token")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	tokenData, ok := token.(models.TokenData)
+	if !ok {
+		log.Error("failed to parse token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.TransactionService.GetTransactionStatusHistory(c.Request.Context(), tokenData, reference)
+	if err != nil {
+		log.Error("failed to get transaction status history: ", err)
+		if errors.Is(err, constants.ErrTransactionForbidden) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, err.Error(), nil)
+			return
+		}
 		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
 		return
 	}
@@ -208,6 +308,23 @@ func (api *TransactionAPI) RefundTransaction(c *gin.Context) {
 	resp, err := api.TransactionService.RefundTransaction(c.Request.Context(), &tokenData, &req)
 	if err != nil {
 		log.Error("failed to refund transaction: ", err)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			helpers.SendResponseHTTP(c, http.StatusAccepted, constants.ErrBalanceOperationPending.Error(), resp)
+			return
+		}
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrRefundAmountExceeded) || errors.Is(err, constants.ErrRefundAmountPrecision) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrTransactionForbidden) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, err.Error(), nil)
+			return
+		}
 		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
 		return
 	}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is how many rows are buffered before they are sent.
+const exportFlushRows = 500
+
+// ExportTransactions streams the transactions the list would return, with
+// the same filters, as CSV or, with format=ndjson, one JSON object per line.
+// Operators and the system may export the history of another user with
+// user_id.
+func (api *TransactionAPI) ExportTransactions(c *gin.Context) {
+	var (
+		log    = helpers.Logger
+		filter models.TransactionFilter
+	)
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		log.Error("failed to parse request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "ndjson" {
+		log.Error("invalid export format: ", format)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	filter.UserID = int(tokenData.UserID)
+	if c.Query("user_id") != "" {
+		userID, err := strconv.Atoi(c.Query("user_id"))
+		if err != nil {
+			log.Error("invalid user_id: ", c.Query("user_id"))
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+			return
+		}
+		if userID != filter.UserID && !tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, constants.ErrTransactionForbidden.Error(), nil)
+			return
+		}
+		filter.UserID = userID
+	}
+
+	w := &transactionExportWriter{c: c, format: format}
+	err := api.TransactionService.ExportTransactions(c.Request.Context(), filter, w.Write)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Error("failed to export transactions: ", err)
+		// once rows are sent the status cannot change, the export is cut off
+		if w.started {
+			c.Abort()
+			return
+		}
+		if errors.Is(err, constants.ErrInvalidTransactionFilter) || errors.Is(err, constants.ErrExportTooLarge) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+	}
+}
+
+// transactionExportWriter writes the response of an export. The headers are
+// only sent with the first row, so a filter rejected before any row is read
+// still gets an error response.
+type transactionExportWriter struct {
+	c       *gin.Context
+	format  string
+	started bool
+	rows    int
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+func (w *transactionExportWriter) start() error {
+	w.started = true
+
+	contentType := "text/csv; charset=utf-8"
+	if w.format == "ndjson" {
+		contentType = "application/x-ndjson"
+	}
+	w.c.Header("Content-Type", contentType)
+	w.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions-%s.%s"`, time.Now().Format("20060102"), w.format))
+	w.c.Status(http.StatusOK)
+
+	if w.format == "ndjson" {
+		w.json = json.NewEncoder(w.c.Writer)
+		return nil
+	}
+	w.csv = csv.NewWriter(w.c.Writer)
+	return w.csv.Write(models.TransactionExportColumns)
+}
+
+func (w *transactionExportWriter) Write(trx models.Transaction) error {
+	if !w.started {
+		err := w.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	export := models.NewTransactionExport(trx)
+	if w.format == "ndjson" {
+		err = w.json.Encode(export)
+	} else {
+		err = w.csv.Write(export.CSVRecord())
+	}
+	if err != nil {
+		return err
+	}
+
+	w.rows++
+	if w.rows%exportFlushRows == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close sends what is left, or the CSV header alone when nothing matched.
+func (w *transactionExportWriter) Close() error {
+	if !w.started {
+		err := w.start()
+		if err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
+
+func (w *transactionExportWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	w.c.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,384 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external/proto/transaction"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"time"
+)
+
+type TransactionGrpcAPI struct {
+	TransactionService interfaces.ITransactionService
+	transaction.UnimplementedTransactionServiceServer
+}
+
+func (api *TransactionGrpcAPI) CreateTransaction(ctx context.Context, req *transaction.CreateTransactionRequest) (*transaction.CreateTransactionResponse, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(ctx)
+	if !ok {
+		log.Error("failed to get token data")
+		return &transaction.CreateTransactionResponse{Message: constants.ErrUnauthorized}, nil
+	}
+
+	amount, err := models.ParseMoney(req.Amount)
+	if err != nil {
+		log.Error("failed to parse amount: ", err)
+		return &transaction.CreateTransactionResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	trx := models.Transaction{
+		Amount:          amount,
+		Currency:        req.Currency,
+		TransactionType: req.TransactionType,
+		Description:     req.Description,
+		AddtionalInfo:   req.AdditionalInfo,
+		RecipientUserID: int(req.RecipientUserId),
+		BankAccount:     bankAccountModel(req.BankAccount),
+		Channel:         req.Channel,
+	}
+	if err := trx.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		return &transaction.CreateTransactionResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	if !constants.MapTransactionType[trx.TransactionType] {
+		log.Error("invalid transaction type")
+		return &transaction.CreateTransactionResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	trx.UserID = int(tokenData.UserID)
+	trx.CreatedBy = tokenData.Username
+	trx.UpdatedBy = tokenData.Username
+	trx.UserEmail = tokenData.Email
+	trx.UserFullName = tokenData.FullName
+
+	resp, err := api.TransactionService.CreateTransaction(ctx, tokenData, &trx)
+	if err != nil {
+		log.Error("failed to create transaction: ", err)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			return &transaction.CreateTransactionResponse{
+				Message: constants.ErrBalanceOperationPending.Error(),
+				Data: &transaction.CreateTransactionData{
+					Reference:         resp.Reference,
+					TransactionStatus: resp.TransactionStatus,
+					FeeAmount:         resp.FeeAmount.String(),
+				},
+			}, nil
+		}
+		if errors.Is(err, constants.ErrInvalidTransferRecipient) || errors.Is(err, constants.ErrInvalidBankAccount) {
+			return &transaction.CreateTransactionResponse{Message: err.Error()}, nil
+		}
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			return &transaction.CreateTransactionResponse{Message: err.Error()}, nil
+		}
+		var limitExceeded *models.LimitExceededError
+		if errors.As(err, &limitExceeded) {
+			return &transaction.CreateTransactionResponse{Message: err.Error(), ErrorCode: limitExceeded.Code}, nil
+		}
+		return &transaction.CreateTransactionResponse{Message: constants.ErrServerError}, nil
+	}
+
+	return &transaction.CreateTransactionResponse{
+		Message: constants.SuccessMessage,
+		Data: &transaction.CreateTransactionData{
+			Reference:         resp.Reference,
+			TransactionStatus: resp.TransactionStatus,
+			FeeAmount:         resp.FeeAmount.String(),
+		},
+	}, nil
+}
+
+func (api *TransactionGrpcAPI) UpdateStatusTransaction(ctx context.Context, req *transaction.UpdateStatusTransactionRequest) (*transaction.UpdateStatusTransactionResponse, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(ctx)
+	if !ok {
+		log.Error("failed to get token data")
+		return &transaction.UpdateStatusTransactionResponse{Message: constants.ErrUnauthorized}, nil
+	}
+
+	updateReq := models.UpdateStatusTransaction{
+		Reference:         req.Reference,
+		TransactionStatus: req.TransactionStatus,
+		AddtionalInfo:     req.AdditionalInfo,
+	}
+	if err := updateReq.Validate(); err != nil || updateReq.Reference == "" {
+		log.Error("failed to validate request: ", err)
+		return &transaction.UpdateStatusTransactionResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	err := api.TransactionService.UpdateStatusTransaction(ctx, tokenData, &updateReq)
+	if err != nil {
+		log.Error("failed to update transaction: ", err)
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			return &transaction.UpdateStatusTransactionResponse{Message: err.Error()}, nil
+		}
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			return &transaction.UpdateStatusTransactionResponse{Message: constants.ErrBalanceOperationPending.Error()}, nil
+		}
+		if errors.Is(err, constants.ErrTransactionForbidden) || errors.Is(err, constants.ErrTransactionUnderReview) || errors.Is(err, constants.ErrTransactionStatusChanged) {
+			return &transaction.UpdateStatusTransactionResponse{Message: err.Error()}, nil
+		}
+		return &transaction.UpdateStatusTransactionResponse{Message: constants.ErrServerError}, nil
+	}
+
+	return &transaction.UpdateStatusTransactionResponse{Message: constants.SuccessMessage}, nil
+}
+
+func (api *TransactionGrpcAPI) GetTransaction(ctx context.Context, req *transaction.GetTransactionRequest) (*transaction.GetTransactionResponse, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(ctx)
+	if !ok {
+		log.Error("failed to get token data")
+		return &transaction.GetTransactionResponse{Message: constants.ErrUnauthorized}, nil
+	}
+
+	filter := models.TransactionFilter{
+		UserID:            int(tokenData.UserID),
+		TransactionType:   req.TransactionType,
+		TransactionStatus: req.TransactionStatus,
+		Currency:          req.Currency,
+		Sort:              req.Sort,
+		Cursor:            req.Cursor,
+		Limit:             int(req.Limit),
+	}
+
+	err := filter.MinAmount.UnmarshalParam(req.MinAmount)
+	if err == nil {
+		err = filter.MaxAmount.UnmarshalParam(req.MaxAmount)
+	}
+	if err == nil && req.StartDate != "" {
+		filter.StartDate, err = time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	}
+	if err == nil && req.EndDate != "" {
+		filter.EndDate, err = time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	}
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		log.Error("failed to validate request: ", err)
+		return &transaction.GetTransactionResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	resp, err := api.TransactionService.GetTransaction(ctx, filter)
+	if err != nil {
+		log.Error("failed to get transaction: ", err)
+		if errors.Is(err, constants.ErrInvalidTransactionFilter) {
+			return &transaction.GetTransactionResponse{Message: err.Error()}, nil
+		}
+		return &transaction.GetTransactionResponse{Message: constants.ErrServerError}, nil
+	}
+
+	data := make([]*transaction.Transaction, 0, len(resp.Transactions))
+	for i := range resp.Transactions {
+		data = append(data, toTransactionProto(resp.Transactions[i]))
+	}
+
+	return &transaction.GetTransactionResponse{
+		Message:    constants.SuccessMessage,
+		Data:       data,
+		NextCursor: resp.NextCursor,
+	}, nil
+}
+
+func (api *TransactionGrpcAPI) GetTransactionDetail(ctx context.Context, req *transaction.GetTransactionDetailRequest) (*transaction.GetTransactionDetailResponse, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(ctx)
+	if !ok {
+		log.Error("failed to get token data")
+		return &transaction.GetTransactionDetailResponse{Message: constants.ErrUnauthorized}, nil
+	}
+
+	if req.Reference == "" {
+		log.Error("failed to get reference")
+		return &transaction.GetTransactionDetailResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	resp, err := api.TransactionService.GetTransactionDetail(ctx, tokenData, req.Reference)
+	if err != nil {
+		log.Error("failed to get transaction detail: ", err)
+		if errors.Is(err, constants.ErrTransactionForbidden) {
+			return &transaction.GetTransactionDetailResponse{Message: err.Error()}, nil
+		}
+		return &transaction.GetTransactionDetailResponse{Message: constants.ErrServerError}, nil
+	}
+
+	return &transaction.GetTransactionDetailResponse{
+		Message: constants.SuccessMessage,
+		Data:    toTransactionProto(resp),
+	}, nil
+}
+
+func (api *TransactionGrpcAPI) GetTransactionStatusHistory(ctx context.Context, req *transaction.GetTransactionDetailRequest) (*transaction.GetTransactionStatusHistoryResponse, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(ctx)
+	if !ok {
+		log.Error("failed to get token data")
+		return &transaction.GetTransactionStatusHistoryResponse{Message: constants.ErrUnauthorized}, nil
+	}
+
+	if req.Reference == "" {
+		log.Error("failed to get reference")
+		return &transaction.GetTransactionStatusHistoryResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	resp, err := api.TransactionService.GetTransactionStatusHistory(ctx, tokenData, req.Reference)
+	if err != nil {
+		log.Error("failed to get transaction status history: ", err)
+		if errors.Is(err, constants.ErrTransactionForbidden) {
+			return &transaction.GetTransactionStatusHistoryResponse{Message: err.Error()}, nil
+		}
+		return &transaction.GetTransactionStatusHistoryResponse{Message: constants.ErrServerError}, nil
+	}
+
+	data := make([]*transaction.TransactionStatusHistory, 0, len(resp))
+	for i := range resp {
+		data = append(data, &transaction.TransactionStatusHistory{
+			Id:                   int64(resp[i].ID),
+			TransactionReference: resp[i].TransactionReference,
+			FromStatus:           resp[i].FromStatus,
+			ToStatus:             resp[i].ToStatus,
+			ActorUserId:          int64(resp[i].ActorUserID),
+			Actor:                resp[i].Actor,
+			AdditionalInfo:       resp[i].AdditionalInfo,
+			Date:                 resp[i].CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return &transaction.GetTransactionStatusHistoryResponse{
+		Message: constants.SuccessMessage,
+		Data:    data,
+	}, nil
+}
+
+func (api *TransactionGrpcAPI) RefundTransaction(ctx context.Context, req *transaction.RefundTransactionRequest) (*transaction.CreateTransactionResponse, error) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(ctx)
+	if !ok {
+		log.Error("failed to get token data")
+		return &transaction.CreateTransactionResponse{Message: constants.ErrUnauthorized}, nil
+	}
+
+	refundReq := models.RefundTransaction{
+		Reference:     req.Reference,
+		Description:   req.Description,
+		AddtionalInfo: req.AdditionalInfo,
+	}
+	err := refundReq.Amount.UnmarshalParam(req.Amount)
+	if err == nil {
+		err = refundReq.Validate()
+	}
+	if err != nil {
+		log.Error("failed to validate request: ", err)
+		return &transaction.CreateTransactionResponse{Message: constants.ErrFailedBadRequest}, nil
+	}
+
+	resp, err := api.TransactionService.RefundTransaction(ctx, &tokenData, &refundReq)
+	if err != nil {
+		log.Error("failed to refund transaction: ", err)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			return &transaction.CreateTransactionResponse{
+				Message: constants.ErrBalanceOperationPending.Error(),
+				Data: &transaction.CreateTransactionData{
+					Reference:         resp.Reference,
+					TransactionStatus: resp.TransactionStatus,
+					FeeAmount:         resp.FeeAmount.String(),
+				},
+			}, nil
+		}
+		var invalidTransition *models.InvalidTransitionError
+		if errors.As(err, &invalidTransition) {
+			return &transaction.CreateTransactionResponse{Message: err.Error()}, nil
+		}
+		if errors.Is(err, constants.ErrRefundAmountExceeded) || errors.Is(err, constants.ErrRefundAmountPrecision) || errors.Is(err, constants.ErrTransactionForbidden) {
+			return &transaction.CreateTransactionResponse{Message: err.Error()}, nil
+		}
+		return &transaction.CreateTransactionResponse{Message: constants.ErrServerError}, nil
+	}
+
+	return &transaction.CreateTransactionResponse{
+		Message: constants.SuccessMessage,
+		Data: &transaction.CreateTransactionData{
+			Reference:         resp.Reference,
+			TransactionStatus: resp.TransactionStatus,
+			FeeAmount:         resp.FeeAmount.String(),
+		},
+	}, nil
+}
+
+func toTransactionProto(trx models.Transaction) *transaction.Transaction {
+	return &transaction.Transaction{
+		Id:                int64(trx.ID),
+		UserId:            int64(trx.UserID),
+		Amount:            trx.Amount.String(),
+		Currency:          trx.Currency,
+		TransactionType:   trx.TransactionType,
+		TransactionStatus: trx.TransactionStatus,
+		Reference:         trx.Reference,
+		Description:       trx.Description,
+		AdditionalInfo:    trx.AddtionalInfo,
+		Date:              trx.CreatedAt.Format(time.RFC3339),
+		ParentReference:   trx.ParentReference,
+		RefundedAmount:    moneyProto(trx.RefundedAmount),
+		RemainingAmount:   moneyProto(trx.RemainingAmount),
+		RecipientUserId:   int64(trx.RecipientUserID),
+		Direction:         trx.Direction,
+		BankAccount:       bankAccountProto(trx.BankAccount),
+		Channel:           trx.Channel,
+		FeeAmount:         trx.FeeAmount.String(),
+	}
+}
+
+func bankAccountModel(b *transaction.BankAccount) *models.BankAccount {
+	if b == nil {
+		return nil
+	}
+	return &models.BankAccount{
+		BankCode:      b.BankCode,
+		AccountNumber: b.AccountNumber,
+		AccountName:   b.AccountName,
+	}
+}
+
+func bankAccountProto(b *models.BankAccount) *transaction.BankAccount {
+	if b == nil {
+		return nil
+	}
+	return &transaction.BankAccount{
+		BankCode:      b.BankCode,
+		AccountNumber: b.AccountNumber,
+		AccountName:   b.AccountName,
+	}
+}
+
+func moneyProto(m *models.Money) *string {
+	if m == nil {
+		return nil
+	}
+	s := m.String()
+	return &s
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransactionLimitAPI struct {
+	TransactionLimitService interfaces.ITransactionLimitService
+}
+
+func (api *TransactionLimitAPI) GetUserLimits(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil || userID <= 0 {
+		log.Error("invalid user id: ", c.Param("user_id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	resp, err := api.TransactionLimitService.GetUserLimits(c.Request.Context(), userID)
+	if err != nil {
+		log.Error("failed to get user transaction limits: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *TransactionLimitAPI) SetUserLimit(c *gin.Context) {
+	var (
+		log = helpers.Logger
+		req models.TransactionLimit
+	)
+
+	userID, transactionType, ok := userLimitParams(c)
+	if !ok {
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("failed to parse request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.TransactionLimitService.SetUserLimit(c.Request.Context(), tokenData, userID, transactionType, req)
+	if err != nil {
+		log.Error("failed to set user transaction limit: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *TransactionLimitAPI) DeleteUserLimit(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	userID, transactionType, ok := userLimitParams(c)
+	if !ok {
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	err := api.TransactionLimitService.DeleteUserLimit(c.Request.Context(), userID, transactionType)
+	if err != nil {
+		log.Error("failed to delete user transaction limit: ", err)
+		if errors.Is(err, constants.ErrTransactionLimitNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, nil)
+}
+
+// userLimitParams reads the user and the transaction type of a user limit
+// from the path. Refunds are not created through the limited endpoint.
+func userLimitParams(c *gin.Context) (int, string, bool) {
+	log := helpers.Logger
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil || userID <= 0 {
+		log.Error("invalid user id: ", c.Param("user_id"))
+		return 0, "", false
+	}
+
+	transactionType := c.Param("transaction_type")
+	if !constants.MapTransactionType[transactionType] || transactionType == constants.TransactionTypeRefund {
+		log.Error("invalid transaction type: ", transactionType)
+		return 0, "", false
+	}
+
+	return userID, transactionType, true
+}
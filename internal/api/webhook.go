@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookAPI struct {
+	WebhookService interfaces.IWebhookService
+}
+
+func (api *WebhookAPI) CreateWebhook(c *gin.Context) {
+	var (
+		log = helpers.Logger
+		req models.CreateWebhook
+	)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("failed to parse request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		log.Error("failed to validate request: ", err)
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.WebhookService.CreateWebhook(c.Request.Context(), tokenData, req)
+	if err != nil {
+		log.Error("failed to create webhook: ", err)
+		if errors.Is(err, constants.ErrInvalidWebhookFilter) || errors.Is(err, constants.ErrInvalidWebhookURL) {
+			helpers.SendResponseHTTP(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, constants.ErrWebhookForbidden) {
+			helpers.SendResponseHTTP(c, http.StatusForbidden, err.Error(), nil)
+			return
+		}
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *WebhookAPI) GetWebhooks(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.WebhookService.GetWebhooks(c.Request.Context(), tokenData)
+	if err != nil {
+		log.Error("failed to get webhooks: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
+
+func (api *WebhookAPI) DeleteWebhook(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Error("invalid id: ", c.Param("id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	err = api.WebhookService.DeleteWebhook(c.Request.Context(), tokenData, id)
+	if err != nil {
+		if errors.Is(err, constants.ErrWebhookNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		log.Error("failed to delete webhook: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, nil)
+}
+
+func (api *WebhookAPI) GetWebhookDeliveries(c *gin.Context) {
+	var (
+		log = helpers.Logger
+	)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Error("invalid id: ", c.Param("id"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		log.Error("invalid limit: ", c.Query("limit"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		log.Error("invalid offset: ", c.Query("offset"))
+		helpers.SendResponseHTTP(c, http.StatusBadRequest, constants.ErrFailedBadRequest, nil)
+		return
+	}
+
+	tokenData, ok := models.TokenDataFromContext(c.Request.Context())
+	if !ok {
+		log.Error("failed to get token data")
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	resp, err := api.WebhookService.GetWebhookDeliveries(c.Request.Context(), tokenData, id, limit, offset)
+	if err != nil {
+		if errors.Is(err, constants.ErrWebhookNotFound) {
+			helpers.SendResponseHTTP(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		log.Error("failed to get webhook deliveries: ", err)
+		helpers.SendResponseHTTP(c, http.StatusInternalServerError, constants.ErrServerError, nil)
+		return
+	}
+
+	helpers.SendResponseHTTP(c, http.StatusOK, constants.SuccessMessage, resp)
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPPublisher posts each event as JSON to URL, with Token as the
+// Authorization header when set. The event is only taken as delivered when
+// the endpoint answers 2xx, consumers should drop repeats by Event-ID.
+type HTTPPublisher struct {
+	URL   string
+	Token string
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event models.Event) error {
+	timeout, err := time.ParseDuration(helpers.GetEnv("EVENT_RELAY_TIMEOUT", "10s"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse event relay timeout")
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return errors.Wrap(err, "failed to create new http request")
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Event-ID", event.ID)
+	httpReq.Header.Set("Event-Type", event.Type)
+	if p.Token != "" {
+		httpReq.Header.Set("Authorization", p.Token)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect event endpoint")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("got error response from event endpoint: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSubscribers is returned by Publish when no handler took the event, so
+// the relay keeps it instead of marking it published.
+var ErrNoSubscribers = errors.New("no subscribers for event")
+
+// EventHandler handles one event. Handlers may see the same event more than
+// once and should use Event.ID to drop repeats.
+type EventHandler func(ctx context.Context, event models.Event) error
+
+// InProcessPublisher hands events to the handlers subscribed in the same
+// process, in the order they subscribed. It is used as the target of the
+// outbox relay, so handlers only see committed changes. Publish returns the first handler
+// error, the remaining handlers still run.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{
+		handlers: map[string][]EventHandler{},
+	}
+}
+
+// Subscribe registers handler for eventType, or for every event type when
+// eventType is empty.
+func (p *InProcessPublisher) Subscribe(eventType string, handler EventHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[eventType] = append(p.handlers[eventType], handler)
+}
+
+func (p *InProcessPublisher) Publish(ctx context.Context, event models.Event) error {
+	p.mu.RLock()
+	handlers := append(append([]EventHandler{}, p.handlers[event.Type]...), p.handlers[""]...)
+	p.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return errors.Wrapf(ErrNoSubscribers, "%s event %s", event.Type, event.ID)
+	}
+
+	var firstErr error
+	for _, handler := range handlers {
+		err := handler(ctx, event)
+		if err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to handle %s event %s", event.Type, event.ID)
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OutboxPublisher writes events to the event outbox in the database
+// transaction of the change, so an event is stored if and only if the change
+// is committed. EventRelayService then forwards them to the real backend.
+type OutboxPublisher struct {
+	EventOutboxRepo interfaces.IEventOutboxRepo
+}
+
+func (p *OutboxPublisher) Publish(ctx context.Context, event models.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+
+	err = p.EventOutboxRepo.CreateEventOutbox(ctx, &models.EventOutbox{
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       string(payload),
+		Status:        constants.EventOutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to insert event outbox")
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+)
+
+type IBalanceOperationRepo interface {
+	CreateBalanceOperation(ctx context.Context, op *models.BalanceOperation) error
+	GetBalanceOperationsByTransactionReference(ctx context.Context, reference, action string) ([]models.BalanceOperation, error)
+	GetBalanceOperationsByChangeID(ctx context.Context, changeID string) ([]models.BalanceOperation, error)
+	GetBalanceOperationsByWalletReference(ctx context.Context, walletReference string) ([]models.BalanceOperation, error)
+	UpdateBalanceOperationStatus(ctx context.Context, id int, status, lastError string) error
+	GetUnfinishedBalanceOperations(ctx context.Context, statuses []string, before time.Time) ([]models.BalanceOperation, error)
+	ClaimBalanceOperation(ctx context.Context, op *models.BalanceOperation) (bool, error)
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+)
+
+// IEventPublisher is implemented by every event backend. The outbox
+// publisher is called inside the database transaction of the change, the
+// others by the relay once it is committed.
+type IEventPublisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+type IEventRelayService interface {
+	RelayEvents(ctx context.Context) error
+}
+
+type IEventOutboxRepo interface {
+	CreateEventOutbox(ctx context.Context, event *models.EventOutbox) error
+	GetDueEventOutbox(ctx context.Context, limit int) ([]models.EventOutbox, error)
+	ClaimEventOutbox(ctx context.Context, event *models.EventOutbox, lease time.Duration) (bool, error)
+	UpdateEventOutbox(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, lastError string) error
+}
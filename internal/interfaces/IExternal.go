@@ -8,7 +8,11 @@ import (
 
 type IExternal interface {
 	ValidateToken(ctx context.Context, token string) (models.TokenData, error)
+	GetUser(ctx context.Context, userID int) (external.User, error)
 	CreditBalance(ctx context.Context, token string, req external.UpdateBalance) (*external.UpdateBalanceResponse, error)
 	DebitBalance(ctx context.Context, token string, req external.UpdateBalance) (*external.UpdateBalanceResponse, error)
+	GetWalletHistory(ctx context.Context, token string, req external.WalletHistoryRequest) ([]external.WalletHistoryEntry, error)
 	SendNotification(ctx context.Context, recipient, templateName string, placeholder map[string]string) error
+	ValidateWebhookURL(ctx context.Context, rawURL string) error
+	SendWebhook(ctx context.Context, rawURL string, header map[string]string, payload []byte) (int, error)
 }
@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IFraudAPI interface {
+	GetPendingReviews(c *gin.Context)
+	GetFraudEvaluations(c *gin.Context)
+	ApproveTransaction(c *gin.Context)
+	RejectTransaction(c *gin.Context)
+}
+
+type IFraudService interface {
+	ReloadRules(ctx context.Context) error
+	Evaluate(ctx context.Context, trx models.Transaction, fromStatus, toStatus string) (*models.FraudEvaluation, error)
+	GetPendingReviews(ctx context.Context, limit, offset int) ([]models.FraudEvaluation, error)
+	GetFraudEvaluations(ctx context.Context, reference string) ([]models.FraudEvaluation, error)
+}
+
+type IFraudRepo interface {
+	CreateFraudEvaluation(ctx context.Context, evaluation *models.FraudEvaluation) error
+	GetFraudEvaluations(ctx context.Context, reference string) ([]models.FraudEvaluation, error)
+	GetPendingFraudReviews(ctx context.Context, limit, offset int) ([]models.FraudEvaluation, error)
+	GetPendingFraudReview(ctx context.Context, reference string) (models.FraudEvaluation, error)
+	ClaimFraudReview(ctx context.Context, id int, status, reviewedBy, note string) (bool, error)
+}
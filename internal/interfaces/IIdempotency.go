@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+)
+
+type IIdempotencyService interface {
+	Begin(ctx context.Context, userID int, idempotencyKey, requestHash string) (*models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key *models.IdempotencyKey, responseCode int, responseBody string) error
+	KeepAlive(ctx context.Context, key *models.IdempotencyKey) (stop func())
+	PurgeExpiredKeys(ctx context.Context) error
+}
+
+type IIdempotencyRepo interface {
+	CreateIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, userID int, idempotencyKey string) (models.IdempotencyKey, error)
+	UpdateIdempotencyResponse(ctx context.Context, id int, responseCode int, responseBody string) error
+	ClaimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey, lease time.Duration) (bool, error)
+	TakeOverExpiredIdempotencyKey(ctx context.Context, id int, key *models.IdempotencyKey) (bool, error)
+	ExtendIdempotencyLease(ctx context.Context, id int, lease time.Duration) error
+	DeleteIdempotencyKey(ctx context.Context, id int) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,29 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type INotificationAPI interface {
+	GetDeadLetters(c *gin.Context)
+	RedriveDeadLetter(c *gin.Context)
+}
+
+type INotificationService interface {
+	DispatchNotifications(ctx context.Context) error
+	GetDeadLetters(ctx context.Context, limit, offset int) ([]models.NotificationOutbox, error)
+	RedriveDeadLetter(ctx context.Context, id int) error
+}
+
+type INotificationOutboxRepo interface {
+	CreateNotificationOutbox(ctx context.Context, notification *models.NotificationOutbox) error
+	GetDueNotificationOutbox(ctx context.Context, limit int) ([]models.NotificationOutbox, error)
+	ClaimNotificationOutbox(ctx context.Context, notification *models.NotificationOutbox, lease time.Duration) (bool, error)
+	UpdateNotificationOutbox(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, lastError string) error
+	GetNotificationOutboxByStatus(ctx context.Context, status string, limit, offset int) ([]models.NotificationOutbox, error)
+	RedriveNotificationOutbox(ctx context.Context, id int) (bool, error)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+)
+
+type IReconciliationService interface {
+	Reconcile(ctx context.Context, start, end time.Time) (models.ReconciliationReport, error)
+	ReconcileRecent(ctx context.Context) error
+}
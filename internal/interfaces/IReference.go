@@ -0,0 +1,5 @@
+package interfaces
+
+type IReferenceGenerator interface {
+	Generate() string
+}
@@ -0,0 +1,41 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IScheduledTransactionAPI interface {
+	CreateSchedule(c *gin.Context)
+	GetSchedules(c *gin.Context)
+	GetSchedule(c *gin.Context)
+	GetScheduleRuns(c *gin.Context)
+	PauseSchedule(c *gin.Context)
+	ResumeSchedule(c *gin.Context)
+	CancelSchedule(c *gin.Context)
+}
+
+type IScheduledTransactionService interface {
+	CreateSchedule(ctx context.Context, tokenData models.TokenData, req models.CreateScheduledTransaction) (models.ScheduledTransaction, error)
+	GetSchedules(ctx context.Context, tokenData models.TokenData) ([]models.ScheduledTransaction, error)
+	GetSchedule(ctx context.Context, tokenData models.TokenData, id int) (models.ScheduledTransaction, error)
+	GetScheduleRuns(ctx context.Context, tokenData models.TokenData, id, limit, offset int) ([]models.ScheduledTransactionRun, error)
+	PauseSchedule(ctx context.Context, tokenData models.TokenData, id int) error
+	ResumeSchedule(ctx context.Context, tokenData models.TokenData, id int) error
+	CancelSchedule(ctx context.Context, tokenData models.TokenData, id int) error
+	RunDueSchedules(ctx context.Context) error
+}
+
+type IScheduledTransactionRepo interface {
+	CreateScheduledTransaction(ctx context.Context, schedule *models.ScheduledTransaction) error
+	GetScheduledTransaction(ctx context.Context, id int) (models.ScheduledTransaction, error)
+	GetScheduledTransactionsByUserID(ctx context.Context, userID int) ([]models.ScheduledTransaction, error)
+	UpdateScheduledTransactionStatus(ctx context.Context, id int, fromStatuses []string, status string, nextRunAt *time.Time, updatedBy string) (bool, error)
+	GetDueScheduledTransactions(ctx context.Context, now time.Time, limit int) ([]models.ScheduledTransaction, error)
+	ClaimScheduledTransaction(ctx context.Context, schedule *models.ScheduledTransaction, status string, nextRunAt *time.Time) (bool, error)
+	CreateScheduledTransactionRun(ctx context.Context, run *models.ScheduledTransactionRun) error
+	GetScheduledTransactionRuns(ctx context.Context, scheduleID, limit, offset int) ([]models.ScheduledTransactionRun, error)
+}
@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 	"ewallet-transaction/internal/models"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,21 +12,38 @@ type ITransactionAPI interface {
 	CreateTransaction(c *gin.Context)
 	UpdateStatusTransaction(c *gin.Context)
 	GetTransaction(c *gin.Context)
+	ExportTransactions(c *gin.Context)
 	GetTransactionDetail(c *gin.Context)
 	RefundTransaction(c *gin.Context)
+	GetTransactionStatusHistory(c *gin.Context)
 }
 
 type ITransactionService interface {
-	CreateTransaction(ctx context.Context, req *models.Transaction) (models.CreateTransactionResponse, error)
+	CreateTransaction(ctx context.Context, tokenData models.TokenData, req *models.Transaction) (models.CreateTransactionResponse, error)
 	UpdateStatusTransaction(ctx context.Context, tokenData models.TokenData, req *models.UpdateStatusTransaction) error
-	GetTransaction(ctx context.Context, userID int) ([]models.Transaction, error)
-	GetTransactionDetail(ctx context.Context, reference string) (models.Transaction, error)
+	GetTransaction(ctx context.Context, filter models.TransactionFilter) (models.TransactionList, error)
+	ExportTransactions(ctx context.Context, filter models.TransactionFilter, fn func(models.Transaction) error) error
+	GetTransactionDetail(ctx context.Context, tokenData models.TokenData, reference string) (models.Transaction, error)
 	RefundTransaction(ctx context.Context, tokenData *models.TokenData, req *models.RefundTransaction) (models.CreateTransactionResponse, error)
+	RecoverBalanceOperations(ctx context.Context) error
+	ExpirePendingTransactions(ctx context.Context) error
+	GetTransactionStatusHistory(ctx context.Context, tokenData models.TokenData, reference string) ([]models.TransactionStatusHistory, error)
+	ReviewTransaction(ctx context.Context, tokenData models.TokenData, reference string, approve bool, note string) (models.CreateTransactionResponse, error)
 }
 
 type ITransactionRepo interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	CreateTransaction(ctx context.Context, trx *models.Transaction) error
 	GetTransactionByReference(ctx context.Context, reference string, includeRefund bool) (models.Transaction, error)
-	UpdateStatusTransaction(ctx context.Context, reference, status, additional_info string) error
-	GetTransaction(ctx context.Context, userID int) ([]models.Transaction, error)
+	GetTransactionByReferenceForUpdate(ctx context.Context, reference string) (models.Transaction, error)
+	GetRefundedAmount(ctx context.Context, reference string) (models.Money, error)
+	GetTransaction(ctx context.Context, filter models.TransactionFilter) ([]models.Transaction, error)
+	CountTransactions(ctx context.Context, filter models.TransactionFilter) (int64, error)
+	ExportTransactions(ctx context.Context, filter models.TransactionFilter, fn func(models.Transaction) error) error
+	GetPendingTransactionsCreatedBefore(ctx context.Context, transactionType string, before time.Time, limit int) ([]models.Transaction, error)
+	UpdateStatusTransactionFrom(ctx context.Context, reference, fromStatus, status, additionalInfo, updatedBy string) (bool, error)
+	GetSettledTransactionsCreatedBetween(ctx context.Context, start, end time.Time) ([]models.Transaction, error)
+	GetTransactionUsage(ctx context.Context, userID int, transactionType, currency string, since time.Time, excludeReference string) (models.TransactionUsage, error)
+	CountUserTransactionsSince(ctx context.Context, userID int, transactionTypes []string, since time.Time, excludeReference string) (int64, error)
+	GetFirstTransaction(ctx context.Context, userID int, excludeReference string) (models.Transaction, error)
 }
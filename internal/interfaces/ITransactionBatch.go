@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ITransactionBatchAPI interface {
+	CreateBatch(c *gin.Context)
+	GetBatch(c *gin.Context)
+}
+
+type ITransactionBatchService interface {
+	CreateBatch(ctx context.Context, tokenData models.TokenData, req models.CreateTransactionBatch) (models.TransactionBatch, error)
+	GetBatch(ctx context.Context, tokenData models.TokenData, batchID string) (models.TransactionBatch, error)
+	ProcessPendingBatches(ctx context.Context) error
+}
+
+type ITransactionBatchRepo interface {
+	CreateTransactionBatch(ctx context.Context, batch *models.TransactionBatch) error
+	CreateTransactionBatchItems(ctx context.Context, items []models.TransactionBatchItem) error
+	GetTransactionBatch(ctx context.Context, batchID string) (models.TransactionBatch, error)
+	GetTransactionBatchItems(ctx context.Context, id int) ([]models.TransactionBatchItem, error)
+	GetProcessableTransactionBatches(ctx context.Context, staleBefore time.Time, limit int) ([]models.TransactionBatch, error)
+	ClaimTransactionBatch(ctx context.Context, batch *models.TransactionBatch) (bool, error)
+	UpdateTransactionBatchProgress(ctx context.Context, batch *models.TransactionBatch) error
+	UpdateTransactionBatchItem(ctx context.Context, item *models.TransactionBatchItem) error
+}
@@ -0,0 +1,29 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ITransactionLimitAPI interface {
+	GetUserLimits(c *gin.Context)
+	SetUserLimit(c *gin.Context)
+	DeleteUserLimit(c *gin.Context)
+}
+
+type ITransactionLimitService interface {
+	GetUserLimits(ctx context.Context, userID int) ([]models.EffectiveTransactionLimit, error)
+	SetUserLimit(ctx context.Context, tokenData models.TokenData, userID int, transactionType string, limit models.TransactionLimit) (models.UserTransactionLimit, error)
+	DeleteUserLimit(ctx context.Context, userID int, transactionType string) error
+	CheckTransactionLimit(ctx context.Context, trx models.Transaction) error
+}
+
+type ITransactionLimitRepo interface {
+	GetUserTransactionLimits(ctx context.Context, userID int) ([]models.UserTransactionLimit, error)
+	GetUserTransactionLimit(ctx context.Context, userID int, transactionType string) (models.UserTransactionLimit, error)
+	UpsertUserTransactionLimit(ctx context.Context, limit *models.UserTransactionLimit) error
+	DeleteUserTransactionLimit(ctx context.Context, userID int, transactionType string) (bool, error)
+	LockUserTransactionLimits(ctx context.Context, userID int) error
+}
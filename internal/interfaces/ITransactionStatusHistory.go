@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+)
+
+type ITransactionStatusHistoryRepo interface {
+	CreateTransactionStatusHistory(ctx context.Context, history *models.TransactionStatusHistory) error
+	GetTransactionStatusHistory(ctx context.Context, reference string) ([]models.TransactionStatusHistory, error)
+	GetTransactionStatusHistories(ctx context.Context, references []string) ([]models.TransactionStatusHistory, error)
+}
@@ -0,0 +1,37 @@
+package interfaces
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IWebhookAPI interface {
+	CreateWebhook(c *gin.Context)
+	GetWebhooks(c *gin.Context)
+	DeleteWebhook(c *gin.Context)
+	GetWebhookDeliveries(c *gin.Context)
+}
+
+type IWebhookService interface {
+	CreateWebhook(ctx context.Context, tokenData models.TokenData, req models.CreateWebhook) (models.CreateWebhookResponse, error)
+	GetWebhooks(ctx context.Context, tokenData models.TokenData) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, tokenData models.TokenData, id int) error
+	GetWebhookDeliveries(ctx context.Context, tokenData models.TokenData, id, limit, offset int) ([]models.WebhookDelivery, error)
+	DispatchWebhookDeliveries(ctx context.Context) error
+}
+
+type IWebhookRepo interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhook(ctx context.Context, id int) (models.Webhook, error)
+	GetWebhooksByUserID(ctx context.Context, userID int) ([]models.Webhook, error)
+	GetWebhooksForUsers(ctx context.Context, userIDs []int) ([]models.Webhook, error)
+	DeactivateWebhook(ctx context.Context, id int) error
+	CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDueWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error)
+	ClaimWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery, lease time.Duration) (bool, error)
+	UpdateWebhookDelivery(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, responseStatus int, lastError string) error
+	GetWebhookDeliveries(ctx context.Context, webhookID, limit, offset int) ([]models.WebhookDelivery, error)
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// BalanceOperation journals a single wallet call. It is written before the
+// wallet is called and finalized in the same database transaction as the
+// change it belongs to, so an operation left PENDING or APPLIED means the
+// wallet and the transactions table may have diverged and must be recovered.
+type BalanceOperation struct {
+	ID                   int    `json:"id"`
+	UserID               int    `json:"user_id" gorm:"column:user_id"`
+	TransactionReference string `json:"transaction_reference" gorm:"column:transaction_reference;type:varchar(255);index"`
+	WalletReference      string `json:"wallet_reference" gorm:"column:wallet_reference;type:varchar(255);index"`
+	// ChangeID groups the ops journaled together for one change, like the
+	// legs of a transfer, which are finished or compensated together.
+	ChangeID  string    `json:"change_id" gorm:"column:change_id;type:varchar(255);index"`
+	Operation string    `json:"operation" gorm:"column:operation;type:enum('CREDIT','DEBIT')"`
+	Amount    Money     `json:"amount" gorm:"column:amount;type:decimal(15,2)"`
+	Currency  string    `json:"currency" gorm:"column:currency;type:varchar(3);default:IDR"`
+	Action    string    `json:"action" gorm:"column:action;type:enum('UPDATE_STATUS','REFUND','TRANSFER','CREATE','FEE')"`
+	Payload   string    `json:"payload" gorm:"column:payload;type:text"`
+	Status    string    `json:"status" gorm:"column:status;type:enum('PENDING','APPLIED','COMPLETED','FAILED','COMPENSATED');index"`
+	LastError string    `json:"last_error" gorm:"column:last_error;type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (*BalanceOperation) TableName() string {
+	return "balance_operations"
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// TransactionBatch creates the transactions of one upload. In the
+// ALL_OR_NOTHING mode every item is created or none is, in the BEST_EFFORT
+// mode each item is created on its own. Large batches are left PENDING for
+// the batch worker and the counters show how far it got.
+type TransactionBatch struct {
+	ID             int       `json:"-"`
+	BatchID        string    `json:"batch_id" gorm:"column:batch_id;type:varchar(64);uniqueIndex"`
+	UserID         int       `json:"user_id" gorm:"column:user_id;index"`
+	Mode           string    `json:"mode" gorm:"column:mode;type:enum('ALL_OR_NOTHING','BEST_EFFORT')"`
+	Status         string    `json:"status" gorm:"column:status;type:enum('PENDING','PROCESSING','COMPLETED','FAILED');index"`
+	TotalItems     int       `json:"total_items" gorm:"column:total_items"`
+	ProcessedItems int       `json:"processed_items" gorm:"column:processed_items"`
+	SucceededItems int       `json:"succeeded_items" gorm:"column:succeeded_items"`
+	FailedItems    int       `json:"failed_items" gorm:"column:failed_items"`
+	UserEmail      string    `json:"-" gorm:"column:user_email;type:varchar(255)"`
+	UserFullName   string    `json:"-" gorm:"column:user_full_name;type:varchar(255)"`
+	CreatedBy      string    `json:"-" gorm:"column:created_by;type:varchar(255)"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Items []TransactionBatchItem `json:"items,omitempty" gorm:"-"`
+}
+
+func (*TransactionBatch) TableName() string {
+	return "transaction_batches"
+}
+
+// TransactionBatchItem is the transaction on Line of a batch, counted from 1
+// without the CSV header, and the result of creating it.
+type TransactionBatchItem struct {
+	ID                int         `json:"-"`
+	BatchID           int         `json:"-" gorm:"column:batch_id;index"`
+	Line              int         `json:"line" gorm:"column:line"`
+	Request           Transaction `json:"-" gorm:"column:request;type:text;serializer:json"`
+	Status            string      `json:"status" gorm:"column:status;type:enum('PENDING','PROCESSING','SUCCESS','FAILED')"`
+	Reference         string      `json:"reference,omitempty" gorm:"column:reference;type:varchar(255)"`
+	TransactionStatus string      `json:"transaction_status,omitempty" gorm:"column:transaction_status;type:varchar(20)"`
+	FeeAmount         Money       `json:"fee_amount" gorm:"column:fee_amount;type:decimal(15,2);default:0"`
+	Error             string      `json:"error,omitempty" gorm:"column:error;type:text"`
+	UpdatedAt         time.Time   `json:"-"`
+}
+
+func (*TransactionBatchItem) TableName() string {
+	return "transaction_batch_items"
+}
+
+// CreateTransactionBatch is a parsed upload. Items that could not be parsed
+// carry their Error and are not created.
+type CreateTransactionBatch struct {
+	Mode  string
+	Items []TransactionBatchItem
+}
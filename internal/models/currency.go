@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const DefaultCurrency = "IDR"
+
+// CurrencyMinorUnits lists the supported ISO 4217 currencies with the number
+// of decimal places each one allows. Currencies with three decimal places
+// are left out since amounts are stored with two.
+var CurrencyMinorUnits = map[string]int{
+	"AUD": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"IDR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"MYR": 2,
+	"PHP": 2,
+	"SGD": 2,
+	"THB": 2,
+	"USD": 2,
+	"VND": 0,
+}
+
+var ErrCurrencyInvalid = errors.New("currency is not supported")
+
+// ValidateCurrencyAmount checks that currency is supported and that amount
+// has no more decimal places than the currency allows. An empty currency is
+// treated as DefaultCurrency.
+func ValidateCurrencyAmount(currency string, amount Money) error {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	minorUnits, ok := CurrencyMinorUnits[currency]
+	if !ok {
+		return ErrCurrencyInvalid
+	}
+
+	if amount.Decimals() > minorUnits {
+		return fmt.Errorf("%s allows at most %d decimal places: %w", currency, minorUnits, ErrMoneyPrecision)
+	}
+
+	return nil
+}
+
+// Round rounds m half away from zero to the decimal places currency allows.
+// Unknown currencies keep two places.
+func (m Money) Round(currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if minorUnits, ok := CurrencyMinorUnits[currency]; ok && minorUnits == 0 {
+		return m.MulRatio(1, moneyScale) * moneyScale
+	}
+	return m
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventTransactionCreated       = "TransactionCreated"
+	EventTransactionStatusChanged = "TransactionStatusChanged"
+	EventTransactionRefunded      = "TransactionRefunded"
+)
+
+// EventSchemaVersion is the current version of the Data of each event type.
+// Bump it when a field is removed or changes meaning, adding fields is
+// backward compatible.
+var EventSchemaVersion = map[string]int{
+	EventTransactionCreated:       1,
+	EventTransactionStatusChanged: 1,
+	EventTransactionRefunded:      1,
+}
+
+// Event is the envelope of every domain event. ID is derived from what
+// happened, so consumers can use it to drop events delivered more than once.
+// Reference is the transaction the event is about and can be used as the
+// partition key.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Reference  string          `json:"reference"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func NewEvent(id, eventType, reference string, data interface{}) (Event, error) {
+	byteData, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:         id,
+		Type:       eventType,
+		Version:    EventSchemaVersion[eventType],
+		OccurredAt: time.Now(),
+		Reference:  reference,
+		Data:       byteData,
+	}, nil
+}
+
+type TransactionCreatedData struct {
+	Reference         string `json:"reference"`
+	ParentReference   string `json:"parent_reference,omitempty"`
+	UserID            int    `json:"user_id"`
+	TransactionType   string `json:"transaction_type"`
+	TransactionStatus string `json:"transaction_status"`
+	Amount            Money  `json:"amount"`
+	Currency          string `json:"currency"`
+	Description       string `json:"description"`
+	Actor             string `json:"actor"`
+}
+
+type TransactionStatusChangedData struct {
+	Reference         string `json:"reference"`
+	UserID            int    `json:"user_id"`
+	TransactionType   string `json:"transaction_type"`
+	PreviousStatus    string `json:"previous_status"`
+	TransactionStatus string `json:"transaction_status"`
+	Amount            Money  `json:"amount"`
+	Currency          string `json:"currency"`
+	AdditionalInfo    string `json:"additional_info"`
+	Actor             string `json:"actor"`
+}
+
+type TransactionRefundedData struct {
+	Reference       string `json:"reference"`
+	ParentReference string `json:"parent_reference"`
+	UserID          int    `json:"user_id"`
+	Amount          Money  `json:"amount"`
+	Currency        string `json:"currency"`
+	Actor           string `json:"actor"`
+}
+
+// EventOutbox is an event waiting to be relayed to the event backend. Rows
+// are written in the same database transaction as the change they announce.
+type EventOutbox struct {
+	ID            int       `json:"id"`
+	EventID       string    `json:"event_id" gorm:"column:event_id;type:varchar(255);uniqueIndex"`
+	EventType     string    `json:"event_type" gorm:"column:event_type;type:varchar(255)"`
+	Payload       string    `json:"payload" gorm:"column:payload;type:text"`
+	Status        string    `json:"status" gorm:"column:status;type:enum('PENDING','PUBLISHED','DEAD');index:idx_event_outbox_status_next_attempt"`
+	Attempts      int       `json:"attempts" gorm:"column:attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"column:next_attempt_at;index:idx_event_outbox_status_next_attempt"`
+	LastError     string    `json:"last_error" gorm:"column:last_error;type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (*EventOutbox) TableName() string {
+	return "event_outbox"
+}
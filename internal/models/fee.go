@@ -0,0 +1,47 @@
+package models
+
+// FeeRule charges a fee on the transactions of TransactionType made through
+// Channel in Currency whose amount is within [MinAmount, MaxAmount]. Empty
+// fields match everything and a zero MaxAmount has no upper bound. The fee
+// is Flat plus PercentageBps hundredths of a percent of the amount, kept
+// between MinFee and MaxFee when they are set.
+type FeeRule struct {
+	Name            string `json:"name"`
+	TransactionType string `json:"transaction_type"`
+	Channel         string `json:"channel,omitempty"`
+	Currency        string `json:"currency,omitempty"`
+	MinAmount       Money  `json:"min_amount,omitempty"`
+	MaxAmount       Money  `json:"max_amount,omitempty"`
+
+	Flat          Money `json:"flat,omitempty"`
+	PercentageBps int64 `json:"percentage_bps,omitempty"`
+	MinFee        Money `json:"min_fee,omitempty"`
+	MaxFee        Money `json:"max_fee,omitempty"`
+}
+
+// Matches reports whether rule applies to trx.
+func (r FeeRule) Matches(trx Transaction) bool {
+	if r.TransactionType != trx.TransactionType {
+		return false
+	}
+	if r.Channel != "" && r.Channel != trx.Channel {
+		return false
+	}
+	if r.Currency != "" && r.Currency != trx.Currency {
+		return false
+	}
+	return trx.Amount >= r.MinAmount && (r.MaxAmount == 0 || trx.Amount <= r.MaxAmount)
+}
+
+// Fee returns the fee rule charges on amount in currency, rounded to the
+// decimal places of the currency.
+func (r FeeRule) Fee(amount Money, currency string) Money {
+	fee := r.Flat + amount.MulRatio(r.PercentageBps, 10000)
+	if fee < r.MinFee {
+		fee = r.MinFee
+	}
+	if r.MaxFee > 0 && fee > r.MaxFee {
+		fee = r.MaxFee
+	}
+	return fee.Round(currency)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FraudRule flags the transactions that meet its condition. It applies to the
+// transactions of TransactionTypes moving to Statuses, PENDING being the
+// status a transaction is created in; empty lists match everything. Action is REVIEW to hold the transaction for an
+// operator or LOG to only record the match. The other fields are the
+// parameters of Condition:
+//
+//   - amount_over: the amount is over Amount
+//   - velocity: the user created at least Count other transactions of
+//     PreviousTransactionTypes in the last Window
+//   - after_transaction: the user created a transaction of
+//     PreviousTransactionTypes in the last Window
+//   - new_user: the first transaction of the user is less than Window old and
+//     the amount is over Amount
+//
+// Amount is in Currency, the default currency when empty, and only
+// transactions in that currency are compared with it.
+type FraudRule struct {
+	Name             string   `json:"name"`
+	Condition        string   `json:"condition"`
+	Action           string   `json:"action,omitempty"`
+	TransactionTypes []string `json:"transaction_types,omitempty"`
+	Statuses         []string `json:"statuses,omitempty"`
+
+	Amount                   Money    `json:"amount,omitempty"`
+	Currency                 string   `json:"currency,omitempty"`
+	Count                    int      `json:"count,omitempty"`
+	Window                   Duration `json:"window,omitempty"`
+	PreviousTransactionTypes []string `json:"previous_transaction_types,omitempty"`
+}
+
+// Applies reports whether rule is checked when trx moves to status.
+func (r FraudRule) Applies(trx Transaction, status string) bool {
+	return containsOrEmpty(r.TransactionTypes, trx.TransactionType) && containsOrEmpty(r.Statuses, status)
+}
+
+// AmountCurrency is the currency of the Amount of r.
+func (r FraudRule) AmountCurrency() string {
+	if r.Currency == "" {
+		return DefaultCurrency
+	}
+	return r.Currency
+}
+
+// Duration is a time.Duration written as a string such as "10m" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// FraudEvaluation records the rules checked when a transaction was created
+// (FromStatus "") or moved to ToStatus. A REVIEW decision moved it to
+// UNDER_REVIEW instead, until an operator approves ToStatus or rejects it.
+type FraudEvaluation struct {
+	ID                   int        `json:"id"`
+	TransactionReference string     `json:"transaction_reference" gorm:"column:transaction_reference;type:varchar(255);index"`
+	UserID               int        `json:"user_id" gorm:"column:user_id"`
+	TransactionType      string     `json:"transaction_type" gorm:"column:transaction_type;type:varchar(20)"`
+	FromStatus           string     `json:"from_status" gorm:"column:from_status;type:varchar(20)"`
+	ToStatus             string     `json:"to_status" gorm:"column:to_status;type:varchar(20)"`
+	MatchedRules         []string   `json:"matched_rules" gorm:"column:matched_rules;type:text;serializer:json"`
+	Decision             string     `json:"decision" gorm:"column:decision;type:enum('ALLOW','REVIEW')"`
+	ReviewStatus         string     `json:"review_status,omitempty" gorm:"column:review_status;type:varchar(20);index"`
+	ReviewedBy           string     `json:"reviewed_by,omitempty" gorm:"column:reviewed_by;type:varchar(255)"`
+	ReviewedAt           *time.Time `json:"reviewed_at,omitempty" gorm:"column:reviewed_at"`
+	ReviewNote           string     `json:"review_note,omitempty" gorm:"column:review_note;type:varchar(255)"`
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+func (*FraudEvaluation) TableName() string {
+	return "fraud_evaluations"
+}
+
+type ReviewTransaction struct {
+	Note string `json:"note" validate:"max=255"`
+}
+
+func (l ReviewTransaction) Validate() error {
+	v := validator.New()
+	return v.Struct(l)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the response produced for an Idempotency-Key header
+// so retried requests get the same result instead of creating new rows.
+// ResponseCode stays zero while the first request is still being processed,
+// LockedUntil is how long that request holds the key before a retry may take
+// it over, so a crashed request does not block the key until it expires.
+type IdempotencyKey struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id" gorm:"column:user_id;uniqueIndex:idx_idempotency_keys_user_key"`
+	IdempotencyKey string    `json:"idempotency_key" gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_idempotency_keys_user_key"`
+	RequestHash    string    `json:"request_hash" gorm:"column:request_hash;type:varchar(64)"`
+	ResponseCode   int       `json:"response_code" gorm:"column:response_code"`
+	ResponseBody   string    `json:"response_body" gorm:"column:response_body;type:text"`
+	LockedUntil    time.Time `json:"locked_until" gorm:"column:locked_until"`
+	ExpiredAt      time.Time `json:"expired_at" gorm:"column:expired_at;index"`
+	CreatedAt      time.Time `json:"-"`
+	UpdatedAt      time.Time `json:"-"`
+}
+
+func (*IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
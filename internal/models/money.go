@@ -0,0 +1,231 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount counted in hundredths, the precision of the
+// decimal(15,2) amount columns. It reads and writes those columns as decimal
+// strings, so existing rows are used as they are without any migration.
+type Money int64
+
+const (
+	moneyDecimals = 2
+	moneyScale    = 100
+	// decimal(15,2) leaves 13 digits before the decimal point
+	moneyMaxIntegerDigits = 13
+)
+
+var (
+	ErrMoneyInvalid   = errors.New("invalid amount")
+	ErrMoneyPrecision = errors.New("amount has too many decimal places")
+	ErrMoneyTooLarge  = errors.New("amount is too large")
+)
+
+// ParseMoney parses a plain decimal such as "1500", "1500.5" or "-0.25".
+// Amounts with more decimal places than Money holds are rejected instead of
+// being rounded.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(s, ".")
+	if integer == "" || !isDigits(integer) || !isDigits(fraction) {
+		return 0, ErrMoneyInvalid
+	}
+	if strings.Contains(s, ".") && fraction == "" {
+		return 0, ErrMoneyInvalid
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > moneyDecimals {
+		return 0, ErrMoneyPrecision
+	}
+
+	integer = strings.TrimLeft(integer, "0")
+	if len(integer) > moneyMaxIntegerDigits {
+		return 0, ErrMoneyTooLarge
+	}
+
+	units, _ := strconv.ParseInt("0"+integer+fraction+strings.Repeat("0", moneyDecimals-len(fraction)), 10, 64)
+	if negative {
+		units = -units
+	}
+
+	return Money(units), nil
+}
+
+// MoneyFromFloat converts f, rounding half away from zero to the nearest
+// hundredth. It is only meant for values that are already inexact, use
+// ParseMoney for user input.
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyScale))
+}
+
+// MulRatio returns m * numerator / denominator rounded half away from zero,
+// the same rounding as MoneyFromFloat. The product is kept in 128 bits so
+// large amounts do not overflow; a result that does not fit in Money, which
+// a ratio of at most 1 never gives, is clamped to the largest amount. The
+// denominator must not be zero.
+func (m Money) MulRatio(numerator, denominator int64) Money {
+	negative := (m < 0) != (numerator < 0) != (denominator < 0)
+	divisor := uabs(denominator)
+
+	hi, lo := bits.Mul64(uabs(int64(m)), uabs(numerator))
+	if hi >= divisor {
+		return clampMoney(negative)
+	}
+	quotient, remainder := bits.Div64(hi, lo, divisor)
+	if remainder >= divisor-remainder {
+		quotient++
+	}
+
+	if negative {
+		if quotient > 1<<63 {
+			return clampMoney(negative)
+		}
+		return Money(-int64(quotient))
+	}
+	if quotient > math.MaxInt64 {
+		return clampMoney(negative)
+	}
+	return Money(quotient)
+}
+
+// Decimals returns the number of decimal places m needs, from 0 to 2.
+func (m Money) Decimals() int {
+	switch {
+	case int64(m)%moneyScale == 0:
+		return 0
+	case int64(m)%10 == 0:
+		return 1
+	}
+	return moneyDecimals
+}
+
+// Format formats m with the number of decimal places of currency, e.g.
+// "1500.00" for IDR and "1500" for JPY. Amounts are expected to be validated
+// with ValidateCurrencyAmount, unknown currencies use two places.
+func (m Money) Format(currency string) string {
+	if minorUnits, ok := CurrencyMinorUnits[currency]; ok && minorUnits == 0 && m.Decimals() == 0 {
+		return fmt.Sprintf("%d", int64(m)/moneyScale)
+	}
+	return m.String()
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// String formats m with exactly two decimal places, e.g. "1500.00".
+func (m Money) String() string {
+	sign := ""
+	units := int64(m)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/moneyScale, units%moneyScale)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding a decimal.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+
+	money, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// UnmarshalParam is used by gin to bind query and form parameters.
+func (m *Money) UnmarshalParam(param string) error {
+	if param == "" {
+		*m = 0
+		return nil
+	}
+
+	money, err := ParseMoney(param)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money(v * moneyScale)
+		return nil
+	case float64:
+		*m = MoneyFromFloat(v)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+func (m *Money) scanString(s string) error {
+	money, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// uabs returns the magnitude of i, which for math.MinInt64 only fits
+// unsigned.
+func uabs(i int64) uint64 {
+	if i < 0 {
+		return uint64(-i)
+	}
+	return uint64(i)
+}
+
+func clampMoney(negative bool) Money {
+	if negative {
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
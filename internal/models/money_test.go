@@ -0,0 +1,216 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		name        string
+		m           Money
+		numerator   int64
+		denominator int64
+		want        Money
+	}{
+		{"exact", 10000, 1, 4, 2500},
+		{"round half up", 5, 1, 2, 3},
+		{"round down", 4, 1, 3, 1},
+		{"negative amount rounds away from zero", -5, 1, 2, -3},
+		{"negative denominator", 5, 1, -2, -3},
+		{"negative both", -10, -1, 3, 3},
+		{"basis points", 10000000, 70, 10000, 70000},
+		// 1e9 IDR fee of a 1e11 IDR purchase refunded by 1e11 IDR, the
+		// product is about 1e24 hundredths
+		{"large fee of a large refund", 100000000000, 10000000000000, 10000000000000, 100000000000},
+		{"large third", 100000000000, 3333333333333, 10000000000000, 33333333333},
+		{"max amount full ratio", math.MaxInt64, math.MaxInt64, math.MaxInt64, math.MaxInt64},
+		{"min amount full ratio", math.MinInt64, 1, 1, math.MinInt64},
+		{"result too large", math.MaxInt64, 2, 1, math.MaxInt64},
+		{"result too small", math.MaxInt64, -2, 1, math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.m.MulRatio(tt.numerator, tt.denominator)
+			if got != tt.want {
+				t.Errorf("%d.MulRatio(%d, %d) = %d, want %d", tt.m, tt.numerator, tt.denominator, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{"1500", 150000, nil},
+		{"1500.5", 150050, nil},
+		{"1500.50", 150050, nil},
+		{"1500.500", 150050, nil},
+		{" 0.01 ", 1, nil},
+		{"-0.25", -25, nil},
+		{"007", 700, nil},
+		{"9999999999999.99", 999999999999999, nil},
+		{"10000000000000", 0, ErrMoneyTooLarge},
+		{"0.001", 0, ErrMoneyPrecision},
+		{"", 0, ErrMoneyInvalid},
+		{"-", 0, ErrMoneyInvalid},
+		{"1.", 0, ErrMoneyInvalid},
+		{".5", 0, ErrMoneyInvalid},
+		{"1e3", 0, ErrMoneyInvalid},
+		{"1,000", 0, ErrMoneyInvalid},
+		{"+1", 0, ErrMoneyInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			if err != tt.wantErr {
+				t.Fatalf("ParseMoney(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		m        Money
+		currency string
+		want     string
+	}{
+		{150050, "IDR", "1500.50"},
+		{150000, "IDR", "1500.00"},
+		{150000, "JPY", "1500"},
+		{-150000, "JPY", "-1500"},
+		// not a valid JPY amount, kept exact instead of rounded
+		{150050, "JPY", "1500.50"},
+		{5, "USD", "0.05"},
+		{-5, "USD", "-0.05"},
+		{150000, "XXX", "1500.00"},
+		{0, "IDR", "0.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Format(tt.currency); got != tt.want {
+			t.Errorf("Money(%d).Format(%q) = %q, want %q", tt.m, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	tests := []struct {
+		m        Money
+		currency string
+		want     Money
+	}{
+		{150050, "JPY", 150100},
+		{150049, "JPY", 150000},
+		{-150050, "JPY", -150100},
+		{150049, "IDR", 150049},
+		{150049, "", 150049},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Round(tt.currency); got != tt.want {
+			t.Errorf("Money(%d).Round(%q) = %d, want %d", tt.m, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyValue(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{150050, "1500.50"},
+		{1, "0.01"},
+		{-1, "-0.01"},
+		{999999999999999, "9999999999999.99"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.m.Value()
+		if err != nil {
+			t.Fatalf("Money(%d).Value() error = %v", tt.m, err)
+		}
+		if got != tt.want {
+			t.Errorf("Money(%d).Value() = %v, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{"nil", nil, 0, false},
+		{"bytes", []byte("1500.50"), 150050, false},
+		{"string", "-0.25", -25, false},
+		{"int64", int64(1500), 150000, false},
+		{"float64 rounds half away from zero", 0.125, 13, false},
+		{"negative float64", -0.125, -13, false},
+		{"invalid string", "abc", 0, true},
+		{"too large", "99999999999999", 0, true},
+		{"unsupported type", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			err := m.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, want error %v", tt.src, err, tt.wantErr)
+			}
+			if m != tt.want {
+				t.Errorf("Scan(%v) = %d, want %d", tt.src, m, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyValueScanRoundTrip(t *testing.T) {
+	for _, m := range []Money{0, 1, -1, 150050, -150050, 999999999999999, -999999999999999} {
+		v, err := m.Value()
+		if err != nil {
+			t.Fatalf("Money(%d).Value() error = %v", m, err)
+		}
+		var got Money
+		if err := got.Scan(v); err != nil {
+			t.Fatalf("Scan(%v) error = %v", v, err)
+		}
+		if got != m {
+			t.Errorf("round trip of %d gave %d", m, got)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := Money(-150050).MarshalJSON()
+	if err != nil || string(b) != "-1500.50" {
+		t.Errorf("MarshalJSON() = %s, %v", b, err)
+	}
+
+	for in, want := range map[string]Money{`1500.5`: 150050, `"1500.5"`: 150050, `null`: 0, `-2`: -200} {
+		var m Money
+		if err := m.UnmarshalJSON([]byte(in)); err != nil {
+			t.Errorf("UnmarshalJSON(%s) error = %v", in, err)
+		}
+		if m != want {
+			t.Errorf("UnmarshalJSON(%s) = %d, want %d", in, m, want)
+		}
+	}
+
+	var m Money
+	if err := m.UnmarshalJSON([]byte(`1.005`)); err != ErrMoneyPrecision {
+		t.Errorf("UnmarshalJSON(1.005) error = %v, want %v", err, ErrMoneyPrecision)
+	}
+}
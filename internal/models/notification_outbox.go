@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// NotificationOutbox is a notification waiting to be delivered by the
+// dispatcher. Rows are written in the same database transaction as the
+// change they announce, so a notification is never lost when the
+// notification service is down.
+type NotificationOutbox struct {
+	ID            int               `json:"id"`
+	Recipient     string            `json:"recipient" gorm:"column:recipient;type:varchar(255)"`
+	TemplateName  string            `json:"template_name" gorm:"column:template_name;type:varchar(255)"`
+	Placeholders  map[string]string `json:"placeholders" gorm:"column:placeholders;type:text;serializer:json"`
+	Status        string            `json:"status" gorm:"column:status;type:enum('PENDING','SENT','DEAD');index:idx_notification_outbox_status_next_attempt"`
+	Attempts      int               `json:"attempts" gorm:"column:attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at" gorm:"column:next_attempt_at;index:idx_notification_outbox_status_next_attempt"`
+	LastError     string            `json:"last_error" gorm:"column:last_error;type:text"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+func (*NotificationOutbox) TableName() string {
+	return "notification_outbox"
+}
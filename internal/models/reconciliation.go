@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ReconciliationReport lists the differences found between the settled
+// transactions created in [StartTime, EndTime) and the wallet entries.
+type ReconciliationReport struct {
+	StartTime      time.Time             `json:"start_time"`
+	EndTime        time.Time             `json:"end_time"`
+	Checked        int                   `json:"checked"`
+	Missing        []ReconciliationEntry `json:"missing"`
+	Duplicate      []ReconciliationEntry `json:"duplicate"`
+	AmountMismatch []ReconciliationEntry `json:"amount_mismatch"`
+	Unexpected     []ReconciliationEntry `json:"unexpected"`
+}
+
+// ReconciliationEntry is one wallet reference that does not match. Expected
+// fields are empty for unexpected entries and wallet fields are empty for
+// missing ones.
+type ReconciliationEntry struct {
+	Reference            string `json:"reference"`
+	TransactionReference string `json:"transaction_reference,omitempty"`
+	ExpectedOperation    string `json:"expected_operation,omitempty"`
+	ExpectedAmount       *Money `json:"expected_amount,omitempty"`
+	WalletOperation      string `json:"wallet_operation,omitempty"`
+	WalletAmount         *Money `json:"wallet_amount,omitempty"`
+	Currency             string `json:"currency,omitempty"`
+	WalletCount          int    `json:"wallet_count"`
+}
+
+func (r ReconciliationReport) HasDiscrepancies() bool {
+	return len(r.Missing) > 0 || len(r.Duplicate) > 0 || len(r.AmountMismatch) > 0 || len(r.Unexpected) > 0
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ScheduledTransaction creates a transaction of its owner at StartAt, or at
+// every time matching the cron expression Recurrence from StartAt until
+// EndAt. NextRunAt is nil once the schedule will not run again.
+type ScheduledTransaction struct {
+	ID              int          `json:"id"`
+	UserID          int          `json:"user_id" gorm:"column:user_id;index"`
+	TransactionType string       `json:"transaction_type" gorm:"column:transaction_type;type:varchar(20)"`
+	Amount          Money        `json:"amount" gorm:"column:amount;type:decimal(15,2)"`
+	Currency        string       `json:"currency" gorm:"column:currency;type:varchar(3);default:IDR"`
+	Description     string       `json:"description" gorm:"column:description;type:varchar(255)"`
+	AddtionalInfo   string       `json:"additional_info" gorm:"column:additional_info;type:text"`
+	RecipientUserID int          `json:"recipient_user_id,omitempty" gorm:"column:recipient_user_id"`
+	BankAccount     *BankAccount `json:"bank_account,omitempty" gorm:"column:bank_account;type:text;serializer:json"`
+	Recurrence      string       `json:"recurrence,omitempty" gorm:"column:recurrence;type:varchar(100)"`
+	Timezone        string       `json:"timezone" gorm:"column:timezone;type:varchar(64)"`
+	StartAt         time.Time    `json:"start_at" gorm:"column:start_at"`
+	EndAt           *time.Time   `json:"end_at,omitempty" gorm:"column:end_at"`
+	NextRunAt       *time.Time   `json:"next_run_at,omitempty" gorm:"column:next_run_at;index:idx_scheduled_transactions_status_next_run_at"`
+	Status          string       `json:"status" gorm:"column:status;type:enum('ACTIVE','PAUSED','CANCELLED','COMPLETED');index:idx_scheduled_transactions_status_next_run_at"`
+	UserEmail       string       `json:"-" gorm:"column:user_email;type:varchar(255)"`
+	UserFullName    string       `json:"-" gorm:"column:user_full_name;type:varchar(255)"`
+	CreatedBy       string       `json:"-" gorm:"column:created_by;type:varchar(255)"`
+	UpdatedBy       string       `json:"-" gorm:"column:updated_by;type:varchar(255)"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+func (*ScheduledTransaction) TableName() string {
+	return "scheduled_transactions"
+}
+
+type CreateScheduledTransaction struct {
+	TransactionType string       `json:"transaction_type" validate:"required"`
+	Amount          Money        `json:"amount" validate:"required,gt=0"`
+	Currency        string       `json:"currency"`
+	Description     string       `json:"description" validate:"required,max=255"`
+	AddtionalInfo   string       `json:"additional_info"`
+	RecipientUserID int          `json:"recipient_user_id"`
+	BankAccount     *BankAccount `json:"bank_account"`
+	// Recurrence is a cron expression, the schedule runs once at StartAt
+	// when it is empty.
+	Recurrence string     `json:"recurrence" validate:"max=100"`
+	Timezone   string     `json:"timezone"`
+	StartAt    time.Time  `json:"start_at" validate:"required"`
+	EndAt      *time.Time `json:"end_at"`
+}
+
+func (l CreateScheduledTransaction) Validate() error {
+	v := validator.New()
+	if err := v.Struct(l); err != nil {
+		return err
+	}
+	return ValidateCurrencyAmount(l.Currency, l.Amount)
+}
+
+// ScheduledTransactionRun records one run of a schedule and the transaction
+// it created, or why it could not create one.
+type ScheduledTransactionRun struct {
+	ID                     int       `json:"id"`
+	ScheduledTransactionID int       `json:"scheduled_transaction_id" gorm:"column:scheduled_transaction_id;index"`
+	ScheduledAt            time.Time `json:"scheduled_at" gorm:"column:scheduled_at"`
+	Status                 string    `json:"status" gorm:"column:status;type:enum('SUCCESS','FAILED','SKIPPED')"`
+	TransactionReference   string    `json:"transaction_reference,omitempty" gorm:"column:transaction_reference;type:varchar(255)"`
+	TransactionStatus      string    `json:"transaction_status,omitempty" gorm:"column:transaction_status;type:varchar(20)"`
+	Error                  string    `json:"error,omitempty" gorm:"column:error;type:text"`
+	CreatedAt              time.Time `json:"created_at"`
+}
+
+func (*ScheduledTransactionRun) TableName() string {
+	return "scheduled_transaction_runs"
+}
@@ -0,0 +1,85 @@
+package models
+
+import "fmt"
+
+// StateTransition is one allowed status change. A From of "" is the creation
+// of the transaction. WalletOperation is the CREDIT or DEBIT made when the
+// transition happens, under the transaction reference prefixed with
+// WalletReferencePrefix. NotificationTemplate is sent to the owner once the
+// transition is committed. The Counterparty fields do the same for the
+// recipient of a TRANSFER; its wallet call is made after the owner's one.
+// FeeWalletOperation charges or returns the fee of the transaction on the
+// owner's wallet, under its own reference, after the other wallet calls.
+type StateTransition struct {
+	From                  string `json:"from"`
+	To                    string `json:"to"`
+	WalletOperation       string `json:"wallet_operation,omitempty"`
+	WalletReferencePrefix string `json:"wallet_reference_prefix,omitempty"`
+	NotificationTemplate  string `json:"notification_template,omitempty"`
+
+	CounterpartyWalletOperation       string `json:"counterparty_wallet_operation,omitempty"`
+	CounterpartyWalletReferencePrefix string `json:"counterparty_wallet_reference_prefix,omitempty"`
+	CounterpartyNotificationTemplate  string `json:"counterparty_notification_template,omitempty"`
+
+	FeeWalletOperation       string `json:"fee_wallet_operation,omitempty"`
+	FeeWalletReferencePrefix string `json:"fee_wallet_reference_prefix,omitempty"`
+}
+
+// StateMachines holds the transitions allowed for each transaction type.
+type StateMachines map[string][]StateTransition
+
+// InvalidTransitionError is returned for a status change that the state
+// machine of the transaction type does not allow.
+type InvalidTransitionError struct {
+	TransactionType string
+	From            string
+	To              string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("status transaksi %s tidak dapat diubah dari %s ke %s", e.TransactionType, e.From, e.To)
+}
+
+// Transition returns the transition of transactionType from from to to.
+func (m StateMachines) Transition(transactionType, from, to string) (StateTransition, error) {
+	for _, transition := range m[transactionType] {
+		if transition.From == from && transition.To == to {
+			return transition, nil
+		}
+	}
+	return StateTransition{}, &InvalidTransitionError{
+		TransactionType: transactionType,
+		From:            from,
+		To:              to,
+	}
+}
+
+// Path returns the transitions that lead from the creation of a transaction
+// of transactionType to status, preferring the fewest steps. It returns false
+// when status cannot be reached.
+func (m StateMachines) Path(transactionType, status string) ([]StateTransition, bool) {
+	paths := map[string][]StateTransition{"": {}}
+	queue := []string{""}
+
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		if from == status && from != "" {
+			return paths[from], true
+		}
+
+		for _, transition := range m[transactionType] {
+			if transition.From != from {
+				continue
+			}
+			if _, seen := paths[transition.To]; seen {
+				continue
+			}
+			path := append(append([]StateTransition{}, paths[from]...), transition)
+			paths[transition.To] = path
+			queue = append(queue, transition.To)
+		}
+	}
+
+	return nil, false
+}
@@ -1,9 +1,38 @@
 package models
 
+import "context"
+
 type TokenData struct {
 	UserID   int64
 	Username string
 	FullName string
 	Token    string
 	Email    string
+	Roles    []string
+}
+
+// HasRole reports whether the user has at least one of roles.
+func (t TokenData) HasRole(roles ...string) bool {
+	for _, userRole := range t.Roles {
+		for _, role := range roles {
+			if userRole == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type tokenDataKey struct{}
+
+// ContextWithTokenData stores the validated token data in ctx so it can be
+// read the same way by HTTP and gRPC handlers.
+func ContextWithTokenData(ctx context.Context, tokenData TokenData) context.Context {
+	return context.WithValue(ctx, tokenDataKey{}, tokenData)
+}
+
+// TokenDataFromContext returns the token data stored by ContextWithTokenData.
+func TokenDataFromContext(ctx context.Context) (TokenData, bool) {
+	tokenData, ok := ctx.Value(tokenDataKey{}).(TokenData)
+	return tokenData, ok
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -8,17 +10,60 @@ import (
 
 type Transaction struct {
 	ID                int       `json:"id"`
-	UserID            int       `json:"user_id"`
-	Amount            float64   `json:"amount" gorm:"column:amount;type:decimal(15,2)" validate:"required"`
-	TransactionType   string    `json:"transaction_type" gorm:"column:transaction_type;type:enum('TOPUP','PURCHASE','REFUND')" validate:"required"`
-	TransactionStatus string    `json:"transaction_status" gorm:"column:transaction_status;type:enum('PENDING','SUCCESS','FAILED','REVERSED')"`
-	Reference         string    `json:"reference" gorm:"column:reference;type:varchar(255)"`
+	UserID            int       `json:"user_id" gorm:"column:user_id;index"`
+	Amount            Money     `json:"amount" gorm:"column:amount;type:decimal(15,2)" validate:"required,gt=0"`
+	Currency          string    `json:"currency" gorm:"column:currency;type:varchar(3);default:IDR"`
+	TransactionType   string    `json:"transaction_type" gorm:"column:transaction_type;type:enum('TOPUP','PURCHASE','REFUND','TRANSFER','WITHDRAWAL')" validate:"required"`
+	TransactionStatus string    `json:"transaction_status" gorm:"column:transaction_status;type:enum('PENDING','SUCCESS','FAILED','REVERSED','UNDER_REVIEW');index:idx_transactions_status_created_at"`
+	Reference         string    `json:"reference" gorm:"column:reference;type:varchar(255);uniqueIndex"`
+	ParentReference   string    `json:"parent_reference,omitempty" gorm:"column:parent_reference;type:varchar(255);index"`
 	Description       string    `json:"description" gorm:"column:description;type:varchar(255)" validate:"required"`
 	AddtionalInfo     string    `json:"additional_info" gorm:"column:additional_info;type:text"`
-	CreatedAt         time.Time `json:"date"`
+	CreatedAt         time.Time `json:"date" gorm:"index:idx_transactions_status_created_at"`
 	CreatedBy         string    `json:"-" gorm:"column:created_by;type:varchar(255)"`
 	UpdatedAt         time.Time `json:"-"`
 	UpdatedBy         string    `json:"-" gorm:"column:updated_by;type:varchar(255)"`
+	UserEmail         string    `json:"-" gorm:"column:user_email;type:varchar(255)"`
+	UserFullName      string    `json:"-" gorm:"column:user_full_name;type:varchar(255)"`
+
+	// RecipientUserID is the user a TRANSFER is sent to.
+	RecipientUserID   int    `json:"recipient_user_id,omitempty" gorm:"column:recipient_user_id;index"`
+	RecipientEmail    string `json:"-" gorm:"column:recipient_email;type:varchar(255)"`
+	RecipientFullName string `json:"-" gorm:"column:recipient_full_name;type:varchar(255)"`
+
+	// BankAccount is where a WITHDRAWAL is paid out to.
+	BankAccount *BankAccount `json:"bank_account,omitempty" gorm:"column:bank_account;type:text;serializer:json"`
+
+	// Channel is how the transaction was made, e.g. "app" or "va_bca". Fees
+	// may differ per channel.
+	Channel string `json:"channel,omitempty" gorm:"column:channel;type:varchar(50)" validate:"max=50"`
+
+	// FeeAmount is charged on top of Amount when the transaction succeeds,
+	// under the FeeRule it was calculated with. For a refund it is the part
+	// of the purchase fee that was returned.
+	FeeAmount Money  `json:"fee_amount" gorm:"column:fee_amount;type:decimal(15,2);default:0"`
+	FeeRule   string `json:"fee_rule,omitempty" gorm:"column:fee_rule;type:varchar(100)"`
+
+	// Direction tells whether a TRANSFER was sent (OUT) or received (IN) by
+	// the user it is shown to.
+	Direction string `json:"direction,omitempty" gorm:"-"`
+
+	// RefundedAmount and RemainingAmount are only filled for purchases
+	// returned by the detail endpoint.
+	RefundedAmount  *Money `json:"refunded_amount,omitempty" gorm:"-"`
+	RemainingAmount *Money `json:"remaining_amount,omitempty" gorm:"-"`
+}
+
+// BankAccount is the destination of a withdrawal.
+type BankAccount struct {
+	BankCode      string `json:"bank_code" validate:"required,max=20"`
+	AccountNumber string `json:"account_number" validate:"required,numeric,max=34"`
+	AccountName   string `json:"account_name" validate:"required,max=255"`
+}
+
+func (l BankAccount) Validate() error {
+	v := validator.New()
+	return v.Struct(l)
 }
 
 func (*Transaction) TableName() string {
@@ -26,13 +71,137 @@ func (*Transaction) TableName() string {
 }
 
 func (l Transaction) Validate() error {
+	v := validator.New()
+	if err := v.Struct(l); err != nil {
+		return err
+	}
+	return ValidateCurrencyAmount(l.Currency, l.Amount)
+}
+
+// TransactionFilter holds the query parameters of the transaction list. The
+// cursor is opaque to clients; CursorID is the decoded position.
+type TransactionFilter struct {
+	UserID            int       `form:"-"`
+	TransactionType   string    `form:"transaction_type"`
+	TransactionStatus string    `form:"transaction_status"`
+	Currency          string    `form:"currency"`
+	StartDate         time.Time `form:"start_date" time_format:"2006-01-02"`
+	EndDate           time.Time `form:"end_date" time_format:"2006-01-02"`
+	MinAmount         Money     `form:"min_amount" validate:"gte=0"`
+	MaxAmount         Money     `form:"max_amount" validate:"gte=0"`
+	Sort              string    `form:"sort" validate:"omitempty,oneof=asc desc"`
+	Cursor            string    `form:"cursor"`
+	Limit             int       `form:"limit" validate:"gte=0,lte=100"`
+	CursorID          int       `form:"-"`
+}
+
+func (l TransactionFilter) Validate() error {
 	v := validator.New()
 	return v.Struct(l)
 }
 
+type TransactionList struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+// TransactionExportColumns is the column order of CSV exports, the same as
+// the field order of TransactionExport.
+var TransactionExportColumns = []string{
+	"reference",
+	"parent_reference",
+	"date",
+	"transaction_type",
+	"transaction_status",
+	"direction",
+	"amount",
+	"fee_amount",
+	"currency",
+	"description",
+	"channel",
+	"user_id",
+	"recipient_user_id",
+	"additional_info",
+}
+
+// TransactionExport is a transaction as exported. Amounts are strings with
+// the decimal places of the currency, so they are read back exactly.
+type TransactionExport struct {
+	Reference         string `json:"reference"`
+	ParentReference   string `json:"parent_reference"`
+	Date              string `json:"date"`
+	TransactionType   string `json:"transaction_type"`
+	TransactionStatus string `json:"transaction_status"`
+	Direction         string `json:"direction"`
+	Amount            string `json:"amount"`
+	FeeAmount         string `json:"fee_amount"`
+	Currency          string `json:"currency"`
+	Description       string `json:"description"`
+	Channel           string `json:"channel"`
+	UserID            int    `json:"user_id"`
+	RecipientUserID   int    `json:"recipient_user_id"`
+	AddtionalInfo     string `json:"additional_info"`
+}
+
+func NewTransactionExport(trx Transaction) TransactionExport {
+	currency := trx.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return TransactionExport{
+		Reference:         trx.Reference,
+		ParentReference:   trx.ParentReference,
+		Date:              trx.CreatedAt.Format(time.RFC3339),
+		TransactionType:   trx.TransactionType,
+		TransactionStatus: trx.TransactionStatus,
+		Direction:         trx.Direction,
+		Amount:            trx.Amount.Format(currency),
+		FeeAmount:         trx.FeeAmount.Format(currency),
+		Currency:          currency,
+		Description:       trx.Description,
+		Channel:           trx.Channel,
+		UserID:            trx.UserID,
+		RecipientUserID:   trx.RecipientUserID,
+		AddtionalInfo:     trx.AddtionalInfo,
+	}
+}
+
+// CSVRecord returns the values of e in TransactionExportColumns order. Text
+// that a spreadsheet would run as a formula is prefixed with a quote.
+func (e TransactionExport) CSVRecord() []string {
+	record := []string{
+		e.Reference,
+		e.ParentReference,
+		e.Date,
+		e.TransactionType,
+		e.TransactionStatus,
+		e.Direction,
+		e.Amount,
+		e.FeeAmount,
+		e.Currency,
+		e.Description,
+		e.Channel,
+		strconv.Itoa(e.UserID),
+		strconv.Itoa(e.RecipientUserID),
+		e.AddtionalInfo,
+	}
+	for i := range record {
+		record[i] = escapeCSVFormula(record[i])
+	}
+	return record
+}
+
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
 type CreateTransactionResponse struct {
 	Reference         string `json:"reference"`
 	TransactionStatus string `json:"transaction_status"`
+	FeeAmount         Money  `json:"fee_amount"`
 }
 
 type UpdateStatusTransaction struct {
@@ -47,7 +216,10 @@ func (l UpdateStatusTransaction) Validate() error {
 }
 
 type RefundTransaction struct {
-	Reference     string `json:"reference" validate:"required"`
+	Reference string `json:"reference" validate:"required"`
+	// Amount is optional, the remaining amount of the purchase is refunded
+	// when it is empty.
+	Amount        Money  `json:"amount" validate:"omitempty,gt=0"`
 	Description   string `json:"description" validate:"required"`
 	AddtionalInfo string `json:"additional_info"`
 }
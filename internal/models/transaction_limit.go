@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// TransactionLimit caps the transactions of one type a user can create. The
+// daily and monthly limits count the PENDING, UNDER_REVIEW and SUCCESS
+// transactions created since the start of the day and of the month. A zero
+// field is unlimited.
+//
+// The amounts are in Currency, DefaultCurrency when empty, and only
+// transactions in that currency are summed. Amounts are not converted, so
+// when any amount is limited a transaction in another currency is refused.
+// The counts include transactions in every currency.
+type TransactionLimit struct {
+	Currency      string `json:"currency,omitempty" gorm:"column:currency;type:varchar(3)"`
+	MaxAmount     Money  `json:"max_amount" gorm:"column:max_amount;type:decimal(15,2)" validate:"gte=0"`
+	DailyAmount   Money  `json:"daily_amount" gorm:"column:daily_amount;type:decimal(15,2)" validate:"gte=0"`
+	DailyCount    int    `json:"daily_count" gorm:"column:daily_count" validate:"gte=0"`
+	MonthlyAmount Money  `json:"monthly_amount" gorm:"column:monthly_amount;type:decimal(15,2)" validate:"gte=0"`
+	MonthlyCount  int    `json:"monthly_count" gorm:"column:monthly_count" validate:"gte=0"`
+}
+
+func (l TransactionLimit) Validate() error {
+	v := validator.New()
+	err := v.Struct(l)
+	if err != nil {
+		return err
+	}
+	if _, ok := CurrencyMinorUnits[l.LimitCurrency()]; !ok {
+		return ErrCurrencyInvalid
+	}
+	return nil
+}
+
+// LimitCurrency is the currency of the amounts of l.
+func (l TransactionLimit) LimitCurrency() string {
+	if l.Currency == "" {
+		return DefaultCurrency
+	}
+	return l.Currency
+}
+
+// LimitsAmount reports whether any amount of l is limited.
+func (l TransactionLimit) LimitsAmount() bool {
+	return l.MaxAmount > 0 || l.DailyAmount > 0 || l.MonthlyAmount > 0
+}
+
+// TransactionLimits holds the default limit of each transaction type. Types
+// without an entry are unlimited.
+type TransactionLimits map[string]TransactionLimit
+
+// UserTransactionLimit is a limit set by an admin for one user, used instead
+// of the default limit of its transaction type.
+type UserTransactionLimit struct {
+	ID              int    `json:"-"`
+	UserID          int    `json:"user_id" gorm:"column:user_id;uniqueIndex:idx_user_transaction_limits_user_type"`
+	TransactionType string `json:"transaction_type" gorm:"column:transaction_type;type:varchar(20);uniqueIndex:idx_user_transaction_limits_user_type"`
+	TransactionLimit
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by" gorm:"column:updated_by;type:varchar(255)"`
+}
+
+func (*UserTransactionLimit) TableName() string {
+	return "user_transaction_limits"
+}
+
+// EffectiveTransactionLimit is the limit applied to a user for one
+// transaction type. Override tells whether it was set for the user.
+type EffectiveTransactionLimit struct {
+	TransactionType string `json:"transaction_type"`
+	TransactionLimit
+	Override bool `json:"override"`
+}
+
+// TransactionLimitLock is the row locked while the limits of a user are
+// checked and the transaction is inserted, so concurrent requests of the user
+// are counted one after the other.
+type TransactionLimitLock struct {
+	UserID    int `gorm:"column:user_id;primaryKey;autoIncrement:false"`
+	CreatedAt time.Time
+}
+
+func (*TransactionLimitLock) TableName() string {
+	return "transaction_limit_locks"
+}
+
+// TransactionUsage is what a user created since the start of a limit window:
+// Count in every currency and Amount in the currency of the limit.
+type TransactionUsage struct {
+	Count  int
+	Amount Money
+}
+
+// LimitExceededError is returned when creating a transaction would go over
+// one of the limits of the user. Limit names the field of TransactionLimit,
+// e.g. daily_amount, and Max is its value.
+type LimitExceededError struct {
+	Code            string `json:"code"`
+	TransactionType string `json:"transaction_type"`
+	Limit           string `json:"limit"`
+	Max             string `json:"max"`
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("batas transaksi %s terlampaui: %s maksimal %s", e.TransactionType, e.Limit, e.Max)
+}
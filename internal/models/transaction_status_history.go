@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TransactionStatusHistory records one status change of a transaction. A
+// FromStatus of "" marks the creation of the transaction.
+type TransactionStatusHistory struct {
+	ID                   int       `json:"id"`
+	TransactionReference string    `json:"transaction_reference" gorm:"column:transaction_reference;type:varchar(255);index"`
+	FromStatus           string    `json:"from_status" gorm:"column:from_status;type:varchar(20)"`
+	ToStatus             string    `json:"to_status" gorm:"column:to_status;type:varchar(20)"`
+	ActorUserID          int       `json:"actor_user_id" gorm:"column:actor_user_id"`
+	Actor                string    `json:"actor" gorm:"column:actor;type:varchar(255)"`
+	AdditionalInfo       string    `json:"additional_info" gorm:"column:additional_info;type:text"`
+	CreatedAt            time.Time `json:"date"`
+}
+
+func (*TransactionStatusHistory) TableName() string {
+	return "transaction_status_history"
+}
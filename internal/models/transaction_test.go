@@ -0,0 +1,58 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTransactionExportCSVRecord(t *testing.T) {
+	created := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		trx  Transaction
+		want []string
+	}{
+		{
+			name: "columns in order",
+			trx: Transaction{
+				Reference: "REF1", ParentReference: "REF0", CreatedAt: created,
+				TransactionType: "TRANSFER", TransactionStatus: "SUCCESS", Direction: "OUT",
+				Amount: 1234567, FeeAmount: 250, Currency: "IDR", Description: "rent",
+				Channel: "app", UserID: 1, RecipientUserID: 2, AddtionalInfo: `{"note":"jan"}`,
+			},
+			want: []string{"REF1", "REF0", "2024-01-02T15:04:05Z", "TRANSFER", "SUCCESS", "OUT", "12345.67", "2.50", "IDR", "rent", "app", "1", "2", `{"note":"jan"}`},
+		},
+		{
+			name: "default currency",
+			trx:  Transaction{Reference: "REF1", CreatedAt: created, Amount: 100},
+			want: []string{"REF1", "", "2024-01-02T15:04:05Z", "", "", "", "1.00", "0.00", "IDR", "", "", "0", "0", ""},
+		},
+		{
+			name: "currency without decimals",
+			trx:  Transaction{Reference: "REF1", CreatedAt: created, Amount: 150000, Currency: "JPY"},
+			want: []string{"REF1", "", "2024-01-02T15:04:05Z", "", "", "", "1500", "0", "JPY", "", "", "0", "0", ""},
+		},
+		{
+			name: "formulas are escaped",
+			trx: Transaction{
+				Reference: "REF1", CreatedAt: created, Currency: "IDR",
+				Description: "=HYPERLINK(\"http://x\")", Channel: "@SUM(A1)", AddtionalInfo: "+1", ParentReference: "-2",
+			},
+			want: []string{"REF1", "'-2", "2024-01-02T15:04:05Z", "", "", "", "0.00", "0.00", "IDR", "'=HYPERLINK(\"http://x\")", "'@SUM(A1)", "0", "0", "'+1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewTransactionExport(tt.trx).CSVRecord()
+			if len(got) != len(TransactionExportColumns) {
+				t.Fatalf("CSVRecord() has %d values, want one per column %v", len(got), TransactionExportColumns)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CSVRecord() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Webhook is an endpoint registered by a merchant to be told about status
+// changes of its transactions. A UserID of 0 receives the changes of every
+// user. Empty filters match everything.
+type Webhook struct {
+	ID                  int       `json:"id"`
+	UserID              int       `json:"user_id" gorm:"column:user_id;index"`
+	URL                 string    `json:"url" gorm:"column:url;type:varchar(2048)"`
+	Secret              string    `json:"-" gorm:"column:secret;type:varchar(255)"`
+	TransactionTypes    []string  `json:"transaction_types" gorm:"column:transaction_types;type:text;serializer:json"`
+	TransactionStatuses []string  `json:"transaction_statuses" gorm:"column:transaction_statuses;type:text;serializer:json"`
+	Active              bool      `json:"active" gorm:"column:active;index"`
+	CreatedBy           string    `json:"-" gorm:"column:created_by;type:varchar(255)"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func (*Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Matches reports whether a change of trx to its current status passes the
+// filters of w.
+func (w Webhook) Matches(trx Transaction) bool {
+	return containsOrEmpty(w.TransactionTypes, trx.TransactionType) && containsOrEmpty(w.TransactionStatuses, trx.TransactionStatus)
+}
+
+func containsOrEmpty(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateWebhook struct {
+	URL                 string   `json:"url" validate:"required,url,max=2048"`
+	TransactionTypes    []string `json:"transaction_types"`
+	TransactionStatuses []string `json:"transaction_statuses"`
+	AllUsers            bool     `json:"all_users"`
+}
+
+func (l CreateWebhook) Validate() error {
+	v := validator.New()
+	return v.Struct(l)
+}
+
+// CreateWebhookResponse is the only place the signing secret is shown.
+type CreateWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is one event waiting to be, or already, delivered to a
+// webhook. Rows are written in the same database transaction as the change
+// they announce and double as the delivery log.
+type WebhookDelivery struct {
+	ID                   int       `json:"id"`
+	WebhookID            int       `json:"webhook_id" gorm:"column:webhook_id;index"`
+	TransactionReference string    `json:"transaction_reference" gorm:"column:transaction_reference;type:varchar(255)"`
+	Event                string    `json:"event" gorm:"column:event;type:varchar(255)"`
+	Payload              string    `json:"payload" gorm:"column:payload;type:text"`
+	Status               string    `json:"status" gorm:"column:status;type:enum('PENDING','SENT','DEAD');index:idx_webhook_deliveries_status_next_attempt"`
+	Attempts             int       `json:"attempts" gorm:"column:attempts"`
+	NextAttemptAt        time.Time `json:"next_attempt_at" gorm:"column:next_attempt_at;index:idx_webhook_deliveries_status_next_attempt"`
+	ResponseStatus       int       `json:"response_status" gorm:"column:response_status"`
+	LastError            string    `json:"last_error" gorm:"column:last_error;type:text"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+func (*WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookPayload is the JSON body posted to webhooks. EventID is the same
+// for every attempt so receivers can drop repeated deliveries.
+type WebhookPayload struct {
+	EventID   string             `json:"event_id"`
+	Event     string             `json:"event"`
+	CreatedAt time.Time          `json:"created_at"`
+	Data      WebhookPayloadData `json:"data"`
+}
+
+type WebhookPayloadData struct {
+	Reference         string `json:"reference"`
+	ParentReference   string `json:"parent_reference,omitempty"`
+	UserID            int    `json:"user_id"`
+	TransactionType   string `json:"transaction_type"`
+	PreviousStatus    string `json:"previous_status"`
+	TransactionStatus string `json:"transaction_status"`
+	Amount            Money  `json:"amount"`
+	Currency          string `json:"currency"`
+	Description       string `json:"description"`
+	AdditionalInfo    string `json:"additional_info"`
+}
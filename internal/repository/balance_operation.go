@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BalanceOperationRepo struct {
+	DB *gorm.DB
+}
+
+func (r *BalanceOperationRepo) CreateBalanceOperation(ctx context.Context, op *models.BalanceOperation) error {
+	return getDB(ctx, r.DB).Create(op).Error
+}
+
+func (r *BalanceOperationRepo) UpdateBalanceOperationStatus(ctx context.Context, id int, status, lastError string) error {
+	return getDB(ctx, r.DB).Model(&models.BalanceOperation{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     status,
+		"last_error": lastError,
+	}).Error
+}
+
+func (r *BalanceOperationRepo) GetBalanceOperationsByTransactionReference(ctx context.Context, reference, action string) ([]models.BalanceOperation, error) {
+	var (
+		resp []models.BalanceOperation
+	)
+	err := getDB(ctx, r.DB).Where("transaction_reference = ? AND action = ?", reference, action).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+func (r *BalanceOperationRepo) GetBalanceOperationsByChangeID(ctx context.Context, changeID string) ([]models.BalanceOperation, error) {
+	var (
+		resp []models.BalanceOperation
+	)
+	err := getDB(ctx, r.DB).Where("change_id = ?", changeID).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+func (r *BalanceOperationRepo) GetBalanceOperationsByWalletReference(ctx context.Context, walletReference string) ([]models.BalanceOperation, error) {
+	var (
+		resp []models.BalanceOperation
+	)
+	err := getDB(ctx, r.DB).Where("wallet_reference = ?", walletReference).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+// GetUnfinishedBalanceOperations returns operations in one of statuses that
+// have not been touched since before.
+func (r *BalanceOperationRepo) GetUnfinishedBalanceOperations(ctx context.Context, statuses []string, before time.Time) ([]models.BalanceOperation, error) {
+	var (
+		resp []models.BalanceOperation
+	)
+	err := getDB(ctx, r.DB).Where("status IN ? AND updated_at < ?", statuses, before).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+// ClaimBalanceOperation refreshes updated_at only if the operation has not
+// changed since it was read, so concurrent recovery runs skip it.
+func (r *BalanceOperationRepo) ClaimBalanceOperation(ctx context.Context, op *models.BalanceOperation) (bool, error) {
+	now := time.Now()
+	result := getDB(ctx, r.DB).Model(&models.BalanceOperation{}).
+		Where("id = ? AND status = ? AND updated_at = ?", op.ID, op.Status, op.UpdatedAt).
+		Update("updated_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	op.UpdatedAt = now
+	return result.RowsAffected == 1, nil
+}
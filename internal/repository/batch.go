@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TransactionBatchRepo struct {
+	DB *gorm.DB
+}
+
+func (r *TransactionBatchRepo) CreateTransactionBatch(ctx context.Context, batch *models.TransactionBatch) error {
+	return getDB(ctx, r.DB).Create(batch).Error
+}
+
+func (r *TransactionBatchRepo) CreateTransactionBatchItems(ctx context.Context, items []models.TransactionBatchItem) error {
+	return getDB(ctx, r.DB).CreateInBatches(items, 500).Error
+}
+
+func (r *TransactionBatchRepo) GetTransactionBatch(ctx context.Context, batchID string) (models.TransactionBatch, error) {
+	var (
+		resp models.TransactionBatch
+	)
+	err := getDB(ctx, r.DB).Where("batch_id = ?", batchID).Take(&resp).Error
+	return resp, err
+}
+
+func (r *TransactionBatchRepo) GetTransactionBatchItems(ctx context.Context, id int) ([]models.TransactionBatchItem, error) {
+	var (
+		resp []models.TransactionBatchItem
+	)
+	err := getDB(ctx, r.DB).Where("batch_id = ?", id).Order("line ASC").Find(&resp).Error
+	return resp, err
+}
+
+// GetProcessableTransactionBatches returns the PENDING batches and the ones
+// left PROCESSING since before staleBefore by a worker that stopped.
+func (r *TransactionBatchRepo) GetProcessableTransactionBatches(ctx context.Context, staleBefore time.Time, limit int) ([]models.TransactionBatch, error) {
+	var (
+		resp []models.TransactionBatch
+	)
+	err := getDB(ctx, r.DB).
+		Where("status = ? OR (status = ? AND updated_at < ?)", constants.BatchStatusPending, constants.BatchStatusProcessing, staleBefore).
+		Order("id ASC").Limit(limit).Find(&resp).Error
+	return resp, err
+}
+
+// ClaimTransactionBatch moves batch to PROCESSING only if it has not changed
+// since it was read, so concurrent workers skip it.
+func (r *TransactionBatchRepo) ClaimTransactionBatch(ctx context.Context, batch *models.TransactionBatch) (bool, error) {
+	result := getDB(ctx, r.DB).Model(&models.TransactionBatch{}).
+		Where("id = ? AND status = ? AND updated_at = ?", batch.ID, batch.Status, batch.UpdatedAt).
+		Update("status", constants.BatchStatusProcessing)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateTransactionBatchProgress writes the status and the counters of batch.
+func (r *TransactionBatchRepo) UpdateTransactionBatchProgress(ctx context.Context, batch *models.TransactionBatch) error {
+	return getDB(ctx, r.DB).Model(&models.TransactionBatch{}).
+		Where("id = ?", batch.ID).
+		Updates(map[string]interface{}{
+			"status":          batch.Status,
+			"processed_items": batch.ProcessedItems,
+			"succeeded_items": batch.SucceededItems,
+			"failed_items":    batch.FailedItems,
+		}).Error
+}
+
+func (r *TransactionBatchRepo) UpdateTransactionBatchItem(ctx context.Context, item *models.TransactionBatchItem) error {
+	return getDB(ctx, r.DB).Model(&models.TransactionBatchItem{}).
+		Where("id = ?", item.ID).
+		Updates(map[string]interface{}{
+			"status":             item.Status,
+			"reference":          item.Reference,
+			"transaction_status": item.TransactionStatus,
+			"fee_amount":         item.FeeAmount,
+			"error":              item.Error,
+		}).Error
+}
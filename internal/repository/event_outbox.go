@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EventOutboxRepo struct {
+	DB *gorm.DB
+}
+
+func (r *EventOutboxRepo) CreateEventOutbox(ctx context.Context, event *models.EventOutbox) error {
+	return getDB(ctx, r.DB).Create(event).Error
+}
+
+func (r *EventOutboxRepo) GetDueEventOutbox(ctx context.Context, limit int) ([]models.EventOutbox, error) {
+	var (
+		resp []models.EventOutbox
+	)
+	err := getDB(ctx, r.DB).Where("status = ? AND next_attempt_at <= ?", constants.EventOutboxStatusPending, time.Now()).
+		Order("id ASC").Limit(limit).Find(&resp).Error
+	return resp, err
+}
+
+// ClaimEventOutbox pushes next_attempt_at forward by lease only if the row
+// has not changed since it was read, so concurrent relays skip it.
+func (r *EventOutboxRepo) ClaimEventOutbox(ctx context.Context, event *models.EventOutbox, lease time.Duration) (bool, error) {
+	next := time.Now().Add(lease)
+	result := getDB(ctx, r.DB).Model(&models.EventOutbox{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", event.ID, constants.EventOutboxStatusPending, event.NextAttemptAt).
+		Update("next_attempt_at", next)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	event.NextAttemptAt = next
+	return result.RowsAffected == 1, nil
+}
+
+func (r *EventOutboxRepo) UpdateEventOutbox(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return getDB(ctx, r.DB).Model(&models.EventOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          status,
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	}).Error
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type FraudRepo struct {
+	DB *gorm.DB
+}
+
+func (r *FraudRepo) CreateFraudEvaluation(ctx context.Context, evaluation *models.FraudEvaluation) error {
+	return getDB(ctx, r.DB).Create(evaluation).Error
+}
+
+func (r *FraudRepo) GetFraudEvaluations(ctx context.Context, reference string) ([]models.FraudEvaluation, error) {
+	var (
+		resp []models.FraudEvaluation
+	)
+	err := getDB(ctx, r.DB).Where("transaction_reference = ?", reference).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+// GetPendingFraudReviews returns the oldest evaluations still waiting for an
+// operator.
+func (r *FraudRepo) GetPendingFraudReviews(ctx context.Context, limit, offset int) ([]models.FraudEvaluation, error) {
+	var (
+		resp []models.FraudEvaluation
+	)
+	err := getDB(ctx, r.DB).Where("review_status = ?", constants.FraudReviewStatusPending).
+		Order("id ASC").Limit(limit).Offset(offset).Find(&resp).Error
+	return resp, err
+}
+
+func (r *FraudRepo) GetPendingFraudReview(ctx context.Context, reference string) (models.FraudEvaluation, error) {
+	var (
+		resp models.FraudEvaluation
+	)
+	err := getDB(ctx, r.DB).Where("transaction_reference = ? AND review_status = ?", reference, constants.FraudReviewStatusPending).
+		Order("id DESC").Take(&resp).Error
+	return resp, err
+}
+
+// ClaimFraudReview records the decision on a pending review and reports
+// whether it was still pending.
+func (r *FraudRepo) ClaimFraudReview(ctx context.Context, id int, status, reviewedBy, note string) (bool, error) {
+	result := getDB(ctx, r.DB).Model(&models.FraudEvaluation{}).
+		Where("id = ? AND review_status = ?", id, constants.FraudReviewStatusPending).
+		Updates(map[string]interface{}{
+			"review_status": status,
+			"reviewed_by":   reviewedBy,
+			"reviewed_at":   time.Now(),
+			"review_note":   note,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
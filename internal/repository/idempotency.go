@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepo struct {
+	DB *gorm.DB
+}
+
+func (r *IdempotencyRepo) CreateIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	return r.DB.Create(key).Error
+}
+
+func (r *IdempotencyRepo) GetIdempotencyKey(ctx context.Context, userID int, idempotencyKey string) (models.IdempotencyKey, error) {
+	var (
+		resp models.IdempotencyKey
+	)
+	err := r.DB.Where("user_id = ? AND idempotency_key = ?", userID, idempotencyKey).First(&resp).Error
+	return resp, err
+}
+
+func (r *IdempotencyRepo) UpdateIdempotencyResponse(ctx context.Context, id int, responseCode int, responseBody string) error {
+	return r.DB.Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"response_code": responseCode,
+		"response_body": responseBody,
+	}).Error
+}
+
+// ClaimIdempotencyKey takes over a key whose request never completed and
+// whose lease ran out, it reports false when another request got it first.
+func (r *IdempotencyRepo) ClaimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey, lease time.Duration) (bool, error) {
+	now := time.Now()
+	result := r.DB.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND response_code = 0 AND (locked_until IS NULL OR locked_until < ?)", key.ID, now).
+		Update("locked_until", now.Add(lease))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	key.LockedUntil = now.Add(lease)
+	return result.RowsAffected == 1, nil
+}
+
+// TakeOverExpiredIdempotencyKey reuses the row id of a key past its TTL for
+// key, it reports false when another request took it over or it was purged
+// first.
+func (r *IdempotencyRepo) TakeOverExpiredIdempotencyKey(ctx context.Context, id int, key *models.IdempotencyKey) (bool, error) {
+	result := r.DB.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND expired_at < ?", id, time.Now()).
+		Updates(map[string]interface{}{
+			"request_hash":  key.RequestHash,
+			"response_code": 0,
+			"response_body": "",
+			"locked_until":  key.LockedUntil,
+			"expired_at":    key.ExpiredAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	key.ID = id
+	return result.RowsAffected == 1, nil
+}
+
+// ExtendIdempotencyLease moves the lease of a key whose request has not
+// completed to lease from now.
+func (r *IdempotencyRepo) ExtendIdempotencyLease(ctx context.Context, id int, lease time.Duration) error {
+	return r.DB.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND response_code = 0", id).
+		Update("locked_until", time.Now().Add(lease)).Error
+}
+
+func (r *IdempotencyRepo) DeleteIdempotencyKey(ctx context.Context, id int) error {
+	return r.DB.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+func (r *IdempotencyRepo) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	result := r.DB.Where("expired_at < ?", before).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type NotificationOutboxRepo struct {
+	DB *gorm.DB
+}
+
+func (r *NotificationOutboxRepo) CreateNotificationOutbox(ctx context.Context, notification *models.NotificationOutbox) error {
+	return getDB(ctx, r.DB).Create(notification).Error
+}
+
+func (r *NotificationOutboxRepo) GetDueNotificationOutbox(ctx context.Context, limit int) ([]models.NotificationOutbox, error) {
+	var (
+		resp []models.NotificationOutbox
+	)
+	err := getDB(ctx, r.DB).Where("status = ? AND next_attempt_at <= ?", constants.NotificationOutboxStatusPending, time.Now()).
+		Order("next_attempt_at ASC").Limit(limit).Find(&resp).Error
+	return resp, err
+}
+
+// ClaimNotificationOutbox pushes next_attempt_at forward by lease only if the
+// row has not changed since it was read, so concurrent dispatchers skip it.
+func (r *NotificationOutboxRepo) ClaimNotificationOutbox(ctx context.Context, notification *models.NotificationOutbox, lease time.Duration) (bool, error) {
+	next := time.Now().Add(lease)
+	result := getDB(ctx, r.DB).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", notification.ID, constants.NotificationOutboxStatusPending, notification.NextAttemptAt).
+		Update("next_attempt_at", next)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	notification.NextAttemptAt = next
+	return result.RowsAffected == 1, nil
+}
+
+func (r *NotificationOutboxRepo) UpdateNotificationOutbox(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return getDB(ctx, r.DB).Model(&models.NotificationOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          status,
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	}).Error
+}
+
+func (r *NotificationOutboxRepo) GetNotificationOutboxByStatus(ctx context.Context, status string, limit, offset int) ([]models.NotificationOutbox, error) {
+	var (
+		resp []models.NotificationOutbox
+	)
+	err := getDB(ctx, r.DB).Where("status = ?", status).Order("id DESC").Limit(limit).Offset(offset).Find(&resp).Error
+	return resp, err
+}
+
+// RedriveNotificationOutbox puts a dead notification back in the queue. It
+// returns false when id is not a dead notification.
+func (r *NotificationOutboxRepo) RedriveNotificationOutbox(ctx context.Context, id int) (bool, error) {
+	result := getDB(ctx, r.DB).Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status = ?", id, constants.NotificationOutboxStatusDead).
+		Updates(map[string]interface{}{
+			"status":          constants.NotificationOutboxStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
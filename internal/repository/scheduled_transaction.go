@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ScheduledTransactionRepo struct {
+	DB *gorm.DB
+}
+
+func (r *ScheduledTransactionRepo) CreateScheduledTransaction(ctx context.Context, schedule *models.ScheduledTransaction) error {
+	return getDB(ctx, r.DB).Create(schedule).Error
+}
+
+func (r *ScheduledTransactionRepo) GetScheduledTransaction(ctx context.Context, id int) (models.ScheduledTransaction, error) {
+	var (
+		resp models.ScheduledTransaction
+	)
+	err := getDB(ctx, r.DB).Where("id = ?", id).Take(&resp).Error
+	return resp, err
+}
+
+func (r *ScheduledTransactionRepo) GetScheduledTransactionsByUserID(ctx context.Context, userID int) ([]models.ScheduledTransaction, error) {
+	var (
+		resp []models.ScheduledTransaction
+	)
+	err := getDB(ctx, r.DB).Where("user_id = ?", userID).Order("id DESC").Find(&resp).Error
+	return resp, err
+}
+
+// UpdateScheduledTransactionStatus changes the status and next run of a
+// schedule only while its status is one of fromStatuses, and reports whether
+// it did.
+func (r *ScheduledTransactionRepo) UpdateScheduledTransactionStatus(ctx context.Context, id int, fromStatuses []string, status string, nextRunAt *time.Time, updatedBy string) (bool, error) {
+	result := getDB(ctx, r.DB).Model(&models.ScheduledTransaction{}).
+		Where("id = ? AND status IN ?", id, fromStatuses).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"next_run_at": nextRunAt,
+			"updated_by":  updatedBy,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *ScheduledTransactionRepo) GetDueScheduledTransactions(ctx context.Context, now time.Time, limit int) ([]models.ScheduledTransaction, error) {
+	var (
+		resp []models.ScheduledTransaction
+	)
+	err := getDB(ctx, r.DB).Where("status = ? AND next_run_at <= ?", constants.ScheduleStatusActive, now).
+		Order("next_run_at ASC").Limit(limit).Find(&resp).Error
+	return resp, err
+}
+
+// ClaimScheduledTransaction moves a due schedule to its next run only if it
+// has not changed since it was read, so concurrent runners skip it.
+func (r *ScheduledTransactionRepo) ClaimScheduledTransaction(ctx context.Context, schedule *models.ScheduledTransaction, status string, nextRunAt *time.Time) (bool, error) {
+	result := getDB(ctx, r.DB).Model(&models.ScheduledTransaction{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", schedule.ID, constants.ScheduleStatusActive, schedule.NextRunAt).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"next_run_at": nextRunAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *ScheduledTransactionRepo) CreateScheduledTransactionRun(ctx context.Context, run *models.ScheduledTransactionRun) error {
+	return getDB(ctx, r.DB).Create(run).Error
+}
+
+func (r *ScheduledTransactionRepo) GetScheduledTransactionRuns(ctx context.Context, scheduleID, limit, offset int) ([]models.ScheduledTransactionRun, error) {
+	var (
+		resp []models.ScheduledTransactionRun
+	)
+	err := getDB(ctx, r.DB).Where("scheduled_transaction_id = ?", scheduleID).
+		Order("id DESC").Limit(limit).Offset(offset).Find(&resp).Error
+	return resp, err
+}
@@ -4,16 +4,22 @@ import (
 	"context"
 	"ewallet-transaction/constants"
 	"ewallet-transaction/internal/models"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepo struct {
 	DB *gorm.DB
 }
 
+func (r *TransactionRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTransaction(ctx, r.DB, fn)
+}
+
 func (r *TransactionRepo) CreateTransaction(ctx context.Context, trx *models.Transaction) error {
-	return r.DB.Create(trx).Error
+	return getDB(ctx, r.DB).Create(trx).Error
 }
 
 func (r *TransactionRepo) GetTransactionByReference(ctx context.Context, reference string, includeRefund bool) (models.Transaction, error) {
@@ -21,25 +27,221 @@ func (r *TransactionRepo) GetTransactionByReference(ctx context.Context, referen
 		resp models.Transaction
 	)
 
-	sql := r.DB.Where("reference=?", reference)
+	sql := getDB(ctx, r.DB).Where("reference=?", reference)
 
 	if !includeRefund {
 		sql = sql.Where("transaction_type != ? ", constants.TransactionTypeRefund)
 	}
 
-	err := sql.Last(&resp).Error
+	err := sql.Take(&resp).Error
 
 	return resp, err
 }
 
-func (r *TransactionRepo) UpdateStatusTransaction(ctx context.Context, reference, status, additional_info string) error {
-	return r.DB.Exec("UPDATE transactions SET transaction_status = ?, additional_info = ? WHERE REFERENCE = ?", status, additional_info, reference).Error
+func (r *TransactionRepo) GetTransactionByReferenceForUpdate(ctx context.Context, reference string) (models.Transaction, error) {
+	var (
+		resp models.Transaction
+	)
+
+	err := getDB(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("reference = ? AND transaction_type != ?", reference, constants.TransactionTypeRefund).
+		Take(&resp).Error
+
+	return resp, err
 }
 
-func (r *TransactionRepo) GetTransaction(ctx context.Context, userID int) ([]models.Transaction, error) {
+// GetRefundedAmount sums the successful refunds of a purchase. Refunds made
+// before they were linked through parent_reference are linked on migration.
+func (r *TransactionRepo) GetRefundedAmount(ctx context.Context, reference string) (models.Money, error) {
+	var (
+		resp models.Money
+	)
+
+	err := getDB(ctx, r.DB).Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("transaction_type = ? AND transaction_status = ?", constants.TransactionTypeRefund, constants.TransactionStatusSuccess).
+		Where("parent_reference = ?", reference).
+		Scan(&resp).Error
+
+	return resp, err
+}
+
+// GetPendingTransactionsCreatedBefore returns the oldest PENDING transactions
+// of transactionType created before before.
+func (r *TransactionRepo) GetPendingTransactionsCreatedBefore(ctx context.Context, transactionType string, before time.Time, limit int) ([]models.Transaction, error) {
 	var (
 		resp []models.Transaction
 	)
-	err := r.DB.Debug().Where("user_id = ?", userID).Find(&resp).Order("id DESC").Error
+
+	err := getDB(ctx, r.DB).
+		Where("transaction_status = ? AND transaction_type = ? AND created_at < ?", constants.TransactionStatusPending, transactionType, before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&resp).Error
+
+	return resp, err
+}
+
+// UpdateStatusTransactionFrom updates the status only while it is still
+// fromStatus and reports whether it did.
+func (r *TransactionRepo) UpdateStatusTransactionFrom(ctx context.Context, reference, fromStatus, status, additionalInfo, updatedBy string) (bool, error) {
+	result := getDB(ctx, r.DB).Exec("UPDATE transactions SET transaction_status = ?, additional_info = ?, updated_at = ?, updated_by = ? WHERE reference = ? AND transaction_status = ?", status, additionalInfo, time.Now(), updatedBy, reference, fromStatus)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetTransactionUsage counts the PENDING, UNDER_REVIEW and SUCCESS
+// transactions of transactionType that userID created since since, other
+// than excludeReference, and sums the amounts of those in currency.
+func (r *TransactionRepo) GetTransactionUsage(ctx context.Context, userID int, transactionType, currency string, since time.Time, excludeReference string) (models.TransactionUsage, error) {
+	var (
+		resp models.TransactionUsage
+	)
+
+	err := getDB(ctx, r.DB).Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(CASE WHEN currency = ? THEN amount ELSE 0 END), 0) AS amount", currency).
+		Where("user_id = ? AND transaction_type = ? AND transaction_status IN ? AND created_at >= ? AND reference != ?",
+			userID, transactionType, []string{constants.TransactionStatusPending, constants.TransactionStatusUnderReview, constants.TransactionStatusSuccess}, since, excludeReference).
+		Scan(&resp).Error
+
 	return resp, err
 }
+
+// CountUserTransactionsSince counts the transactions of transactionTypes,
+// or of every type when it is empty, that userID created since since, other
+// than excludeReference.
+func (r *TransactionRepo) CountUserTransactionsSince(ctx context.Context, userID int, transactionTypes []string, since time.Time, excludeReference string) (int64, error) {
+	var (
+		resp int64
+	)
+
+	sql := getDB(ctx, r.DB).Model(&models.Transaction{}).
+		Where("user_id = ? AND created_at >= ? AND reference != ?", userID, since, excludeReference)
+	if len(transactionTypes) > 0 {
+		sql = sql.Where("transaction_type IN ?", transactionTypes)
+	}
+
+	err := sql.Count(&resp).Error
+	return resp, err
+}
+
+// GetFirstTransaction returns the oldest transaction of userID other than
+// excludeReference.
+func (r *TransactionRepo) GetFirstTransaction(ctx context.Context, userID int, excludeReference string) (models.Transaction, error) {
+	var (
+		resp models.Transaction
+	)
+
+	err := getDB(ctx, r.DB).
+		Where("user_id = ? AND reference != ?", userID, excludeReference).
+		Order("id ASC").
+		Take(&resp).Error
+
+	return resp, err
+}
+
+// GetSettledTransactionsCreatedBetween returns the SUCCESS and REVERSED
+// transactions created in [start, end).
+func (r *TransactionRepo) GetSettledTransactionsCreatedBetween(ctx context.Context, start, end time.Time) ([]models.Transaction, error) {
+	var (
+		resp []models.Transaction
+	)
+
+	err := getDB(ctx, r.DB).
+		Where("transaction_status IN ? AND created_at >= ? AND created_at < ?", []string{constants.TransactionStatusSuccess, constants.TransactionStatusReversed}, start, end).
+		Order("id ASC").
+		Find(&resp).Error
+
+	return resp, err
+}
+
+// GetTransaction returns at most filter.Limit transactions made or received
+// by filter.UserID after filter.CursorID in the requested sort order.
+func (r *TransactionRepo) GetTransaction(ctx context.Context, filter models.TransactionFilter) ([]models.Transaction, error) {
+	var (
+		resp []models.Transaction
+	)
+
+	err := transactionFilterQuery(getDB(ctx, r.DB), filter).Limit(filter.Limit).Find(&resp).Error
+	return resp, err
+}
+
+// CountTransactions counts the transactions matching filter, without its
+// Limit.
+func (r *TransactionRepo) CountTransactions(ctx context.Context, filter models.TransactionFilter) (int64, error) {
+	var (
+		resp int64
+	)
+	err := transactionFilterQuery(getDB(ctx, r.DB).WithContext(ctx), filter).Model(&models.Transaction{}).Count(&resp).Error
+	return resp, err
+}
+
+// ExportTransactions calls fn with every transaction matching filter, up to
+// its Limit when set, read from a cursor so the result is never held in
+// memory. It stops at the first error of fn or when ctx is done.
+func (r *TransactionRepo) ExportTransactions(ctx context.Context, filter models.TransactionFilter, fn func(models.Transaction) error) error {
+	db := getDB(ctx, r.DB).WithContext(ctx)
+
+	sql := transactionFilterQuery(db, filter).Model(&models.Transaction{})
+	if filter.Limit > 0 {
+		sql = sql.Limit(filter.Limit)
+	}
+	rows, err := sql.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trx models.Transaction
+		err = db.ScanRows(rows, &trx)
+		if err != nil {
+			return err
+		}
+		err = fn(trx)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// transactionFilterQuery applies filter to db, without its Limit.
+func transactionFilterQuery(db *gorm.DB, filter models.TransactionFilter) *gorm.DB {
+	sql := db.Where("user_id = ? OR recipient_user_id = ?", filter.UserID, filter.UserID)
+
+	if filter.TransactionType != "" {
+		sql = sql.Where("transaction_type = ?", filter.TransactionType)
+	}
+	if filter.TransactionStatus != "" {
+		sql = sql.Where("transaction_status = ?", filter.TransactionStatus)
+	}
+	if filter.Currency != "" {
+		sql = sql.Where("currency = ?", filter.Currency)
+	}
+	if !filter.StartDate.IsZero() {
+		sql = sql.Where("created_at >= ?", filter.StartDate)
+	}
+	if !filter.EndDate.IsZero() {
+		sql = sql.Where("created_at < ?", filter.EndDate.AddDate(0, 0, 1))
+	}
+	if filter.MinAmount > 0 {
+		sql = sql.Where("amount >= ?", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		sql = sql.Where("amount <= ?", filter.MaxAmount)
+	}
+
+	if filter.Sort == "asc" {
+		if filter.CursorID > 0 {
+			sql = sql.Where("id > ?", filter.CursorID)
+		}
+		return sql.Order("id ASC")
+	}
+	if filter.CursorID > 0 {
+		sql = sql.Where("id < ?", filter.CursorID)
+	}
+	return sql.Order("id DESC")
+}
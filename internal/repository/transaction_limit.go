@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TransactionLimitRepo struct {
+	DB *gorm.DB
+}
+
+func (r *TransactionLimitRepo) GetUserTransactionLimits(ctx context.Context, userID int) ([]models.UserTransactionLimit, error) {
+	var (
+		resp []models.UserTransactionLimit
+	)
+	err := getDB(ctx, r.DB).Where("user_id = ?", userID).Order("transaction_type ASC").Find(&resp).Error
+	return resp, err
+}
+
+func (r *TransactionLimitRepo) GetUserTransactionLimit(ctx context.Context, userID int, transactionType string) (models.UserTransactionLimit, error) {
+	var (
+		resp models.UserTransactionLimit
+	)
+	err := getDB(ctx, r.DB).Where("user_id = ? AND transaction_type = ?", userID, transactionType).Take(&resp).Error
+	return resp, err
+}
+
+// UpsertUserTransactionLimit creates the limit of the user for its
+// transaction type or replaces the existing one.
+func (r *TransactionLimitRepo) UpsertUserTransactionLimit(ctx context.Context, limit *models.UserTransactionLimit) error {
+	return getDB(ctx, r.DB).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "transaction_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"currency", "max_amount", "daily_amount", "daily_count", "monthly_amount", "monthly_count", "updated_at", "updated_by"}),
+	}).Create(limit).Error
+}
+
+// DeleteUserTransactionLimit deletes the limit of the user for
+// transactionType and reports whether there was one.
+func (r *TransactionLimitRepo) DeleteUserTransactionLimit(ctx context.Context, userID int, transactionType string) (bool, error) {
+	result := getDB(ctx, r.DB).Where("user_id = ? AND transaction_type = ?", userID, transactionType).Delete(&models.UserTransactionLimit{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// LockUserTransactionLimits locks the limit row of the user, creating it the
+// first time, until the database transaction in ctx ends.
+func (r *TransactionLimitRepo) LockUserTransactionLimits(ctx context.Context, userID int) error {
+	db := getDB(ctx, r.DB)
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TransactionLimitLock{UserID: userID}).Error
+	if err != nil {
+		return err
+	}
+
+	var lock models.TransactionLimitLock
+	return db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).Take(&lock).Error
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type TransactionStatusHistoryRepo struct {
+	DB *gorm.DB
+}
+
+func (r *TransactionStatusHistoryRepo) CreateTransactionStatusHistory(ctx context.Context, history *models.TransactionStatusHistory) error {
+	return getDB(ctx, r.DB).Create(history).Error
+}
+
+func (r *TransactionStatusHistoryRepo) GetTransactionStatusHistory(ctx context.Context, reference string) ([]models.TransactionStatusHistory, error) {
+	var (
+		resp []models.TransactionStatusHistory
+	)
+	err := getDB(ctx, r.DB).Where("transaction_reference = ?", reference).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+// GetTransactionStatusHistories returns the history of every transaction in
+// references, oldest first.
+func (r *TransactionStatusHistoryRepo) GetTransactionStatusHistories(ctx context.Context, references []string) ([]models.TransactionStatusHistory, error) {
+	var (
+		resp []models.TransactionStatusHistory
+	)
+	if len(references) == 0 {
+		return resp, nil
+	}
+	err := getDB(ctx, r.DB).Where("transaction_reference IN ?", references).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepo struct {
+	DB *gorm.DB
+}
+
+func (r *WebhookRepo) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	return getDB(ctx, r.DB).Create(webhook).Error
+}
+
+func (r *WebhookRepo) GetWebhook(ctx context.Context, id int) (models.Webhook, error) {
+	var (
+		resp models.Webhook
+	)
+	err := getDB(ctx, r.DB).Where("id = ?", id).Take(&resp).Error
+	return resp, err
+}
+
+func (r *WebhookRepo) GetWebhooksByUserID(ctx context.Context, userID int) ([]models.Webhook, error) {
+	var (
+		resp []models.Webhook
+	)
+	err := getDB(ctx, r.DB).Where("user_id = ? AND active = ?", userID, true).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+// GetWebhooksForUsers returns the active webhooks of userIDs together with
+// the ones registered for every user.
+func (r *WebhookRepo) GetWebhooksForUsers(ctx context.Context, userIDs []int) ([]models.Webhook, error) {
+	var (
+		resp []models.Webhook
+	)
+	err := getDB(ctx, r.DB).Where("user_id IN ? AND active = ?", append([]int{0}, userIDs...), true).Order("id ASC").Find(&resp).Error
+	return resp, err
+}
+
+func (r *WebhookRepo) DeactivateWebhook(ctx context.Context, id int) error {
+	return getDB(ctx, r.DB).Model(&models.Webhook{}).Where("id = ?", id).Update("active", false).Error
+}
+
+func (r *WebhookRepo) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return getDB(ctx, r.DB).Create(delivery).Error
+}
+
+func (r *WebhookRepo) GetDueWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	var (
+		resp []models.WebhookDelivery
+	)
+	err := getDB(ctx, r.DB).Where("status = ? AND next_attempt_at <= ?", constants.WebhookDeliveryStatusPending, time.Now()).
+		Order("next_attempt_at ASC").Limit(limit).Find(&resp).Error
+	return resp, err
+}
+
+// ClaimWebhookDelivery pushes next_attempt_at forward by lease only if the
+// row has not changed since it was read, so concurrent dispatchers skip it.
+func (r *WebhookRepo) ClaimWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery, lease time.Duration) (bool, error) {
+	next := time.Now().Add(lease)
+	result := getDB(ctx, r.DB).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, constants.WebhookDeliveryStatusPending, delivery.NextAttemptAt).
+		Update("next_attempt_at", next)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	delivery.NextAttemptAt = next
+	return result.RowsAffected == 1, nil
+}
+
+func (r *WebhookRepo) UpdateWebhookDelivery(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, responseStatus int, lastError string) error {
+	return getDB(ctx, r.DB).Model(&models.WebhookDelivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          status,
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"response_status": responseStatus,
+		"last_error":      lastError,
+	}).Error
+}
+
+func (r *WebhookRepo) GetWebhookDeliveries(ctx context.Context, webhookID, limit, offset int) ([]models.WebhookDelivery, error) {
+	var (
+		resp []models.WebhookDelivery
+	)
+	err := getDB(ctx, r.DB).Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Offset(offset).Find(&resp).Error
+	return resp, err
+}
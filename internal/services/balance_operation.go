@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// walletClockSkew widens the window in which recovery looks for the entry of
+// an op in the wallet history, as the clocks of both services may differ.
+const walletClockSkew = 5 * time.Minute
+
+// runBalanceOperation journals op, calls the wallet and then runs finalize in
+// the same database transaction that marks op as completed. When finalize
+// fails the wallet call is compensated so both sides stay in sync.
+func (s *TransactionService) runBalanceOperation(ctx context.Context, token string, op *models.BalanceOperation, finalize func(ctx context.Context) error) error {
+	return s.runBalanceOperations(ctx, []string{token}, []*models.BalanceOperation{op}, finalize)
+}
+
+// runBalanceOperations is runBalanceOperation for a change that makes
+// several wallet calls, like a transfer or a transaction with a fee. All ops
+// are journaled before the first wallet call and called in order with
+// tokens[i].
+func (s *TransactionService) runBalanceOperations(ctx context.Context, tokens []string, ops []*models.BalanceOperation, finalize func(ctx context.Context) error) error {
+	err := s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.journalBalanceOperations(ctx, ops)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.applyBalanceOperations(ctx, tokens, ops, finalize)
+}
+
+// journalBalanceOperations inserts ops as PENDING under one change id.
+func (s *TransactionService) journalBalanceOperations(ctx context.Context, ops []*models.BalanceOperation) error {
+	changeID := s.ReferenceGenerator.Generate()
+	for _, op := range ops {
+		op.ChangeID = changeID
+		op.Status = constants.BalanceOperationStatusPending
+		err := s.BalanceOperationRepo.CreateBalanceOperation(ctx, op)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert balance operation")
+		}
+	}
+	return nil
+}
+
+// applyBalanceOperations is runBalanceOperations for ops that are already
+// journaled as PENDING. When the wallet rejects a call the ops already
+// applied are compensated and the rest are marked FAILED so recovery does not
+// send them. When the outcome of a call is unknown nothing is compensated:
+// the ops are left for RecoverBalanceOperations, which finds out from the
+// wallet history, and ErrBalanceOperationPending is returned.
+func (s *TransactionService) applyBalanceOperations(ctx context.Context, tokens []string, ops []*models.BalanceOperation, finalize func(ctx context.Context) error) error {
+	var err error
+	for i, op := range ops {
+		err = s.sendBalanceOperation(ctx, tokens[i], op)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, external.ErrWalletOutcomeUnknown) {
+			s.setBalanceOperationsStatus(ctx, ops[:i], constants.BalanceOperationStatusApplied, "")
+			s.setBalanceOperationsStatus(ctx, ops[i:i+1], constants.BalanceOperationStatusPending, err.Error())
+			return errors.Wrap(constants.ErrBalanceOperationPending, err.Error())
+		}
+
+		s.setBalanceOperationsStatus(ctx, ops[i:], constants.BalanceOperationStatusFailed, err.Error())
+		for j, applied := range ops[:i] {
+			s.compensateBalanceOperation(context.WithoutCancel(ctx), tokens[j], applied, err)
+		}
+		return errors.Wrap(err, "failed to update balance")
+	}
+
+	err = s.completeBalanceOperations(ctx, ops, finalize)
+	if err != nil {
+		for i, op := range ops {
+			s.compensateBalanceOperation(context.WithoutCancel(ctx), tokens[i], op, err)
+		}
+		return errors.Wrap(err, "failed to finalize balance operations")
+	}
+
+	return nil
+}
+
+func (s *TransactionService) completeBalanceOperations(ctx context.Context, ops []*models.BalanceOperation, finalize func(ctx context.Context) error) error {
+	return s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		err := finalize(ctx)
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			err = s.BalanceOperationRepo.UpdateBalanceOperationStatus(ctx, op.ID, constants.BalanceOperationStatusCompleted, "")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setBalanceOperationsStatus records status on ops. A failure is only logged:
+// the ops keep an unfinished status at worst, which recovery checks again.
+func (s *TransactionService) setBalanceOperationsStatus(ctx context.Context, ops []*models.BalanceOperation, status, lastError string) {
+	for _, op := range ops {
+		op.Status = status
+		err := s.BalanceOperationRepo.UpdateBalanceOperationStatus(context.WithoutCancel(ctx), op.ID, status, lastError)
+		if err != nil {
+			helpers.Logger.Errorf("failed to mark balance operation %d as %s: %v", op.ID, status, err)
+		}
+	}
+}
+
+// sendBalanceOperation makes the wallet call of op. A reference the wallet
+// already has counts as applied when op is the only live op journaled with
+// it, since it then means an earlier call of op got through unanswered.
+func (s *TransactionService) sendBalanceOperation(ctx context.Context, token string, op *models.BalanceOperation) error {
+	err := s.callWallet(ctx, token, op.Operation, op.WalletReference, op)
+	if !errors.Is(err, external.ErrWalletDuplicateReference) {
+		return err
+	}
+
+	owned, errOwner := s.ownsWalletReference(ctx, op)
+	if errOwner != nil {
+		return fmt.Errorf("%w: failed to check owner of wallet reference: %v", external.ErrWalletOutcomeUnknown, errOwner)
+	}
+	if owned {
+		return nil
+	}
+	return err
+}
+
+// ownsWalletReference reports whether no other op that may have reached the
+// wallet was journaled with the wallet reference of op. Only a FAILED op is
+// known not to have.
+func (s *TransactionService) ownsWalletReference(ctx context.Context, op *models.BalanceOperation) (bool, error) {
+	ops, err := s.BalanceOperationRepo.GetBalanceOperationsByWalletReference(ctx, op.WalletReference)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range ops {
+		if ops[i].ID != op.ID && ops[i].Status != constants.BalanceOperationStatusFailed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// compensateBalanceOperation reverses a wallet call whose database change
+// could not be written. If the reversal fails too, op is left APPLIED so
+// RecoverBalanceOperations picks it up later.
+func (s *TransactionService) compensateBalanceOperation(ctx context.Context, token string, op *models.BalanceOperation, cause error) {
+	var (
+		log       = helpers.Logger
+		operation = constants.BalanceOperationCredit
+	)
+
+	if op.Operation == constants.BalanceOperationCredit {
+		operation = constants.BalanceOperationDebit
+	}
+
+	status := constants.BalanceOperationStatusCompensated
+	lastError := cause.Error()
+
+	// only this op reverses its own wallet reference, a duplicate means an
+	// earlier reversal got through
+	err := s.callWallet(ctx, token, operation, "COMPENSATE-"+op.WalletReference, op)
+	if err != nil && !errors.Is(err, external.ErrWalletDuplicateReference) {
+		log.Error("failed to compensate balance operation: ", err)
+		status = constants.BalanceOperationStatusApplied
+		lastError = fmt.Sprintf("%s; compensation failed: %s", lastError, err.Error())
+	}
+
+	op.Status = status
+	err = s.BalanceOperationRepo.UpdateBalanceOperationStatus(ctx, op.ID, status, lastError)
+	if err != nil {
+		log.Error("failed to update balance operation status: ", err)
+	}
+}
+
+func (s *TransactionService) callWallet(ctx context.Context, token, operation, reference string, op *models.BalanceOperation) error {
+	req := external.UpdateBalance{
+		Reference: reference,
+		Amount:    op.Amount,
+		Currency:  op.Currency,
+		UserID:    op.UserID,
+	}
+
+	var err error
+	if operation == constants.BalanceOperationCredit {
+		_, err = s.External.CreditBalance(ctx, token, req)
+	} else {
+		_, err = s.External.DebitBalance(ctx, token, req)
+	}
+
+	return err
+}
+
+// RecoverBalanceOperations finishes or compensates the changes whose ops were
+// left unfinished by a crash, an unknown wallet outcome or a failed
+// compensation. The ops of a change are recovered together: an op whose
+// outcome is unknown is looked up in the wallet history first and only sent
+// again when the wallet has no entry for it. Wallet calls are made with the
+// system token since the user's token is not kept.
+func (s *TransactionService) RecoverBalanceOperations(ctx context.Context) error {
+	var (
+		log   = helpers.Logger
+		token = helpers.GetEnv("WALLET_SYSTEM_TOKEN", "")
+		done  = map[int]bool{}
+	)
+
+	grace, err := time.ParseDuration(helpers.GetEnv("BALANCE_OPERATION_GRACE_PERIOD", "2m"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse balance operation grace period")
+	}
+	before := time.Now().Add(-grace)
+
+	ops, err := s.BalanceOperationRepo.GetUnfinishedBalanceOperations(ctx, []string{
+		constants.BalanceOperationStatusPending,
+		constants.BalanceOperationStatusApplied,
+	}, before)
+	if err != nil {
+		return errors.Wrap(err, "failed to get unfinished balance operations")
+	}
+
+	for i := range ops {
+		if done[ops[i].ID] {
+			continue
+		}
+
+		legs, err := s.balanceOperationLegs(ctx, ops[i])
+		if err != nil {
+			log.Errorf("failed to get the balance operations of the change of %d: %v", ops[i].ID, err)
+			continue
+		}
+		for _, leg := range legs {
+			done[leg.ID] = true
+		}
+
+		s.recoverBalanceOperations(ctx, token, legs, before)
+	}
+
+	return nil
+}
+
+// balanceOperationLegs returns the ops journaled together with op. Ops
+// journaled before change ids were kept are grouped by their payload.
+func (s *TransactionService) balanceOperationLegs(ctx context.Context, op models.BalanceOperation) ([]*models.BalanceOperation, error) {
+	var (
+		ops []models.BalanceOperation
+		err error
+	)
+
+	switch {
+	case op.ChangeID != "":
+		ops, err = s.BalanceOperationRepo.GetBalanceOperationsByChangeID(ctx, op.ChangeID)
+	case op.Action == constants.BalanceOperationActionUpdateStatus || op.Action == constants.BalanceOperationActionCreate:
+		ops = []models.BalanceOperation{op}
+	default:
+		ops, err = s.BalanceOperationRepo.GetBalanceOperationsByTransactionReference(ctx, op.TransactionReference, op.Action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var legs []*models.BalanceOperation
+	for i := range ops {
+		if ops[i].Payload == op.Payload {
+			legs = append(legs, &ops[i])
+		}
+	}
+	return legs, nil
+}
+
+// recoverBalanceOperations recovers the legs of one change. It gives up on
+// the change as soon as a leg is known not to have been applied, and leaves
+// it for the next run while the outcome of a leg is still unknown.
+func (s *TransactionService) recoverBalanceOperations(ctx context.Context, token string, legs []*models.BalanceOperation, before time.Time) {
+	var (
+		log       = helpers.Logger
+		abandoned = false
+	)
+
+	for _, leg := range legs {
+		switch leg.Status {
+		case constants.BalanceOperationStatusFailed, constants.BalanceOperationStatusCompensated:
+			abandoned = true
+		case constants.BalanceOperationStatusPending, constants.BalanceOperationStatusApplied:
+			// another leg was touched recently, the change is still running
+			if !leg.UpdatedAt.Before(before) {
+				return
+			}
+		}
+	}
+
+	for _, leg := range legs {
+		if leg.Status != constants.BalanceOperationStatusPending && leg.Status != constants.BalanceOperationStatusApplied {
+			continue
+		}
+		claimed, err := s.BalanceOperationRepo.ClaimBalanceOperation(ctx, leg)
+		if err != nil {
+			log.Error("failed to claim balance operation: ", err)
+			return
+		}
+		if !claimed {
+			return
+		}
+	}
+
+	for _, leg := range legs {
+		switch leg.Status {
+		case constants.BalanceOperationStatusApplied:
+			compensated, err := s.walletEntryExists(ctx, token, leg, "COMPENSATE-"+leg.WalletReference)
+			if err != nil {
+				log.Errorf("failed to look up the compensation of balance operation %d: %v", leg.ID, err)
+				return
+			}
+			if compensated {
+				s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusCompensated, leg.LastError)
+				abandoned = true
+			}
+		case constants.BalanceOperationStatusPending:
+			applied, err := s.walletEntryExists(ctx, token, leg, leg.WalletReference)
+			if err != nil {
+				log.Errorf("failed to look up balance operation %d: %v", leg.ID, err)
+				return
+			}
+			if !applied {
+				continue
+			}
+			owned, err := s.ownsWalletReference(ctx, leg)
+			if err != nil {
+				log.Errorf("failed to check owner of the wallet reference of balance operation %d: %v", leg.ID, err)
+				return
+			}
+			if owned {
+				s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusApplied, "")
+			} else {
+				s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusFailed, "wallet reference used by another balance operation")
+				abandoned = true
+			}
+		}
+	}
+
+	for _, leg := range legs {
+		if abandoned || leg.Status != constants.BalanceOperationStatusPending {
+			continue
+		}
+		err := s.sendBalanceOperation(ctx, token, leg)
+		if errors.Is(err, external.ErrWalletOutcomeUnknown) {
+			log.Warnf("balance operation %d still has an unknown wallet outcome, retrying later: %v", leg.ID, err)
+			s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusPending, err.Error())
+			return
+		}
+		if err != nil {
+			s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusFailed, err.Error())
+			abandoned = true
+			continue
+		}
+		s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusApplied, "")
+	}
+
+	var applied []*models.BalanceOperation
+	for _, leg := range legs {
+		switch leg.Status {
+		case constants.BalanceOperationStatusPending:
+			s.setBalanceOperationsStatus(ctx, []*models.BalanceOperation{leg}, constants.BalanceOperationStatusFailed, "another balance operation of the change was not applied")
+		case constants.BalanceOperationStatusApplied:
+			applied = append(applied, leg)
+		}
+	}
+	if len(applied) == 0 {
+		return
+	}
+
+	if abandoned {
+		for _, leg := range applied {
+			log.Warnf("compensating balance operation %d, another balance operation of the change was not applied", leg.ID)
+			s.compensateBalanceOperation(ctx, token, leg, errors.New("another balance operation of the change was not applied"))
+		}
+		return
+	}
+
+	err := s.completeBalanceOperations(ctx, applied, func(ctx context.Context) error {
+		return s.resumeBalanceOperation(ctx, applied[0])
+	})
+	if err != nil {
+		for _, leg := range applied {
+			log.Warnf("failed to resume balance operation %d, compensating: %v", leg.ID, err)
+			s.compensateBalanceOperation(ctx, token, leg, err)
+		}
+		return
+	}
+
+	for _, leg := range applied {
+		log.Infof("balance operation %d recovered", leg.ID)
+	}
+}
+
+// walletEntryExists reports whether the wallet history has an entry with
+// reference since op was journaled.
+func (s *TransactionService) walletEntryExists(ctx context.Context, token string, op *models.BalanceOperation, reference string) (bool, error) {
+	entries, err := s.External.GetWalletHistory(ctx, token, external.WalletHistoryRequest{
+		StartTime: op.CreatedAt.Add(-walletClockSkew),
+		EndTime:   time.Now().Add(walletClockSkew),
+		Reference: reference,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get wallet history")
+	}
+
+	// the reference filter narrows the result, it is not relied on
+	for _, entry := range entries {
+		if entry.Reference == reference {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resumeBalanceOperation writes the database change recorded in the payload
+// of op, unless it is already there.
+func (s *TransactionService) resumeBalanceOperation(ctx context.Context, op *models.BalanceOperation) error {
+	switch op.Action {
+	case constants.BalanceOperationActionUpdateStatus, constants.BalanceOperationActionTransfer, constants.BalanceOperationActionFee:
+		var payload updateStatusPayload
+		err := json.Unmarshal([]byte(op.Payload), &payload)
+		if err != nil {
+			return errors.Wrap(err, "failed to unmarshal balance operation payload")
+		}
+
+		trx, err := s.TransactionRepo.GetTransactionByReference(ctx, payload.Reference, false)
+		if err != nil {
+			return errors.Wrap(err, "failed to get transaction")
+		}
+
+		if trx.TransactionStatus == payload.TransactionStatus {
+			return nil
+		}
+
+		fromStatus := payload.FromStatus
+		if fromStatus == "" {
+			fromStatus = trx.TransactionStatus
+		}
+
+		_, err = s.StateMachines.Transition(trx.TransactionType, fromStatus, payload.TransactionStatus)
+		if err != nil {
+			return err
+		}
+
+		if payload.TransactionStatus == constants.TransactionStatusReversed {
+			err = s.checkNotRefunded(ctx, payload.Reference)
+			if err != nil {
+				return err
+			}
+		}
+
+		if payload.FraudReview != nil {
+			err = s.decideFraudReview(ctx, trx, payload.FraudReview)
+			if err != nil {
+				return err
+			}
+		}
+
+		updated, err := s.TransactionRepo.UpdateStatusTransactionFrom(ctx, payload.Reference, fromStatus, payload.TransactionStatus, payload.AddtionalInfo, constants.SystemUsername)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errors.Wrapf(constants.ErrTransactionStatusChanged, "transaction %s is no longer %s", payload.Reference, fromStatus)
+		}
+
+		trx.TransactionStatus = payload.TransactionStatus
+		trx.AddtionalInfo = payload.AddtionalInfo
+
+		return s.recordStatusChange(ctx, systemTokenData(), trx, fromStatus, payload.AdditionalInfoDelta)
+	case constants.BalanceOperationActionRefund:
+		var transaction models.Transaction
+		err := json.Unmarshal([]byte(op.Payload), &transaction)
+		if err != nil {
+			return errors.Wrap(err, "failed to unmarshal balance operation payload")
+		}
+
+		_, err = s.TransactionRepo.GetTransactionByReference(ctx, transaction.Reference, true)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.Wrap(err, "failed to get refund transaction")
+		}
+
+		// the owner's contact is not part of the payload
+		parent, err := s.TransactionRepo.GetTransactionByReference(ctx, op.TransactionReference, false)
+		if err != nil {
+			return errors.Wrap(err, "failed to get refunded transaction")
+		}
+
+		transaction.UserEmail = parent.UserEmail
+		transaction.UserFullName = parent.UserFullName
+		transaction.CreatedBy = constants.SystemUsername
+		transaction.UpdatedBy = constants.SystemUsername
+		transaction.UpdatedAt = transaction.CreatedAt
+
+		err = s.TransactionRepo.CreateTransaction(ctx, &transaction)
+		if err != nil {
+			return err
+		}
+
+		return s.recordStatusChange(ctx, systemTokenData(), transaction, "", transaction.AddtionalInfo)
+	case constants.BalanceOperationActionCreate:
+		var payload createTransactionPayload
+		err := json.Unmarshal([]byte(op.Payload), &payload)
+		if err != nil {
+			return errors.Wrap(err, "failed to unmarshal balance operation payload")
+		}
+
+		_, err = s.TransactionRepo.GetTransactionByReference(ctx, payload.Transaction.Reference, true)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.Wrap(err, "failed to get transaction")
+		}
+
+		transition, err := s.StateMachines.Transition(payload.Transaction.TransactionType, "", payload.Transaction.TransactionStatus)
+		if err != nil {
+			return err
+		}
+
+		transaction := payload.Transaction
+		transaction.UserEmail = payload.UserEmail
+		transaction.UserFullName = payload.UserFullName
+		transaction.CreatedBy = payload.CreatedBy
+		transaction.UpdatedBy = payload.CreatedBy
+
+		err = s.TransactionRepo.CreateTransaction(ctx, &transaction)
+		if err != nil {
+			return err
+		}
+
+		err = s.recordStatusChange(ctx, systemTokenData(), transaction, "", transaction.AddtionalInfo)
+		if err != nil {
+			return err
+		}
+
+		return s.sendNotification(ctx, systemTokenData(), transaction, transition.NotificationTemplate)
+	}
+
+	return fmt.Errorf("unknown balance operation action: %s", op.Action)
+}
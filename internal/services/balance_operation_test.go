@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external"
+	"ewallet-transaction/internal/models"
+)
+
+func newTestWithdrawal() *models.Transaction {
+	return &models.Transaction{
+		UserID:          int(testUser.UserID),
+		Amount:          10000,
+		Currency:        "IDR",
+		TransactionType: constants.TransactionTypeWithdrawal,
+		Description:     "withdrawal",
+		BankAccount:     &models.BankAccount{BankCode: "BCA", AccountNumber: "1234567890", AccountName: "User 1"},
+		UserEmail:       testUser.Email,
+		UserFullName:    testUser.FullName,
+	}
+}
+
+// A withdrawal is only created once its money is held, by the request or,
+// when the wallet did not answer, by recovery.
+func TestCreateWithdrawalWalletOutcome(t *testing.T) {
+	tests := []struct {
+		name             string
+		fault            walletFault
+		wantPending      bool
+		wantCreated      bool
+		wantOps          []string
+		wantEntries      []string
+		wantCreatedAfter bool
+		wantOpsAfter     []string
+		wantEntriesAfter []string
+	}{
+		{
+			name:             "debit applied",
+			wantCreated:      true,
+			wantOps:          []string{"REF0001 COMPLETED"},
+			wantEntries:      []string{"REF0001"},
+			wantCreatedAfter: true,
+			wantOpsAfter:     []string{"REF0001 COMPLETED"},
+			wantEntriesAfter: []string{"REF0001"},
+		},
+		{
+			name:             "debit applied but timed out",
+			fault:            walletTimeoutApplied,
+			wantPending:      true,
+			wantOps:          []string{"REF0001 PENDING"},
+			wantEntries:      []string{"REF0001"},
+			wantCreatedAfter: true,
+			wantOpsAfter:     []string{"REF0001 COMPLETED"},
+			wantEntriesAfter: []string{"REF0001"},
+		},
+		{
+			name:             "debit lost in a timeout",
+			fault:            walletTimeoutLost,
+			wantPending:      true,
+			wantOps:          []string{"REF0001 PENDING"},
+			wantCreatedAfter: true,
+			wantOpsAfter:     []string{"REF0001 COMPLETED"},
+			wantEntriesAfter: []string{"REF0001"},
+		},
+		{
+			name:         "debit rejected",
+			fault:        walletReject,
+			wantOps:      []string{"REF0001 FAILED"},
+			wantOpsAfter: []string{"REF0001 FAILED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			if tt.fault != 0 {
+				f.wallet.faults["REF0001"] = tt.fault
+			}
+
+			resp, err := f.CreateTransaction(ctx, testUser, newTestWithdrawal())
+			switch {
+			case tt.wantPending:
+				if !errors.Is(err, constants.ErrBalanceOperationPending) {
+					t.Fatalf("CreateTransaction() error = %v, want %v", err, constants.ErrBalanceOperationPending)
+				}
+				if resp.Reference != "REF0001" {
+					t.Errorf("CreateTransaction() reference = %q, want REF0001", resp.Reference)
+				}
+			case tt.wantCreated:
+				if err != nil {
+					t.Fatalf("CreateTransaction() error = %v", err)
+				}
+			default:
+				if err == nil || errors.Is(err, constants.ErrBalanceOperationPending) {
+					t.Fatalf("CreateTransaction() error = %v, want a rejection", err)
+				}
+			}
+			assertTransactionCreated(t, f, "REF0001", tt.wantCreated)
+			assertEqual(t, "balance operations", f.balanceOperations.statuses(), tt.wantOps)
+			assertEqual(t, "wallet entries", walletReferences(f.wallet), tt.wantEntries)
+
+			f.balanceOperations.age(time.Hour)
+			err = f.RecoverBalanceOperations(ctx)
+			if err != nil {
+				t.Fatalf("RecoverBalanceOperations() error = %v", err)
+			}
+			assertTransactionCreated(t, f, "REF0001", tt.wantCreatedAfter)
+			assertEqual(t, "balance operations after recovery", f.balanceOperations.statuses(), tt.wantOpsAfter)
+			assertEqual(t, "wallet entries after recovery", walletReferences(f.wallet), tt.wantEntriesAfter)
+		})
+	}
+}
+
+// An op whose outcome is still unknown is kept for the next run.
+func TestRecoverBalanceOperationsStillUnknown(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	f.wallet.faults["REF0001"] = walletTimeoutLost
+
+	_, err := f.CreateTransaction(ctx, testUser, newTestWithdrawal())
+	if !errors.Is(err, constants.ErrBalanceOperationPending) {
+		t.Fatalf("CreateTransaction() error = %v, want %v", err, constants.ErrBalanceOperationPending)
+	}
+
+	f.wallet.faults["REF0001"] = walletTimeoutLost
+	f.balanceOperations.age(time.Hour)
+	err = f.RecoverBalanceOperations(ctx)
+	if err != nil {
+		t.Fatalf("RecoverBalanceOperations() error = %v", err)
+	}
+	assertTransactionCreated(t, f, "REF0001", false)
+	assertEqual(t, "balance operations", f.balanceOperations.statuses(), []string{"REF0001 PENDING"})
+
+	f.balanceOperations.age(time.Hour)
+	err = f.RecoverBalanceOperations(ctx)
+	if err != nil {
+		t.Fatalf("RecoverBalanceOperations() error = %v", err)
+	}
+	assertTransactionCreated(t, f, "REF0001", true)
+	assertEqual(t, "balance operations", f.balanceOperations.statuses(), []string{"REF0001 COMPLETED"})
+	assertEqual(t, "wallet entries", walletReferences(f.wallet), []string{"REF0001"})
+}
+
+func TestSendBalanceOperationDuplicateReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		otherStatus string
+		wantErr     error
+	}{
+		{"no other op", "", nil},
+		{"other op failed", constants.BalanceOperationStatusFailed, nil},
+		{"other op completed", constants.BalanceOperationStatusCompleted, external.ErrWalletDuplicateReference},
+		{"other op pending", constants.BalanceOperationStatusPending, external.ErrWalletDuplicateReference},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			f.wallet.entries = []external.WalletHistoryEntry{{Reference: "TOPUP-1", Date: time.Now()}}
+
+			if tt.otherStatus != "" {
+				other := &models.BalanceOperation{WalletReference: "TOPUP-1", Operation: constants.BalanceOperationCredit}
+				_ = f.balanceOperations.CreateBalanceOperation(ctx, other)
+				_ = f.balanceOperations.UpdateBalanceOperationStatus(ctx, other.ID, tt.otherStatus, "")
+			}
+			op := &models.BalanceOperation{WalletReference: "TOPUP-1", Operation: constants.BalanceOperationCredit, Amount: 1000, Currency: "IDR"}
+			_ = f.balanceOperations.CreateBalanceOperation(ctx, op)
+
+			err := f.sendBalanceOperation(ctx, "", op)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("sendBalanceOperation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type TransactionBatchService struct {
+	TransactionBatchRepo interfaces.ITransactionBatchRepo
+	TransactionRepo      interfaces.ITransactionRepo
+	TransactionService   interfaces.ITransactionService
+	External             interfaces.IExternal
+	ReferenceGenerator   interfaces.IReferenceGenerator
+	StateMachines        models.StateMachines
+
+	// SyncMaxItems is the largest batch processed within the request, larger
+	// ones are left to the batch worker.
+	SyncMaxItems int
+}
+
+// CreateBatch records the batch and its items, then processes it straight
+// away when it is small enough. An ALL_OR_NOTHING batch with an invalid item
+// is failed without creating anything.
+func (s *TransactionBatchService) CreateBatch(ctx context.Context, tokenData models.TokenData, req models.CreateTransactionBatch) (models.TransactionBatch, error) {
+	var (
+		batch   models.TransactionBatch
+		invalid = 0
+	)
+
+	if req.Mode != constants.BatchModeAllOrNothing && req.Mode != constants.BatchModeBestEffort {
+		return batch, constants.ErrInvalidBatch
+	}
+	if len(req.Items) == 0 || len(req.Items) > constants.MaxBatchItems {
+		return batch, constants.ErrInvalidBatch
+	}
+
+	for i := range req.Items {
+		item := &req.Items[i]
+		item.Status = constants.BatchItemStatusPending
+		if item.Error == "" {
+			err := s.prepareBatchItem(tokenData, req.Mode, item)
+			if err != nil {
+				item.Error = err.Error()
+			}
+		}
+		if item.Error != "" {
+			item.Status = constants.BatchItemStatusFailed
+			invalid++
+		}
+	}
+
+	if req.Mode == constants.BatchModeAllOrNothing && invalid > 0 {
+		for i := range req.Items {
+			if req.Items[i].Status == constants.BatchItemStatusPending {
+				req.Items[i].Status = constants.BatchItemStatusFailed
+				req.Items[i].Error = constants.ErrBatchItemCancelled.Error()
+			}
+		}
+	}
+
+	batch = models.TransactionBatch{
+		BatchID:      "BATCH-" + s.ReferenceGenerator.Generate(),
+		UserID:       int(tokenData.UserID),
+		Mode:         req.Mode,
+		Status:       constants.BatchStatusPending,
+		TotalItems:   len(req.Items),
+		UserEmail:    tokenData.Email,
+		UserFullName: tokenData.FullName,
+		CreatedBy:    tokenData.Username,
+	}
+	countBatchItems(&batch, req.Items)
+
+	sync := len(req.Items) <= s.SyncMaxItems
+	switch {
+	case batch.ProcessedItems == batch.TotalItems:
+		finishBatch(&batch)
+	case sync:
+		// claimed from the start so the worker leaves it alone
+		batch.Status = constants.BatchStatusProcessing
+	}
+
+	err := s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		err := s.TransactionBatchRepo.CreateTransactionBatch(ctx, &batch)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert transaction batch")
+		}
+		for i := range req.Items {
+			req.Items[i].BatchID = batch.ID
+		}
+		err = s.TransactionBatchRepo.CreateTransactionBatchItems(ctx, req.Items)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert transaction batch items")
+		}
+		return nil
+	})
+	if err != nil {
+		return batch, err
+	}
+
+	if batch.Status == constants.BatchStatusPending {
+		return batch, nil
+	}
+
+	if batch.Status == constants.BatchStatusProcessing {
+		err = s.processBatch(ctx, tokenData, &batch, req.Items)
+		if err != nil {
+			return batch, err
+		}
+	}
+
+	batch.Items = req.Items
+	return batch, nil
+}
+
+// prepareBatchItem turns the parsed item into the transaction to create and
+// checks it. Only operators and the system create transactions of other
+// users.
+func (s *TransactionBatchService) prepareBatchItem(tokenData models.TokenData, mode string, item *models.TransactionBatchItem) error {
+	req := item.Request
+	trx := models.Transaction{
+		UserID:          req.UserID,
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		TransactionType: req.TransactionType,
+		Description:     req.Description,
+		AddtionalInfo:   req.AddtionalInfo,
+		Channel:         req.Channel,
+		RecipientUserID: req.RecipientUserID,
+		BankAccount:     req.BankAccount,
+	}
+
+	if trx.UserID == 0 {
+		trx.UserID = int(tokenData.UserID)
+	}
+	if trx.UserID != int(tokenData.UserID) && !tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+		return constants.ErrTransactionForbidden
+	}
+	if trx.Currency == "" {
+		trx.Currency = models.DefaultCurrency
+	}
+
+	err := trx.Validate()
+	if err != nil {
+		return fmt.Errorf("%s: %v", constants.ErrFailedBadRequest, err)
+	}
+	if !constants.MapTransactionType[trx.TransactionType] || trx.TransactionType == constants.TransactionTypeRefund {
+		return fmt.Errorf("%s: transaction type %s", constants.ErrFailedBadRequest, trx.TransactionType)
+	}
+	if trx.AddtionalInfo != "" {
+		var additionalInfo map[string]interface{}
+		if json.Unmarshal([]byte(trx.AddtionalInfo), &additionalInfo) != nil {
+			return fmt.Errorf("%s: additional_info must be a JSON object", constants.ErrFailedBadRequest)
+		}
+	}
+
+	// an ALL_OR_NOTHING batch is created in one database transaction, which
+	// cannot take back a wallet call
+	if mode == constants.BatchModeAllOrNothing && s.movesMoneyOnCreation(trx.TransactionType) {
+		return constants.ErrBatchWalletTransaction
+	}
+
+	item.Request = trx
+	return nil
+}
+
+// movesMoneyOnCreation reports whether creating a transaction of
+// transactionType calls the wallet, on its creation or, for transfers, when
+// it is settled right after.
+func (s *TransactionBatchService) movesMoneyOnCreation(transactionType string) bool {
+	if transactionType == constants.TransactionTypeTransfer {
+		return true
+	}
+	transition, err := s.StateMachines.Transition(transactionType, "", constants.TransactionStatusPending)
+	return err == nil && transition.WalletOperation != ""
+}
+
+func (s *TransactionBatchService) GetBatch(ctx context.Context, tokenData models.TokenData, batchID string) (models.TransactionBatch, error) {
+	batch, err := s.TransactionBatchRepo.GetTransactionBatch(ctx, batchID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return batch, constants.ErrBatchNotFound
+	}
+	if err != nil {
+		return batch, errors.Wrap(err, "failed to get transaction batch")
+	}
+
+	// batches of other users are reported as not found
+	if batch.UserID != int(tokenData.UserID) && !tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+		return models.TransactionBatch{}, constants.ErrBatchNotFound
+	}
+
+	batch.Items, err = s.TransactionBatchRepo.GetTransactionBatchItems(ctx, batch.ID)
+	if err != nil {
+		return batch, errors.Wrap(err, "failed to get transaction batch items")
+	}
+
+	return batch, nil
+}
+
+// ProcessPendingBatches processes the batches too large to be processed
+// within their request, and resumes the ones whose worker stopped for longer
+// than BATCH_PROCESSING_TIMEOUT.
+func (s *TransactionBatchService) ProcessPendingBatches(ctx context.Context) error {
+	var (
+		log = helpers.Logger
+	)
+
+	timeout, err := time.ParseDuration(helpers.GetEnv("BATCH_PROCESSING_TIMEOUT", "10m"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse batch processing timeout")
+	}
+
+	batches, err := s.TransactionBatchRepo.GetProcessableTransactionBatches(ctx, time.Now().Add(-timeout), constants.BatchProcessSize)
+	if err != nil {
+		return errors.Wrap(err, "failed to get processable transaction batches")
+	}
+
+	for i := range batches {
+		batch := &batches[i]
+
+		claimed, err := s.TransactionBatchRepo.ClaimTransactionBatch(ctx, batch)
+		if err != nil {
+			log.Error("failed to claim transaction batch: ", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		batch.Status = constants.BatchStatusProcessing
+
+		items, err := s.TransactionBatchRepo.GetTransactionBatchItems(ctx, batch.ID)
+		if err != nil {
+			log.Error("failed to get transaction batch items: ", err)
+			continue
+		}
+
+		// the uploader's token is not kept, the wallet is called with the
+		// system one
+		err = s.processBatch(ctx, systemTokenData(), batch, items)
+		if err != nil {
+			log.Errorf("failed to process transaction batch %s: %v", batch.BatchID, err)
+			continue
+		}
+
+		log.Infof("transaction batch %s processed: %d created, %d failed", batch.BatchID, batch.SucceededItems, batch.FailedItems)
+	}
+
+	return nil
+}
+
+func (s *TransactionBatchService) processBatch(ctx context.Context, tokenData models.TokenData, batch *models.TransactionBatch, items []models.TransactionBatchItem) error {
+	if batch.Mode == constants.BatchModeAllOrNothing {
+		return s.processAllOrNothing(ctx, tokenData, batch, items)
+	}
+	return s.processBestEffort(ctx, tokenData, batch, items)
+}
+
+// processAllOrNothing creates the items in one database transaction, so the
+// first failure rolls back the ones created before it. The results and the
+// final status are written in the same transaction, so a batch whose
+// transactions were created is never left to be processed again.
+func (s *TransactionBatchService) processAllOrNothing(ctx context.Context, tokenData models.TokenData, batch *models.TransactionBatch, items []models.TransactionBatchItem) error {
+	var (
+		failed  = -1
+		results = make([]models.CreateTransactionResponse, len(items))
+	)
+
+	err := s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		// touching the batch locks it until the end, so a worker taking it
+		// for stale waits and then finds it changed
+		err := s.TransactionBatchRepo.UpdateTransactionBatchProgress(ctx, batch)
+		if err != nil {
+			return errors.Wrap(err, "failed to update transaction batch")
+		}
+
+		for i := range items {
+			if items[i].Status != constants.BatchItemStatusPending {
+				continue
+			}
+			results[i], err = s.createBatchItem(ctx, tokenData, batch, items[i])
+			if err != nil {
+				failed = i
+				return err
+			}
+		}
+
+		done := allOrNothingResults(items, results, failed, nil)
+		err = s.saveBatchResults(ctx, batch, done)
+		if err != nil {
+			return err
+		}
+		copy(items, done)
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+	if failed == -1 {
+		helpers.Logger.Errorf("transaction batch %s rolled back: %v", batch.BatchID, err)
+	}
+
+	// nothing was created, the batch is failed in a transaction of its own
+	done := allOrNothingResults(items, results, failed, err)
+	err = s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.saveBatchResults(ctx, batch, done)
+	})
+	if err != nil {
+		return err
+	}
+	copy(items, done)
+	return nil
+}
+
+// allOrNothingResults returns items with the outcome of an ALL_OR_NOTHING
+// batch: every pending item created when err is nil, otherwise the item at
+// failed failed with err and the others cancelled.
+func allOrNothingResults(items []models.TransactionBatchItem, results []models.CreateTransactionResponse, failed int, err error) []models.TransactionBatchItem {
+	done := slices.Clone(items)
+	for i := range done {
+		if done[i].Status != constants.BatchItemStatusPending {
+			continue
+		}
+		switch {
+		case err == nil:
+			setBatchItemResult(&done[i], results[i], nil)
+		case i == failed:
+			setBatchItemResult(&done[i], results[i], err)
+		default:
+			done[i].Status = constants.BatchItemStatusFailed
+			done[i].Error = constants.ErrBatchItemCancelled.Error()
+		}
+	}
+	return done
+}
+
+// saveBatchResults writes the items and the final status of batch.
+func (s *TransactionBatchService) saveBatchResults(ctx context.Context, batch *models.TransactionBatch, items []models.TransactionBatchItem) error {
+	for i := range items {
+		err := s.TransactionBatchRepo.UpdateTransactionBatchItem(ctx, &items[i])
+		if err != nil {
+			return errors.Wrap(err, "failed to update transaction batch item")
+		}
+	}
+
+	countBatchItems(batch, items)
+	finishBatch(batch)
+
+	err := s.TransactionBatchRepo.UpdateTransactionBatchProgress(ctx, batch)
+	if err != nil {
+		return errors.Wrap(err, "failed to update transaction batch")
+	}
+	return nil
+}
+
+// processBestEffort creates the items one by one, saving the progress after
+// each. An item left PROCESSING by a worker that stopped may or may not have
+// been created, so it is failed for the uploader to check instead of being
+// created twice.
+func (s *TransactionBatchService) processBestEffort(ctx context.Context, tokenData models.TokenData, batch *models.TransactionBatch, items []models.TransactionBatchItem) error {
+	for i := range items {
+		item := &items[i]
+
+		switch item.Status {
+		case constants.BatchItemStatusProcessing:
+			item.Status = constants.BatchItemStatusFailed
+			item.Error = constants.ErrBatchItemInterrupted.Error()
+		case constants.BatchItemStatusPending:
+			item.Status = constants.BatchItemStatusProcessing
+			err := s.TransactionBatchRepo.UpdateTransactionBatchItem(ctx, item)
+			if err != nil {
+				return errors.Wrap(err, "failed to update transaction batch item")
+			}
+
+			resp, err := s.createBatchItem(ctx, tokenData, batch, *item)
+			setBatchItemResult(item, resp, err)
+		default:
+			continue
+		}
+
+		err := s.TransactionBatchRepo.UpdateTransactionBatchItem(ctx, item)
+		if err != nil {
+			return errors.Wrap(err, "failed to update transaction batch item")
+		}
+
+		countBatchItems(batch, items)
+		err = s.TransactionBatchRepo.UpdateTransactionBatchProgress(ctx, batch)
+		if err != nil {
+			return errors.Wrap(err, "failed to update transaction batch")
+		}
+	}
+
+	countBatchItems(batch, items)
+	finishBatch(batch)
+
+	return s.TransactionBatchRepo.UpdateTransactionBatchProgress(ctx, batch)
+}
+
+// createBatchItem creates the transaction of item, tagging it with the batch
+// in its additional_info.
+func (s *TransactionBatchService) createBatchItem(ctx context.Context, tokenData models.TokenData, batch *models.TransactionBatch, item models.TransactionBatchItem) (models.CreateTransactionResponse, error) {
+	batchInfo, err := json.Marshal(map[string]interface{}{
+		"batch_id":   batch.BatchID,
+		"batch_line": item.Line,
+	})
+	if err != nil {
+		return models.CreateTransactionResponse{}, errors.Wrap(err, "failed to marshal batch info")
+	}
+
+	additionalInfo, err := mergeAdditionalInfo(item.Request.AddtionalInfo, string(batchInfo))
+	if err != nil {
+		return models.CreateTransactionResponse{}, err
+	}
+
+	trx := item.Request
+	trx.AddtionalInfo = string(additionalInfo)
+	trx.CreatedBy = batch.CreatedBy
+	trx.UpdatedBy = batch.CreatedBy
+
+	if trx.UserID == batch.UserID {
+		trx.UserEmail = batch.UserEmail
+		trx.UserFullName = batch.UserFullName
+	} else {
+		user, err := s.External.GetUser(ctx, trx.UserID)
+		if err != nil {
+			return models.CreateTransactionResponse{}, err
+		}
+		trx.UserEmail = user.Email
+		trx.UserFullName = user.FullName
+	}
+
+	return s.TransactionService.CreateTransaction(ctx, tokenData, &trx)
+}
+
+// setBatchItemResult records the outcome of creating item. Only errors meant
+// for the caller are kept, the others are logged.
+func setBatchItemResult(item *models.TransactionBatchItem, resp models.CreateTransactionResponse, err error) {
+	// a pending wallet call is finished by recovery, the transaction is
+	// followed with its reference
+	if err == nil || errors.Is(err, constants.ErrBalanceOperationPending) {
+		item.Status = constants.BatchItemStatusSuccess
+		item.Reference = resp.Reference
+		item.TransactionStatus = resp.TransactionStatus
+		item.FeeAmount = resp.FeeAmount
+		item.Error = ""
+		if err != nil {
+			item.Error = constants.ErrBalanceOperationPending.Error()
+		}
+		return
+	}
+
+	item.Status = constants.BatchItemStatusFailed
+	item.Error = constants.ErrServerError
+
+	var (
+		invalidTransition *models.InvalidTransitionError
+		limitExceeded     *models.LimitExceededError
+	)
+	for _, known := range []error{constants.ErrInvalidTransferRecipient, constants.ErrInvalidBankAccount, constants.ErrUserNotFound} {
+		if errors.Is(err, known) {
+			item.Error = err.Error()
+			return
+		}
+	}
+	if errors.As(err, &invalidTransition) || errors.As(err, &limitExceeded) {
+		item.Error = err.Error()
+		return
+	}
+
+	helpers.Logger.Errorf("failed to create line %d of transaction batch %d: %v", item.Line, item.BatchID, err)
+}
+
+func countBatchItems(batch *models.TransactionBatch, items []models.TransactionBatchItem) {
+	batch.SucceededItems, batch.FailedItems = 0, 0
+	for i := range items {
+		switch items[i].Status {
+		case constants.BatchItemStatusSuccess:
+			batch.SucceededItems++
+		case constants.BatchItemStatusFailed:
+			batch.FailedItems++
+		}
+	}
+	batch.ProcessedItems = batch.SucceededItems + batch.FailedItems
+}
+
+// finishBatch sets the final status of batch: an ALL_OR_NOTHING batch with a
+// failed item is FAILED, any other batch COMPLETED.
+func finishBatch(batch *models.TransactionBatch) {
+	batch.Status = constants.BatchStatusCompleted
+	if batch.Mode == constants.BatchModeAllOrNothing && batch.FailedItems > 0 {
+		batch.Status = constants.BatchStatusFailed
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+)
+
+// fakeTransactionBatchRepo keeps the batches and items in memory. While
+// failWrites is set, writing an item fails.
+type fakeTransactionBatchRepo struct {
+	interfaces.ITransactionBatchRepo
+	batches    []models.TransactionBatch
+	items      []models.TransactionBatchItem
+	failWrites bool
+}
+
+func (r *fakeTransactionBatchRepo) CreateTransactionBatch(ctx context.Context, batch *models.TransactionBatch) error {
+	batch.ID = len(r.batches) + 1
+	batch.UpdatedAt = time.Now()
+	r.batches = append(r.batches, *batch)
+	return nil
+}
+
+func (r *fakeTransactionBatchRepo) CreateTransactionBatchItems(ctx context.Context, items []models.TransactionBatchItem) error {
+	for i := range items {
+		items[i].ID = len(r.items) + 1
+		r.items = append(r.items, items[i])
+	}
+	return nil
+}
+
+func (r *fakeTransactionBatchRepo) GetTransactionBatchItems(ctx context.Context, id int) ([]models.TransactionBatchItem, error) {
+	var resp []models.TransactionBatchItem
+	for _, item := range r.items {
+		if item.BatchID == id {
+			resp = append(resp, item)
+		}
+	}
+	return resp, nil
+}
+
+func (r *fakeTransactionBatchRepo) GetProcessableTransactionBatches(ctx context.Context, staleBefore time.Time, limit int) ([]models.TransactionBatch, error) {
+	var resp []models.TransactionBatch
+	for _, batch := range r.batches {
+		stale := batch.Status == constants.BatchStatusProcessing && batch.UpdatedAt.Before(staleBefore)
+		if batch.Status == constants.BatchStatusPending || stale {
+			resp = append(resp, batch)
+		}
+	}
+	return resp, nil
+}
+
+func (r *fakeTransactionBatchRepo) ClaimTransactionBatch(ctx context.Context, batch *models.TransactionBatch) (bool, error) {
+	row := &r.batches[batch.ID-1]
+	if row.Status != batch.Status || !row.UpdatedAt.Equal(batch.UpdatedAt) {
+		return false, nil
+	}
+	row.Status = constants.BatchStatusProcessing
+	row.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *fakeTransactionBatchRepo) UpdateTransactionBatchProgress(ctx context.Context, batch *models.TransactionBatch) error {
+	row := &r.batches[batch.ID-1]
+	row.Status = batch.Status
+	row.ProcessedItems, row.SucceededItems, row.FailedItems = batch.ProcessedItems, batch.SucceededItems, batch.FailedItems
+	row.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *fakeTransactionBatchRepo) UpdateTransactionBatchItem(ctx context.Context, item *models.TransactionBatchItem) error {
+	if r.failWrites {
+		return errors.New("connection lost")
+	}
+	r.items[item.ID-1] = *item
+	return nil
+}
+
+// age moves the batches back by d, as if their worker stopped d ago.
+func (r *fakeTransactionBatchRepo) age(d time.Duration) {
+	for i := range r.batches {
+		r.batches[i].UpdatedAt = r.batches[i].UpdatedAt.Add(-d)
+	}
+}
+
+// An ALL_OR_NOTHING batch whose worker stopped before its results were
+// written is re-claimed, and creates its transactions only once.
+func TestProcessPendingBatchesReclaimed(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	repo := &fakeTransactionBatchRepo{}
+	s := &TransactionBatchService{
+		TransactionBatchRepo: repo,
+		TransactionRepo:      f.transactions,
+		TransactionService:   f.TransactionService,
+		External:             f.wallet,
+		ReferenceGenerator:   &fakeReferenceGenerator{},
+		StateMachines:        DefaultStateMachines,
+	}
+
+	item := func(line int) models.TransactionBatchItem {
+		return models.TransactionBatchItem{Line: line, Request: models.Transaction{
+			Amount:          10000,
+			TransactionType: constants.TransactionTypePurchase,
+			Description:     "purchase",
+		}}
+	}
+	batch, err := s.CreateBatch(ctx, testUser, models.CreateTransactionBatch{
+		Mode:  constants.BatchModeAllOrNothing,
+		Items: []models.TransactionBatchItem{item(1), item(2)},
+	})
+	if err != nil {
+		t.Fatalf("CreateBatch() error = %v", err)
+	}
+	if batch.Status != constants.BatchStatusPending {
+		t.Fatalf("batch is %s, want %s", batch.Status, constants.BatchStatusPending)
+	}
+
+	// the worker loses the database while writing the results
+	repo.failWrites = true
+	err = s.ProcessPendingBatches(ctx)
+	if err != nil {
+		t.Fatalf("ProcessPendingBatches() error = %v", err)
+	}
+	if len(f.transactions.rows) != 0 {
+		t.Fatalf("%d transactions created, want the batch rolled back", len(f.transactions.rows))
+	}
+	if status := repo.batches[0].Status; status != constants.BatchStatusProcessing {
+		t.Fatalf("batch is %s, want it left %s", status, constants.BatchStatusProcessing)
+	}
+
+	repo.failWrites = false
+	for run := 0; run < 2; run++ {
+		repo.age(time.Hour)
+		err = s.ProcessPendingBatches(ctx)
+		if err != nil {
+			t.Fatalf("ProcessPendingBatches() error = %v", err)
+		}
+	}
+
+	if len(f.transactions.rows) != 2 {
+		t.Errorf("%d transactions created, want 2", len(f.transactions.rows))
+	}
+	if got := repo.batches[0]; got.Status != constants.BatchStatusCompleted || got.SucceededItems != 2 {
+		t.Errorf("batch is %s with %d succeeded items, want %s with 2", got.Status, got.SucceededItems, constants.BatchStatusCompleted)
+	}
+	for _, item := range repo.items {
+		if item.Status != constants.BatchItemStatusSuccess || f.transactions.rows[item.Reference] == nil {
+			t.Errorf("line %d is %s with reference %q, want a created transaction", item.Line, item.Status, item.Reference)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// publishStatusChange emits the events for trx having moved from fromStatus
+// to its current status. A fromStatus of "" means trx was just created.
+// historyID is the status history row of the change, a transaction can go
+// through the same status more than once so it is what tells changes apart.
+func (s *TransactionService) publishStatusChange(ctx context.Context, tokenData models.TokenData, trx models.Transaction, fromStatus string, historyID int) error {
+	var (
+		events []models.Event
+	)
+
+	if fromStatus == "" {
+		event, err := models.NewEvent(trx.Reference+":created", models.EventTransactionCreated, trx.Reference, models.TransactionCreatedData{
+			Reference:         trx.Reference,
+			ParentReference:   trx.ParentReference,
+			UserID:            trx.UserID,
+			TransactionType:   trx.TransactionType,
+			TransactionStatus: trx.TransactionStatus,
+			Amount:            trx.Amount,
+			Currency:          trx.Currency,
+			Description:       trx.Description,
+			Actor:             tokenData.Username,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to create event")
+		}
+		events = append(events, event)
+
+		if trx.TransactionType == constants.TransactionTypeRefund {
+			event, err := models.NewEvent(trx.Reference+":refunded", models.EventTransactionRefunded, trx.ParentReference, models.TransactionRefundedData{
+				Reference:       trx.Reference,
+				ParentReference: trx.ParentReference,
+				UserID:          trx.UserID,
+				Amount:          trx.Amount,
+				Currency:        trx.Currency,
+				Actor:           tokenData.Username,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to create event")
+			}
+			events = append(events, event)
+		}
+	} else {
+		event, err := models.NewEvent(trx.Reference+":"+strconv.Itoa(historyID), models.EventTransactionStatusChanged, trx.Reference, models.TransactionStatusChangedData{
+			Reference:         trx.Reference,
+			UserID:            trx.UserID,
+			TransactionType:   trx.TransactionType,
+			PreviousStatus:    fromStatus,
+			TransactionStatus: trx.TransactionStatus,
+			Amount:            trx.Amount,
+			Currency:          trx.Currency,
+			AdditionalInfo:    trx.AddtionalInfo,
+			Actor:             tokenData.Username,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to create event")
+		}
+		events = append(events, event)
+	}
+
+	for i := range events {
+		err := s.EventPublisher.Publish(ctx, events[i])
+		if err != nil {
+			return errors.Wrap(err, "failed to publish event")
+		}
+	}
+
+	return nil
+}
+
+type EventRelayService struct {
+	EventOutboxRepo interfaces.IEventOutboxRepo
+	Publisher       interfaces.IEventPublisher
+}
+
+// RelayEvents forwards due outbox events to Publisher. Failed events are
+// retried with exponential backoff until EVENT_MAX_ATTEMPTS is reached, then
+// the event is marked DEAD.
+func (s *EventRelayService) RelayEvents(ctx context.Context) error {
+	var (
+		log = helpers.Logger
+	)
+
+	maxAttempts, err := strconv.Atoi(helpers.GetEnv("EVENT_MAX_ATTEMPTS", "10"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse event max attempts")
+	}
+
+	backoff, err := time.ParseDuration(helpers.GetEnv("EVENT_RETRY_BACKOFF", "10s"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse event retry backoff")
+	}
+
+	maxBackoff, err := time.ParseDuration(helpers.GetEnv("EVENT_RETRY_MAX_BACKOFF", "1h"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse event retry max backoff")
+	}
+
+	events, err := s.EventOutboxRepo.GetDueEventOutbox(ctx, 100)
+	if err != nil {
+		return errors.Wrap(err, "failed to get due events")
+	}
+
+	for i := range events {
+		outbox := &events[i]
+
+		claimed, err := s.EventOutboxRepo.ClaimEventOutbox(ctx, outbox, time.Minute)
+		if err != nil {
+			log.Error("failed to claim event: ", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		attempts := outbox.Attempts + 1
+		status := constants.EventOutboxStatusPublished
+		nextAttemptAt := outbox.NextAttemptAt
+		lastError := ""
+
+		var event models.Event
+		err = json.Unmarshal([]byte(outbox.Payload), &event)
+		if err == nil {
+			err = s.Publisher.Publish(ctx, event)
+		}
+		if err != nil {
+			lastError = err.Error()
+			status = constants.EventOutboxStatusPending
+			nextAttemptAt = time.Now().Add(retryBackoff(backoff, maxBackoff, attempts))
+			if attempts >= maxAttempts {
+				status = constants.EventOutboxStatusDead
+				log.Errorf("event %s is dead after %d attempts: %v", outbox.EventID, attempts, err)
+			} else {
+				log.Warnf("failed to publish event %s, attempt %d: %v", outbox.EventID, attempts, err)
+			}
+		}
+
+		err = s.EventOutboxRepo.UpdateEventOutbox(ctx, outbox.ID, status, attempts, nextAttemptAt, lastError)
+		if err != nil {
+			log.Error("failed to update event: ", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/events"
+	"ewallet-transaction/internal/models"
+	"testing"
+	"time"
+)
+
+type fakeEventOutboxRepo struct {
+	rows []models.EventOutbox
+}
+
+func (r *fakeEventOutboxRepo) CreateEventOutbox(ctx context.Context, event *models.EventOutbox) error {
+	event.ID = len(r.rows) + 1
+	r.rows = append(r.rows, *event)
+	return nil
+}
+
+func (r *fakeEventOutboxRepo) GetDueEventOutbox(ctx context.Context, limit int) ([]models.EventOutbox, error) {
+	var due []models.EventOutbox
+	for _, row := range r.rows {
+		if row.Status == constants.EventOutboxStatusPending && !row.NextAttemptAt.After(time.Now()) {
+			due = append(due, row)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeEventOutboxRepo) ClaimEventOutbox(ctx context.Context, event *models.EventOutbox, lease time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeEventOutboxRepo) UpdateEventOutbox(ctx context.Context, id int, status string, attempts int, nextAttemptAt time.Time, lastError string) error {
+	row := &r.rows[id-1]
+	row.Status, row.Attempts, row.NextAttemptAt, row.LastError = status, attempts, nextAttemptAt, lastError
+	return nil
+}
+
+// A transaction can go through the same status twice, each change needs its
+// own event ID or the second one is rejected by the outbox.
+func TestPublishStatusChangeUniqueIDs(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	trx := models.Transaction{Reference: "REF0001", TransactionStatus: constants.TransactionStatusPending}
+
+	for _, fromStatus := range []string{constants.TransactionStatusPending, constants.TransactionStatusPending} {
+		err := f.recordStatusChange(ctx, testOperator, trx, fromStatus, "")
+		if err != nil {
+			t.Fatalf("recordStatusChange() error = %v", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, event := range f.events.events {
+		if seen[event.ID] {
+			t.Errorf("event ID %s published twice", event.ID)
+		}
+		seen[event.ID] = true
+	}
+}
+
+func TestRelayEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		subscribe  bool
+		wantStatus string
+		wantCalls  int
+	}{
+		{"subscriber", true, constants.EventOutboxStatusPublished, 1},
+		{"no subscriber", false, constants.EventOutboxStatusPending, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := &fakeEventOutboxRepo{}
+			bus := events.NewInProcessPublisher()
+			calls := 0
+			if tt.subscribe {
+				bus.Subscribe(models.EventTransactionStatusChanged, func(ctx context.Context, event models.Event) error {
+					calls++
+					return nil
+				})
+			}
+
+			event, _ := models.NewEvent("REF0001:1", models.EventTransactionStatusChanged, "REF0001", nil)
+			err := (&events.OutboxPublisher{EventOutboxRepo: repo}).Publish(ctx, event)
+			if err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			if calls != 0 {
+				t.Fatalf("handler called before the relay")
+			}
+
+			err = (&EventRelayService{EventOutboxRepo: repo, Publisher: bus}).RelayEvents(ctx)
+			if err != nil {
+				t.Fatalf("RelayEvents() error = %v", err)
+			}
+			if repo.rows[0].Status != tt.wantStatus {
+				t.Errorf("outbox status = %s, want %s", repo.rows[0].Status, tt.wantStatus)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// expiryDefaultTTL is how long a transaction of each type may stay PENDING,
+// overridden by TRANSACTION_EXPIRY_TTL_<TYPE>. A TTL of 0 turns expiry off
+// for the type.
+var expiryDefaultTTL = map[string]string{
+	constants.TransactionTypeTopup:    "24h",
+	constants.TransactionTypePurchase: "1h",
+	constants.TransactionTypeTransfer: "15m",
+}
+
+// ExpirePendingTransactions moves transactions that stayed PENDING longer than
+// the TTL of their type to FAILED and notifies their owner.
+func (s *TransactionService) ExpirePendingTransactions(ctx context.Context) error {
+	var (
+		log     = helpers.Logger
+		expired = 0
+	)
+
+	for _, transactionType := range []string{constants.TransactionTypeTopup, constants.TransactionTypePurchase, constants.TransactionTypeTransfer} {
+		envKey := "TRANSACTION_EXPIRY_TTL_" + transactionType
+		ttl, err := time.ParseDuration(helpers.GetEnv(envKey, expiryDefaultTTL[transactionType]))
+		if err != nil {
+			return errors.Wrap(err, "failed to parse "+envKey)
+		}
+		if ttl <= 0 {
+			continue
+		}
+
+		transition, err := s.StateMachines.Transition(transactionType, constants.TransactionStatusPending, constants.TransactionStatusFailed)
+		if err != nil || transition.WalletOperation != "" {
+			log.Warnf("%s transactions cannot expire, the state machine needs a transition from %s to %s without a wallet operation", transactionType, constants.TransactionStatusPending, constants.TransactionStatusFailed)
+			continue
+		}
+
+		transactions, err := s.TransactionRepo.GetPendingTransactionsCreatedBefore(ctx, transactionType, time.Now().Add(-ttl), constants.ExpiryBatchSize)
+		if err != nil {
+			return errors.Wrap(err, "failed to get pending transactions")
+		}
+
+		for i := range transactions {
+			ok, err := s.expireTransaction(ctx, transactions[i], ttl, transition)
+			if err != nil {
+				log.Errorf("failed to expire transaction %s: %v", transactions[i].Reference, err)
+				continue
+			}
+			if ok {
+				expired++
+			}
+		}
+	}
+
+	log.Infof("expired %d pending transactions", expired)
+
+	return nil
+}
+
+// expireTransaction fails trx unless its status changed since it was read,
+// e.g. because the caller reported the result in the meantime.
+func (s *TransactionService) expireTransaction(ctx context.Context, trx models.Transaction, ttl time.Duration, transition models.StateTransition) (bool, error) {
+	reason, err := json.Marshal(map[string]interface{}{
+		"failure_reason": fmt.Sprintf("transaction expired after staying %s for %s", constants.TransactionStatusPending, ttl),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to marshal expiry reason")
+	}
+
+	additionalInfo, err := mergeAdditionalInfo(trx.AddtionalInfo, string(reason))
+	if err != nil {
+		return false, err
+	}
+
+	tokenData := systemTokenData()
+
+	var expired bool
+	err = s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		expired, err = s.TransactionRepo.UpdateStatusTransactionFrom(ctx, trx.Reference, constants.TransactionStatusPending, constants.TransactionStatusFailed, string(additionalInfo), tokenData.Username)
+		if err != nil || !expired {
+			return err
+		}
+
+		trx.TransactionStatus = constants.TransactionStatusFailed
+		trx.AddtionalInfo = string(additionalInfo)
+
+		err = s.recordStatusChange(ctx, tokenData, trx, constants.TransactionStatusPending, string(reason))
+		if err != nil {
+			return err
+		}
+
+		return s.sendNotification(ctx, tokenData, trx, transition.NotificationTemplate)
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update status transaction")
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// The fakes below keep their rows in memory. They only implement the methods
+// the tests reach, the embedded interfaces panic on the others.
+
+func init() {
+	if helpers.Logger == nil {
+		helpers.SetupLogger()
+	}
+	helpers.Logger.SetOutput(io.Discard)
+}
+
+// fakeTransactionRepo rolls the transactions back when the function given to
+// WithTransaction fails, like the database would.
+type fakeTransactionRepo struct {
+	interfaces.ITransactionRepo
+	rows  map[string]*models.Transaction
+	seq   int
+	depth int
+}
+
+func newFakeTransactionRepo() *fakeTransactionRepo {
+	return &fakeTransactionRepo{rows: map[string]*models.Transaction{}}
+}
+
+func (r *fakeTransactionRepo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	snapshot := map[string]models.Transaction{}
+	for reference, trx := range r.rows {
+		snapshot[reference] = *trx
+	}
+
+	r.depth++
+	err := fn(ctx)
+	r.depth--
+
+	if err != nil && r.depth == 0 {
+		r.rows = map[string]*models.Transaction{}
+		for reference, trx := range snapshot {
+			trx := trx
+			r.rows[reference] = &trx
+		}
+	}
+	return err
+}
+
+func (r *fakeTransactionRepo) CreateTransaction(ctx context.Context, trx *models.Transaction) error {
+	if _, ok := r.rows[trx.Reference]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	r.seq++
+	trx.ID = r.seq
+	if trx.CreatedAt.IsZero() {
+		trx.CreatedAt = time.Now()
+	}
+	row := *trx
+	r.rows[trx.Reference] = &row
+	return nil
+}
+
+func (r *fakeTransactionRepo) GetTransactionByReference(ctx context.Context, reference string, includeRefund bool) (models.Transaction, error) {
+	trx, ok := r.rows[reference]
+	if !ok {
+		return models.Transaction{}, gorm.ErrRecordNotFound
+	}
+	return *trx, nil
+}
+
+func (r *fakeTransactionRepo) GetTransactionByReferenceForUpdate(ctx context.Context, reference string) (models.Transaction, error) {
+	return r.GetTransactionByReference(ctx, reference, false)
+}
+
+func (r *fakeTransactionRepo) GetRefundedAmount(ctx context.Context, reference string) (models.Money, error) {
+	var refunded models.Money
+	for _, trx := range r.rows {
+		if trx.TransactionType == constants.TransactionTypeRefund && trx.ParentReference == reference && trx.TransactionStatus == constants.TransactionStatusSuccess {
+			refunded += trx.Amount
+		}
+	}
+	return refunded, nil
+}
+
+func (r *fakeTransactionRepo) GetFirstTransaction(ctx context.Context, userID int, excludeReference string) (models.Transaction, error) {
+	var first *models.Transaction
+	for _, trx := range r.rows {
+		if trx.UserID == userID && trx.Reference != excludeReference && (first == nil || trx.CreatedAt.Before(first.CreatedAt)) {
+			first = trx
+		}
+	}
+	if first == nil {
+		return models.Transaction{}, gorm.ErrRecordNotFound
+	}
+	return *first, nil
+}
+
+func (r *fakeTransactionRepo) UpdateStatusTransactionFrom(ctx context.Context, reference, fromStatus, status, additionalInfo, updatedBy string) (bool, error) {
+	trx, ok := r.rows[reference]
+	if !ok || trx.TransactionStatus != fromStatus {
+		return false, nil
+	}
+	trx.TransactionStatus = status
+	trx.AddtionalInfo = additionalInfo
+	trx.UpdatedBy = updatedBy
+	return true, nil
+}
+
+func (r *fakeTransactionRepo) GetSettledTransactionsCreatedBetween(ctx context.Context, start, end time.Time) ([]models.Transaction, error) {
+	var resp []models.Transaction
+	for _, trx := range r.rows {
+		settled := trx.TransactionStatus == constants.TransactionStatusSuccess || trx.TransactionStatus == constants.TransactionStatusReversed
+		if settled && !trx.CreatedAt.Before(start) && trx.CreatedAt.Before(end) {
+			resp = append(resp, *trx)
+		}
+	}
+	slices.SortFunc(resp, func(a, b models.Transaction) int { return a.ID - b.ID })
+	return resp, nil
+}
+
+// CountTransactions and ExportTransactions only filter on the user.
+func (r *fakeTransactionRepo) CountTransactions(ctx context.Context, filter models.TransactionFilter) (int64, error) {
+	var count int64
+	_ = r.ExportTransactions(ctx, filter, func(models.Transaction) error {
+		count++
+		return nil
+	})
+	return count, nil
+}
+
+func (r *fakeTransactionRepo) ExportTransactions(ctx context.Context, filter models.TransactionFilter, fn func(models.Transaction) error) error {
+	var rows []models.Transaction
+	for _, trx := range r.rows {
+		if trx.UserID == filter.UserID || trx.RecipientUserID == filter.UserID {
+			rows = append(rows, *trx)
+		}
+	}
+	slices.SortFunc(rows, func(a, b models.Transaction) int { return b.ID - a.ID })
+	if filter.Limit > 0 && len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+	}
+	for _, trx := range rows {
+		err := fn(trx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeTransactionRepo) GetTransactionUsage(ctx context.Context, userID int, transactionType, currency string, since time.Time, excludeReference string) (models.TransactionUsage, error) {
+	var usage models.TransactionUsage
+	for _, trx := range r.rows {
+		if trx.UserID != userID || trx.TransactionType != transactionType || trx.CreatedAt.Before(since) || trx.Reference == excludeReference {
+			continue
+		}
+		switch trx.TransactionStatus {
+		case constants.TransactionStatusPending, constants.TransactionStatusUnderReview, constants.TransactionStatusSuccess:
+			usage.Count++
+			if trx.Currency == currency {
+				usage.Amount += trx.Amount
+			}
+		}
+	}
+	return usage, nil
+}
+
+// fakeBalanceOperationRepo keeps copies of the ops, so a test sees what was
+// written and not what the service holds.
+type fakeBalanceOperationRepo struct {
+	interfaces.IBalanceOperationRepo
+	ops []models.BalanceOperation
+}
+
+func (r *fakeBalanceOperationRepo) CreateBalanceOperation(ctx context.Context, op *models.BalanceOperation) error {
+	now := time.Now()
+	op.ID = len(r.ops) + 1
+	op.CreatedAt, op.UpdatedAt = now, now
+	r.ops = append(r.ops, *op)
+	return nil
+}
+
+func (r *fakeBalanceOperationRepo) UpdateBalanceOperationStatus(ctx context.Context, id int, status, lastError string) error {
+	r.ops[id-1].Status = status
+	r.ops[id-1].LastError = lastError
+	r.ops[id-1].UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *fakeBalanceOperationRepo) GetBalanceOperationsByTransactionReference(ctx context.Context, reference, action string) ([]models.BalanceOperation, error) {
+	return r.find(func(op models.BalanceOperation) bool {
+		return op.TransactionReference == reference && op.Action == action
+	}), nil
+}
+
+func (r *fakeBalanceOperationRepo) GetBalanceOperationsByChangeID(ctx context.Context, changeID string) ([]models.BalanceOperation, error) {
+	return r.find(func(op models.BalanceOperation) bool {
+		return op.ChangeID == changeID
+	}), nil
+}
+
+func (r *fakeBalanceOperationRepo) GetBalanceOperationsByWalletReference(ctx context.Context, walletReference string) ([]models.BalanceOperation, error) {
+	return r.find(func(op models.BalanceOperation) bool {
+		return op.WalletReference == walletReference
+	}), nil
+}
+
+func (r *fakeBalanceOperationRepo) GetUnfinishedBalanceOperations(ctx context.Context, statuses []string, before time.Time) ([]models.BalanceOperation, error) {
+	return r.find(func(op models.BalanceOperation) bool {
+		return slices.Contains(statuses, op.Status) && op.UpdatedAt.Before(before)
+	}), nil
+}
+
+func (r *fakeBalanceOperationRepo) ClaimBalanceOperation(ctx context.Context, op *models.BalanceOperation) (bool, error) {
+	row := &r.ops[op.ID-1]
+	if row.Status != op.Status || !row.UpdatedAt.Equal(op.UpdatedAt) {
+		return false, nil
+	}
+	row.UpdatedAt = time.Now()
+	op.UpdatedAt = row.UpdatedAt
+	return true, nil
+}
+
+func (r *fakeBalanceOperationRepo) find(match func(op models.BalanceOperation) bool) []models.BalanceOperation {
+	var resp []models.BalanceOperation
+	for _, op := range r.ops {
+		if match(op) {
+			resp = append(resp, op)
+		}
+	}
+	return resp
+}
+
+// age moves every op back by d, as if recovery ran d later.
+func (r *fakeBalanceOperationRepo) age(d time.Duration) {
+	for i := range r.ops {
+		r.ops[i].CreatedAt = r.ops[i].CreatedAt.Add(-d)
+		r.ops[i].UpdatedAt = r.ops[i].UpdatedAt.Add(-d)
+	}
+}
+
+func (r *fakeBalanceOperationRepo) statuses() []string {
+	var resp []string
+	for _, op := range r.ops {
+		resp = append(resp, op.WalletReference+" "+op.Status)
+	}
+	return resp
+}
+
+// walletFault is how the fake wallet answers the next call with a reference.
+type walletFault int
+
+const (
+	// walletReject rejects the call without applying it.
+	walletReject walletFault = iota + 1
+	// walletTimeoutApplied applies the call but answers like a timeout.
+	walletTimeoutApplied
+	// walletTimeoutLost answers like a timeout without applying the call.
+	walletTimeoutLost
+)
+
+// fakeWallet applies credits and debits like the wallet service: a reference
+// is only applied once and a duplicate is answered with
+// ErrWalletDuplicateReference.
+type fakeWallet struct {
+	interfaces.IExternal
+	entries []external.WalletHistoryEntry
+	faults  map[string]walletFault
+	calls   []string
+	// onCall, when set, runs before each call is answered
+	onCall func(reference string)
+}
+
+func newFakeWallet() *fakeWallet {
+	return &fakeWallet{faults: map[string]walletFault{}}
+}
+
+func (w *fakeWallet) GetUser(ctx context.Context, userID int) (external.User, error) {
+	return external.User{ID: userID, Email: fmt.Sprintf("user%d@example.com", userID), FullName: fmt.Sprintf("User %d", userID)}, nil
+}
+
+func (w *fakeWallet) CreditBalance(ctx context.Context, token string, req external.UpdateBalance) (*external.UpdateBalanceResponse, error) {
+	return w.updateBalance("CREDIT", req)
+}
+
+func (w *fakeWallet) DebitBalance(ctx context.Context, token string, req external.UpdateBalance) (*external.UpdateBalanceResponse, error) {
+	return w.updateBalance("DEBIT", req)
+}
+
+func (w *fakeWallet) updateBalance(walletTransactionType string, req external.UpdateBalance) (*external.UpdateBalanceResponse, error) {
+	w.calls = append(w.calls, walletTransactionType+" "+req.Reference)
+	if w.onCall != nil {
+		w.onCall(req.Reference)
+	}
+
+	fault := w.faults[req.Reference]
+	delete(w.faults, req.Reference)
+
+	switch fault {
+	case walletReject:
+		return nil, fmt.Errorf("got error response from wallet service: %d", 400)
+	case walletTimeoutLost:
+		return nil, fmt.Errorf("%w: timeout", external.ErrWalletOutcomeUnknown)
+	}
+
+	if w.hasEntry(req.Reference) {
+		return nil, external.ErrWalletDuplicateReference
+	}
+	w.entries = append(w.entries, external.WalletHistoryEntry{
+		Reference:             req.Reference,
+		Amount:                req.Amount,
+		Currency:              req.Currency,
+		WalletTransactionType: walletTransactionType,
+		UserID:                req.UserID,
+		Date:                  time.Now(),
+	})
+
+	if fault == walletTimeoutApplied {
+		return nil, fmt.Errorf("%w: timeout", external.ErrWalletOutcomeUnknown)
+	}
+	return &external.UpdateBalanceResponse{Message: "success"}, nil
+}
+
+func (w *fakeWallet) GetWalletHistory(ctx context.Context, token string, req external.WalletHistoryRequest) ([]external.WalletHistoryEntry, error) {
+	var resp []external.WalletHistoryEntry
+	for _, entry := range w.entries {
+		if req.Reference != "" && entry.Reference != req.Reference {
+			continue
+		}
+		if !entry.Date.Before(req.StartTime) && entry.Date.Before(req.EndTime) {
+			resp = append(resp, entry)
+		}
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) hasEntry(reference string) bool {
+	for _, entry := range w.entries {
+		if entry.Reference == reference {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeOutbox takes the notifications, status history rows and webhooks of a
+// change.
+type fakeOutbox struct {
+	interfaces.INotificationOutboxRepo
+	interfaces.ITransactionStatusHistoryRepo
+	interfaces.IWebhookRepo
+	notifications []models.NotificationOutbox
+	history       []models.TransactionStatusHistory
+	webhooks      []models.Webhook
+	deliveries    []models.WebhookDelivery
+}
+
+func (o *fakeOutbox) CreateNotificationOutbox(ctx context.Context, notification *models.NotificationOutbox) error {
+	o.notifications = append(o.notifications, *notification)
+	return nil
+}
+
+func (o *fakeOutbox) CreateTransactionStatusHistory(ctx context.Context, history *models.TransactionStatusHistory) error {
+	history.ID = len(o.history) + 1
+	o.history = append(o.history, *history)
+	return nil
+}
+
+func (o *fakeOutbox) GetTransactionStatusHistory(ctx context.Context, reference string) ([]models.TransactionStatusHistory, error) {
+	return o.GetTransactionStatusHistories(ctx, []string{reference})
+}
+
+func (o *fakeOutbox) GetTransactionStatusHistories(ctx context.Context, references []string) ([]models.TransactionStatusHistory, error) {
+	var resp []models.TransactionStatusHistory
+	for _, history := range o.history {
+		if slices.Contains(references, history.TransactionReference) {
+			resp = append(resp, history)
+		}
+	}
+	return resp, nil
+}
+
+func (o *fakeOutbox) GetWebhooksForUsers(ctx context.Context, userIDs []int) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	for _, webhook := range o.webhooks {
+		if webhook.UserID == 0 || slices.Contains(userIDs, webhook.UserID) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (o *fakeOutbox) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	o.deliveries = append(o.deliveries, *delivery)
+	return nil
+}
+
+type fakeEventPublisher struct {
+	events []models.Event
+}
+
+func (p *fakeEventPublisher) Publish(ctx context.Context, event models.Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+type fakeReferenceGenerator struct {
+	n int
+}
+
+func (g *fakeReferenceGenerator) Generate() string {
+	g.n++
+	return fmt.Sprintf("REF%04d", g.n)
+}
+
+type fakeTransactionLimitRepo struct {
+	interfaces.ITransactionLimitRepo
+}
+
+func (fakeTransactionLimitRepo) LockUserTransactionLimits(ctx context.Context, userID int) error {
+	return nil
+}
+
+func (fakeTransactionLimitRepo) GetUserTransactionLimit(ctx context.Context, userID int, transactionType string) (models.UserTransactionLimit, error) {
+	return models.UserTransactionLimit{}, gorm.ErrRecordNotFound
+}
+
+type fakeFraudRepo struct {
+	interfaces.IFraudRepo
+	evaluations []models.FraudEvaluation
+}
+
+func (r *fakeFraudRepo) CreateFraudEvaluation(ctx context.Context, evaluation *models.FraudEvaluation) error {
+	evaluation.ID = len(r.evaluations) + 1
+	r.evaluations = append(r.evaluations, *evaluation)
+	return nil
+}
+
+func (r *fakeFraudRepo) GetPendingFraudReview(ctx context.Context, reference string) (models.FraudEvaluation, error) {
+	for i := len(r.evaluations) - 1; i >= 0; i-- {
+		if r.evaluations[i].TransactionReference == reference && r.evaluations[i].ReviewStatus == constants.FraudReviewStatusPending {
+			return r.evaluations[i], nil
+		}
+	}
+	return models.FraudEvaluation{}, gorm.ErrRecordNotFound
+}
+
+func (r *fakeFraudRepo) ClaimFraudReview(ctx context.Context, id int, status, reviewedBy, note string) (bool, error) {
+	evaluation := &r.evaluations[id-1]
+	if evaluation.ReviewStatus != constants.FraudReviewStatusPending {
+		return false, nil
+	}
+	evaluation.ReviewStatus = status
+	return true, nil
+}
+
+// fakeTransactionService wires a TransactionService to the fakes.
+type fakeTransactionService struct {
+	*TransactionService
+	transactions      *fakeTransactionRepo
+	balanceOperations *fakeBalanceOperationRepo
+	wallet            *fakeWallet
+	outbox            *fakeOutbox
+	events            *fakeEventPublisher
+	fraud             *fakeFraudRepo
+}
+
+func newFakeTransactionService() *fakeTransactionService {
+	f := &fakeTransactionService{
+		transactions:      newFakeTransactionRepo(),
+		balanceOperations: &fakeBalanceOperationRepo{},
+		wallet:            newFakeWallet(),
+		outbox:            &fakeOutbox{},
+		events:            &fakeEventPublisher{},
+		fraud:             &fakeFraudRepo{},
+	}
+	f.TransactionService = &TransactionService{
+		TransactionRepo:              f.transactions,
+		BalanceOperationRepo:         f.balanceOperations,
+		NotificationOutboxRepo:       f.outbox,
+		External:                     f.wallet,
+		ReferenceGenerator:           &fakeReferenceGenerator{},
+		TransactionStatusHistoryRepo: f.outbox,
+		WebhookRepo:                  f.outbox,
+		EventPublisher:               f.events,
+		StateMachines:                DefaultStateMachines,
+		TransactionLimitService: &TransactionLimitService{
+			TransactionLimitRepo: fakeTransactionLimitRepo{},
+			TransactionRepo:      f.transactions,
+			Location:             time.UTC,
+		},
+		FraudService: &FraudService{FraudRepo: f.fraud, TransactionRepo: f.transactions},
+		FraudRepo:    f.fraud,
+	}
+	return f
+}
+
+var (
+	testUser     = models.TokenData{UserID: 1, Username: "user1", Email: "user1@example.com", FullName: "User 1", Token: "user-token", Roles: []string{constants.RoleUser}}
+	testOperator = models.TokenData{UserID: 99, Username: "operator", Roles: []string{constants.RoleOperator}}
+)
+
+func assertTransactionStatus(t *testing.T, f *fakeTransactionService, reference, want string) {
+	t.Helper()
+	trx, err := f.transactions.GetTransactionByReference(context.Background(), reference, true)
+	if err != nil {
+		t.Fatalf("GetTransactionByReference(%s) error = %v", reference, err)
+	}
+	if trx.TransactionStatus != want {
+		t.Errorf("transaction %s is %s, want %s", reference, trx.TransactionStatus, want)
+	}
+}
+
+func assertTransactionCreated(t *testing.T, f *fakeTransactionService, reference string, want bool) {
+	t.Helper()
+	_, err := f.transactions.GetTransactionByReference(context.Background(), reference, true)
+	if got := err == nil; got != want {
+		t.Errorf("transaction %s created = %v, want %v", reference, got, want)
+	}
+}
+
+func assertEqual[T comparable](t *testing.T, what string, got, want []T) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func walletReferences(w *fakeWallet) []string {
+	var resp []string
+	for _, entry := range w.entries {
+		resp = append(resp, entry.Reference)
+	}
+	return resp
+}
+
+// walletEntries lists the entries of w as operation, reference and amount.
+func walletEntries(w *fakeWallet) []string {
+	var resp []string
+	for _, entry := range w.entries {
+		resp = append(resp, entry.WalletTransactionType+" "+entry.Reference+" "+entry.Amount.String())
+	}
+	return resp
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultFeeRules is used when FEE_RULES_FILE is not set, no fee is charged.
+// fee_rules.example.json shows the format of the file.
+var DefaultFeeRules = []models.FeeRule{}
+
+// LoadFeeRules reads the fee rules from the JSON file at path, or returns
+// DefaultFeeRules when path is empty. The first rule that matches a
+// transaction is used, so narrower rules go first.
+func LoadFeeRules(path string) ([]models.FeeRule, error) {
+	if path == "" {
+		return DefaultFeeRules, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fee rule file")
+	}
+
+	var rules []models.FeeRule
+	err = json.Unmarshal(b, &rules)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse fee rule file")
+	}
+
+	names := map[string]bool{}
+	for _, rule := range rules {
+		err = validateFeeRule(rule)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid fee rule file")
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("invalid fee rule file: duplicate rule %s", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return rules, nil
+}
+
+func validateFeeRule(rule models.FeeRule) error {
+	if rule.Name == "" {
+		return errors.New("rule without name")
+	}
+	// refunds return part of the fee of the purchase instead
+	if !constants.MapTransactionType[rule.TransactionType] || rule.TransactionType == constants.TransactionTypeRefund {
+		return fmt.Errorf("%s: invalid transaction type %q", rule.Name, rule.TransactionType)
+	}
+	if rule.Currency != "" {
+		if _, ok := models.CurrencyMinorUnits[rule.Currency]; !ok {
+			return fmt.Errorf("%s: unknown currency %s", rule.Name, rule.Currency)
+		}
+	}
+	if rule.MinAmount < 0 || rule.MaxAmount < 0 || rule.Flat < 0 || rule.PercentageBps < 0 || rule.MinFee < 0 || rule.MaxFee < 0 {
+		return fmt.Errorf("%s: negative parameter", rule.Name)
+	}
+	if rule.MaxAmount > 0 && rule.MaxAmount < rule.MinAmount {
+		return fmt.Errorf("%s: max_amount is below min_amount", rule.Name)
+	}
+	if rule.MaxFee > 0 && rule.MaxFee < rule.MinFee {
+		return fmt.Errorf("%s: max_fee is below min_fee", rule.Name)
+	}
+	return nil
+}
+
+// calculateFee returns the fee of trx under the first rule that matches it,
+// zero without a rule.
+func calculateFee(rules []models.FeeRule, trx models.Transaction) (models.Money, string) {
+	for _, rule := range rules {
+		if rule.Matches(trx) {
+			return rule.Fee(trx.Amount, trx.Currency), rule.Name
+		}
+	}
+	return 0, ""
+}
+
+// returnedFee is the part of the fee of trx returned once refunded of its
+// amount has been refunded. Each refund returns the difference, so rounding
+// never returns more or less than the whole fee.
+func returnedFee(trx models.Transaction, refunded models.Money) models.Money {
+	if trx.FeeAmount == 0 || trx.Amount == 0 {
+		return 0
+	}
+	return trx.FeeAmount.MulRatio(int64(refunded), int64(trx.Amount)).Round(trx.Currency)
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"ewallet-transaction/internal/models"
+)
+
+func TestReturnedFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		trx      models.Transaction
+		refunded models.Money
+		want     models.Money
+	}{
+		{"no fee", models.Transaction{Amount: 10000, Currency: "IDR"}, 5000, 0},
+		{"half", models.Transaction{Amount: 10000, FeeAmount: 700, Currency: "IDR"}, 5000, 350},
+		{"full", models.Transaction{Amount: 10000, FeeAmount: 700, Currency: "IDR"}, 10000, 700},
+		{"rounded to whole yen", models.Transaction{Amount: 30000, FeeAmount: 1000, Currency: "JPY"}, 10000, 300},
+		// a 1e9 fee on a 1e11 purchase overflowed int64 before
+		{"large amounts", models.Transaction{Amount: 10000000000000, FeeAmount: 100000000000, Currency: "IDR"}, 10000000000000, 100000000000},
+		{"large partial", models.Transaction{Amount: 10000000000000, FeeAmount: 100000000000, Currency: "IDR"}, 3333333333333, 33333333333},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := returnedFee(tt.trx, tt.refunded)
+			if got != tt.want {
+				t.Errorf("returnedFee() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
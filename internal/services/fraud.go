@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DefaultFraudRules is used when FRAUD_RULES_FILE is not set, nothing is
+// flagged. fraud_rules.example.json shows the format of the file.
+var DefaultFraudRules = []models.FraudRule{}
+
+// LoadFraudRules reads the fraud rules from the JSON file at path. Rules
+// without an action hold the transactions they flag for review.
+func LoadFraudRules(path string) ([]models.FraudRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fraud rule file")
+	}
+
+	var rules []models.FraudRule
+	err = json.Unmarshal(b, &rules)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse fraud rule file")
+	}
+
+	names := map[string]bool{}
+	for i := range rules {
+		if rules[i].Action == "" {
+			rules[i].Action = constants.FraudRuleActionReview
+		}
+		err = validateFraudRule(rules[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid fraud rule file")
+		}
+		if names[rules[i].Name] {
+			return nil, fmt.Errorf("invalid fraud rule file: duplicate rule %s", rules[i].Name)
+		}
+		names[rules[i].Name] = true
+	}
+
+	return rules, nil
+}
+
+func validateFraudRule(rule models.FraudRule) error {
+	if rule.Name == "" {
+		return errors.New("rule without name")
+	}
+	if rule.Action != constants.FraudRuleActionReview && rule.Action != constants.FraudRuleActionLog {
+		return fmt.Errorf("%s: unknown action %s", rule.Name, rule.Action)
+	}
+	for _, transactionType := range append(append([]string{}, rule.TransactionTypes...), rule.PreviousTransactionTypes...) {
+		if !constants.MapTransactionType[transactionType] {
+			return fmt.Errorf("%s: unknown transaction type %s", rule.Name, transactionType)
+		}
+	}
+	for _, status := range rule.Statuses {
+		if !constants.MapTransactionStatus[status] || status == constants.TransactionStatusUnderReview {
+			return fmt.Errorf("%s: invalid status %s", rule.Name, status)
+		}
+	}
+	if rule.Currency != "" {
+		if _, ok := models.CurrencyMinorUnits[rule.Currency]; !ok {
+			return fmt.Errorf("%s: unknown currency %s", rule.Name, rule.Currency)
+		}
+	}
+	if rule.Amount < 0 || rule.Count < 0 || rule.Window < 0 {
+		return fmt.Errorf("%s: negative parameter", rule.Name)
+	}
+
+	switch rule.Condition {
+	case constants.FraudConditionAmountOver:
+		if rule.Amount == 0 {
+			return fmt.Errorf("%s: %s needs an amount", rule.Name, rule.Condition)
+		}
+	case constants.FraudConditionVelocity:
+		if rule.Count == 0 || rule.Window == 0 {
+			return fmt.Errorf("%s: %s needs a count and a window", rule.Name, rule.Condition)
+		}
+	case constants.FraudConditionAfterTransaction:
+		if rule.Window == 0 || len(rule.PreviousTransactionTypes) == 0 {
+			return fmt.Errorf("%s: %s needs a window and previous transaction types", rule.Name, rule.Condition)
+		}
+	case constants.FraudConditionNewUser:
+		if rule.Window == 0 {
+			return fmt.Errorf("%s: %s needs a window", rule.Name, rule.Condition)
+		}
+	default:
+		return fmt.Errorf("%s: unknown condition %s", rule.Name, rule.Condition)
+	}
+
+	return nil
+}
+
+type FraudService struct {
+	FraudRepo       interfaces.IFraudRepo
+	TransactionRepo interfaces.ITransactionRepo
+
+	// RulesFile is reloaded by ReloadRules whenever it is modified. The
+	// DefaultFraudRules are used when it is empty.
+	RulesFile string
+
+	reloadMu     sync.Mutex
+	rules        atomic.Pointer[[]models.FraudRule]
+	rulesModTime time.Time
+}
+
+// ReloadRules loads RulesFile if it changed since the last load. The rules
+// in use are kept when the file is invalid.
+func (s *FraudService) ReloadRules(ctx context.Context) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if s.RulesFile == "" {
+		return nil
+	}
+
+	info, err := os.Stat(s.RulesFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to stat fraud rule file")
+	}
+	if s.rules.Load() != nil && info.ModTime().Equal(s.rulesModTime) {
+		return nil
+	}
+
+	rules, err := LoadFraudRules(s.RulesFile)
+	if err != nil {
+		return err
+	}
+
+	s.rules.Store(&rules)
+	s.rulesModTime = info.ModTime()
+	helpers.Logger.Infof("loaded %d fraud rules from %s", len(rules), s.RulesFile)
+
+	return nil
+}
+
+func (s *FraudService) currentRules() []models.FraudRule {
+	if rules := s.rules.Load(); rules != nil {
+		return *rules
+	}
+	return DefaultFraudRules
+}
+
+// Evaluate runs the rules that apply to trx moving from fromStatus to
+// toStatus. It returns nil when no rule applies. The decision is REVIEW when
+// a rule with the REVIEW action matched.
+func (s *FraudService) Evaluate(ctx context.Context, trx models.Transaction, fromStatus, toStatus string) (*models.FraudEvaluation, error) {
+	var (
+		applied = false
+		review  = false
+		matched = []string{}
+	)
+
+	for _, rule := range s.currentRules() {
+		if !rule.Applies(trx, toStatus) {
+			continue
+		}
+		applied = true
+
+		ok, err := s.matches(ctx, rule, trx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate fraud rule %s", rule.Name)
+		}
+		if ok {
+			matched = append(matched, rule.Name)
+			review = review || rule.Action == constants.FraudRuleActionReview
+		}
+	}
+
+	if !applied {
+		helpers.Logger.Infof("fraud evaluation of %s transaction %q of user %d from %q to %s: no rule applies", trx.TransactionType, trx.Reference, trx.UserID, fromStatus, toStatus)
+		return nil, nil
+	}
+
+	evaluation := &models.FraudEvaluation{
+		TransactionReference: trx.Reference,
+		UserID:               trx.UserID,
+		TransactionType:      trx.TransactionType,
+		FromStatus:           fromStatus,
+		ToStatus:             toStatus,
+		MatchedRules:         matched,
+		Decision:             constants.FraudDecisionAllow,
+	}
+	if review {
+		evaluation.Decision = constants.FraudDecisionReview
+	}
+
+	helpers.Logger.Infof("fraud evaluation of %s transaction %q of user %d from %q to %s: %s, matched rules %v", trx.TransactionType, trx.Reference, trx.UserID, fromStatus, toStatus, evaluation.Decision, matched)
+	return evaluation, nil
+}
+
+func (s *FraudService) matches(ctx context.Context, rule models.FraudRule, trx models.Transaction) (bool, error) {
+	since := time.Now().Add(-time.Duration(rule.Window))
+
+	switch rule.Condition {
+	case constants.FraudConditionAmountOver:
+		// an amount in another currency cannot be compared with rule.Amount
+		return trx.Currency == rule.AmountCurrency() && trx.Amount > rule.Amount, nil
+	case constants.FraudConditionVelocity, constants.FraudConditionAfterTransaction:
+		count, err := s.TransactionRepo.CountUserTransactionsSince(ctx, trx.UserID, rule.PreviousTransactionTypes, since, trx.Reference)
+		if err != nil {
+			return false, err
+		}
+		if rule.Condition == constants.FraudConditionAfterTransaction {
+			return count > 0, nil
+		}
+		return count >= int64(rule.Count), nil
+	case constants.FraudConditionNewUser:
+		if trx.Currency != rule.AmountCurrency() || trx.Amount <= rule.Amount {
+			return false, nil
+		}
+		first, err := s.TransactionRepo.GetFirstTransaction(ctx, trx.UserID, trx.Reference)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return first.CreatedAt.After(since), nil
+	}
+
+	return false, fmt.Errorf("unknown condition %s", rule.Condition)
+}
+
+func (s *FraudService) GetPendingReviews(ctx context.Context, limit, offset int) ([]models.FraudEvaluation, error) {
+	return s.FraudRepo.GetPendingFraudReviews(ctx, limit, offset)
+}
+
+func (s *FraudService) GetFraudEvaluations(ctx context.Context, reference string) ([]models.FraudEvaluation, error) {
+	return s.FraudRepo.GetFraudEvaluations(ctx, reference)
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+)
+
+// Amount conditions only compare transactions in the currency of the rule.
+func TestEvaluateAmountCurrency(t *testing.T) {
+	tests := []struct {
+		name         string
+		condition    string
+		ruleCurrency string
+		currency     string
+		want         string
+	}{
+		{"amount over in the default currency", constants.FraudConditionAmountOver, "", "IDR", constants.FraudDecisionReview},
+		{"amount over in another currency", constants.FraudConditionAmountOver, "", "USD", constants.FraudDecisionAllow},
+		{"amount over in the rule currency", constants.FraudConditionAmountOver, "USD", "USD", constants.FraudDecisionReview},
+		{"new user in another currency", constants.FraudConditionNewUser, "", "USD", constants.FraudDecisionAllow},
+		{"new user in the rule currency", constants.FraudConditionNewUser, "USD", "USD", constants.FraudDecisionReview},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeTransactionService()
+			s := f.FraudService.(*FraudService)
+			s.rules.Store(&[]models.FraudRule{{
+				Name:      "large",
+				Condition: tt.condition,
+				Action:    constants.FraudRuleActionReview,
+				Amount:    5000,
+				Currency:  tt.ruleCurrency,
+				Window:    models.Duration(24 * time.Hour),
+			}})
+
+			evaluation, err := s.Evaluate(context.Background(), models.Transaction{
+				Reference:       "REF0001",
+				UserID:          1,
+				Amount:          10000,
+				Currency:        tt.currency,
+				TransactionType: constants.TransactionTypePurchase,
+			}, "", constants.TransactionStatusPending)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if evaluation.Decision != tt.want {
+				t.Errorf("Evaluate() decision = %s, want %s", evaluation.Decision, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFraudRulesUnknownCurrency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fraud_rules.json")
+	err := os.WriteFile(path, []byte(`[{"name": "large", "condition": "amount_over", "amount": 5000, "currency": "XXX"}]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadFraudRules(path)
+	if err == nil {
+		t.Error("LoadFraudRules() error = nil, want the unknown currency refused")
+	}
+}
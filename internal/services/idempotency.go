@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type IdempotencyService struct {
+	IdempotencyRepo interfaces.IIdempotencyRepo
+}
+
+// Begin reserves idempotencyKey for the user. When the key was already used
+// with the same request and its response is stored, the stored key is
+// returned with replay set to true so the caller can send it back as is. A
+// key still in progress is only taken over once its lease has run out.
+func (s *IdempotencyService) Begin(ctx context.Context, userID int, idempotencyKey, requestHash string) (*models.IdempotencyKey, bool, error) {
+	now := time.Now()
+	key := &models.IdempotencyKey{
+		UserID:         userID,
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		LockedUntil:    now.Add(idempotencyLease()),
+		ExpiredAt:      now.Add(idempotencyKeyTTL()),
+	}
+
+	err := s.IdempotencyRepo.CreateIdempotencyKey(ctx, key)
+	if err == nil {
+		return key, false, nil
+	}
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, false, errors.Wrap(err, "failed to insert idempotency key")
+	}
+
+	existing, err := s.IdempotencyRepo.GetIdempotencyKey(ctx, userID, idempotencyKey)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get idempotency key")
+	}
+
+	// concurrent retries of an expired key race for the same row, only one
+	// takes it over
+	if now.After(existing.ExpiredAt) {
+		taken, err := s.IdempotencyRepo.TakeOverExpiredIdempotencyKey(ctx, existing.ID, key)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to take over expired idempotency key")
+		}
+		if !taken {
+			return nil, false, constants.ErrIdempotencyKeyInProgress
+		}
+		return key, false, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, false, constants.ErrIdempotencyKeyMismatch
+	}
+
+	if existing.ResponseCode == 0 {
+		if now.Before(existing.LockedUntil) {
+			return nil, false, constants.ErrIdempotencyKeyInProgress
+		}
+		claimed, err := s.IdempotencyRepo.ClaimIdempotencyKey(ctx, &existing, idempotencyLease())
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to claim idempotency key")
+		}
+		if !claimed {
+			return nil, false, constants.ErrIdempotencyKeyInProgress
+		}
+		return &existing, false, nil
+	}
+
+	return &existing, true, nil
+}
+
+// Complete stores the response of the request. Requests rejected with a 4xx
+// changed nothing and release the key so the client can retry with it, a 5xx
+// may have moved money halfway and is replayed like a success.
+func (s *IdempotencyService) Complete(ctx context.Context, key *models.IdempotencyKey, responseCode int, responseBody string) error {
+	if responseCode >= http.StatusBadRequest && responseCode < http.StatusInternalServerError {
+		return s.IdempotencyRepo.DeleteIdempotencyKey(ctx, key.ID)
+	}
+
+	return s.IdempotencyRepo.UpdateIdempotencyResponse(ctx, key.ID, responseCode, responseBody)
+}
+
+// KeepAlive extends the lease of key while its request runs, a request
+// making several slow wallet calls outlives a single lease. The returned stop
+// ends it once the request is done.
+func (s *IdempotencyService) KeepAlive(ctx context.Context, key *models.IdempotencyKey) (stop func()) {
+	lease := idempotencyLease()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lease / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.IdempotencyRepo.ExtendIdempotencyLease(ctx, key.ID, lease)
+				if err != nil {
+					helpers.Logger.Errorf("failed to extend lease of idempotency key %d: %v", key.ID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// PurgeExpiredKeys deletes the keys past their TTL.
+func (s *IdempotencyService) PurgeExpiredKeys(ctx context.Context) error {
+	deleted, err := s.IdempotencyRepo.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to delete expired idempotency keys")
+	}
+	if deleted > 0 {
+		helpers.Logger.Infof("purged %d expired idempotency keys", deleted)
+	}
+	return nil
+}
+
+func idempotencyKeyTTL() time.Duration {
+	ttl, err := time.ParseDuration(helpers.GetEnv("IDEMPOTENCY_KEY_TTL", "24h"))
+	if err != nil || ttl <= 0 {
+		helpers.Logger.Warn("invalid IDEMPOTENCY_KEY_TTL, using default 24h")
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+func idempotencyLease() time.Duration {
+	lease, err := time.ParseDuration(helpers.GetEnv("IDEMPOTENCY_LEASE", "1m"))
+	if err != nil || lease <= 0 {
+		helpers.Logger.Warn("invalid IDEMPOTENCY_LEASE, using default 1m")
+		return time.Minute
+	}
+	return lease
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeIdempotencyRepo keeps the keys in memory, mu guards them against the
+// lease extension of KeepAlive. onGet, when set, runs before a key is looked
+// up.
+type fakeIdempotencyRepo struct {
+	mu    sync.Mutex
+	keys  map[int]*models.IdempotencyKey
+	n     int
+	onGet func()
+}
+
+func (r *fakeIdempotencyRepo) CreateIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.keys {
+		if existing.UserID == key.UserID && existing.IdempotencyKey == key.IdempotencyKey {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	r.n++
+	key.ID = r.n
+	stored := *key
+	r.keys[key.ID] = &stored
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) GetIdempotencyKey(ctx context.Context, userID int, idempotencyKey string) (models.IdempotencyKey, error) {
+	if r.onGet != nil {
+		r.onGet()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.keys {
+		if existing.UserID == userID && existing.IdempotencyKey == idempotencyKey {
+			return *existing, nil
+		}
+	}
+	return models.IdempotencyKey{}, gorm.ErrRecordNotFound
+}
+
+func (r *fakeIdempotencyRepo) UpdateIdempotencyResponse(ctx context.Context, id int, responseCode int, responseBody string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[id].ResponseCode, r.keys[id].ResponseBody = responseCode, responseBody
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) ClaimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey, lease time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.keys[key.ID]
+	if stored.ResponseCode != 0 || time.Now().Before(stored.LockedUntil) {
+		return false, nil
+	}
+	stored.LockedUntil = time.Now().Add(lease)
+	key.LockedUntil = stored.LockedUntil
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepo) TakeOverExpiredIdempotencyKey(ctx context.Context, id int, key *models.IdempotencyKey) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.keys[id]
+	if stored == nil || !stored.ExpiredAt.Before(time.Now()) {
+		return false, nil
+	}
+	key.ID = id
+	*stored = *key
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepo) ExtendIdempotencyLease(ctx context.Context, id int, lease time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored := r.keys[id]; stored != nil && stored.ResponseCode == 0 {
+		stored.LockedUntil = time.Now().Add(lease)
+	}
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) DeleteIdempotencyKey(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, id)
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, key := range r.keys {
+		if key.ExpiredAt.Before(before) {
+			delete(r.keys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestIdempotencyComplete(t *testing.T) {
+	tests := []struct {
+		name         string
+		responseCode int
+		wantReplay   bool
+	}{
+		{"success is replayed", http.StatusOK, true},
+		{"bad request releases the key", http.StatusBadRequest, false},
+		{"server error is replayed", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := &IdempotencyService{IdempotencyRepo: &fakeIdempotencyRepo{keys: map[int]*models.IdempotencyKey{}}}
+
+			key, _, err := s.Begin(ctx, 1, "key", "hash")
+			if err != nil {
+				t.Fatalf("Begin() error = %v", err)
+			}
+			err = s.Complete(ctx, key, tt.responseCode, "{}")
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			retry, replay, err := s.Begin(ctx, 1, "key", "hash")
+			if err != nil {
+				t.Fatalf("Begin() retry error = %v", err)
+			}
+			if replay != tt.wantReplay {
+				t.Errorf("Begin() retry replay = %v, want %v", replay, tt.wantReplay)
+			}
+			if replay && retry.ResponseCode != tt.responseCode {
+				t.Errorf("Begin() retry response code = %d, want %d", retry.ResponseCode, tt.responseCode)
+			}
+		})
+	}
+}
+
+// A request that never completed holds its key only until the lease runs out.
+func TestIdempotencyLease(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeIdempotencyRepo{keys: map[int]*models.IdempotencyKey{}}
+	s := &IdempotencyService{IdempotencyRepo: repo}
+
+	key, _, err := s.Begin(ctx, 1, "key", "hash")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	_, _, err = s.Begin(ctx, 1, "key", "hash")
+	if !errors.Is(err, constants.ErrIdempotencyKeyInProgress) {
+		t.Fatalf("Begin() during lease error = %v, want %v", err, constants.ErrIdempotencyKeyInProgress)
+	}
+
+	repo.keys[key.ID].LockedUntil = time.Now().Add(-time.Second)
+	retry, replay, err := s.Begin(ctx, 1, "key", "hash")
+	if err != nil || replay || retry.ID != key.ID {
+		t.Fatalf("Begin() after lease = %v, %v, %v, want key %d taken over", retry, replay, err, key.ID)
+	}
+
+	_, _, err = s.Begin(ctx, 1, "key", "hash")
+	if !errors.Is(err, constants.ErrIdempotencyKeyInProgress) {
+		t.Errorf("Begin() after takeover error = %v, want %v", err, constants.ErrIdempotencyKeyInProgress)
+	}
+}
+
+// Of two retries racing for an expired key, one takes it over and the other
+// finds it in progress.
+func TestIdempotencyExpiredTakeover(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeIdempotencyRepo{keys: map[int]*models.IdempotencyKey{}}
+	s := &IdempotencyService{IdempotencyRepo: repo}
+
+	_ = repo.CreateIdempotencyKey(ctx, &models.IdempotencyKey{UserID: 1, IdempotencyKey: "key", RequestHash: "old", ResponseCode: http.StatusOK, ExpiredAt: time.Now().Add(-time.Hour)})
+
+	var (
+		concurrent    *models.IdempotencyKey
+		concurrentErr error
+	)
+	repo.onGet = func() {
+		repo.onGet = nil
+		concurrent, _, concurrentErr = s.Begin(ctx, 1, "key", "hash")
+	}
+
+	_, _, err := s.Begin(ctx, 1, "key", "hash")
+	if !errors.Is(err, constants.ErrIdempotencyKeyInProgress) {
+		t.Errorf("Begin() error = %v, want %v", err, constants.ErrIdempotencyKeyInProgress)
+	}
+	if concurrentErr != nil || concurrent.ID != 1 {
+		t.Fatalf("concurrent Begin() = %v, %v, want key 1 taken over", concurrent, concurrentErr)
+	}
+	if stored := repo.keys[1]; stored.RequestHash != "hash" || stored.ResponseCode != 0 {
+		t.Errorf("key after takeover = %+v, want the new request in progress", stored)
+	}
+}
+
+// The lease of a key is extended while its request runs, and no longer once
+// it stopped.
+func TestIdempotencyKeepAlive(t *testing.T) {
+	helpers.Env["IDEMPOTENCY_LEASE"] = "40ms"
+	defer delete(helpers.Env, "IDEMPOTENCY_LEASE")
+
+	ctx := context.Background()
+	repo := &fakeIdempotencyRepo{keys: map[int]*models.IdempotencyKey{}}
+	s := &IdempotencyService{IdempotencyRepo: repo}
+
+	key, _, err := s.Begin(ctx, 1, "key", "hash")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	stop := s.KeepAlive(ctx, key)
+	time.Sleep(150 * time.Millisecond)
+	_, _, err = s.Begin(ctx, 1, "key", "hash")
+	if !errors.Is(err, constants.ErrIdempotencyKeyInProgress) {
+		t.Errorf("Begin() while running error = %v, want %v", err, constants.ErrIdempotencyKeyInProgress)
+	}
+
+	stop()
+	time.Sleep(60 * time.Millisecond)
+	retry, _, err := s.Begin(ctx, 1, "key", "hash")
+	if err != nil || retry.ID != key.ID {
+		t.Errorf("Begin() after stop = %v, %v, want key %d taken over", retry, err, key.ID)
+	}
+}
+
+func TestPurgeExpiredKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeIdempotencyRepo{keys: map[int]*models.IdempotencyKey{}}
+	s := &IdempotencyService{IdempotencyRepo: repo}
+
+	_ = repo.CreateIdempotencyKey(ctx, &models.IdempotencyKey{UserID: 1, IdempotencyKey: "old", ExpiredAt: time.Now().Add(-time.Hour)})
+	_ = repo.CreateIdempotencyKey(ctx, &models.IdempotencyKey{UserID: 1, IdempotencyKey: "new", ExpiredAt: time.Now().Add(time.Hour)})
+
+	err := s.PurgeExpiredKeys(ctx)
+	if err != nil {
+		t.Fatalf("PurgeExpiredKeys() error = %v", err)
+	}
+	if len(repo.keys) != 1 || repo.keys[2] == nil {
+		t.Errorf("keys after purge = %v, want only the unexpired one", repo.keys)
+	}
+}
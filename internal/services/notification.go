@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type NotificationService struct {
+	NotificationOutboxRepo interfaces.INotificationOutboxRepo
+	External               interfaces.IExternal
+}
+
+// DispatchNotifications delivers due outbox rows. Failed deliveries are
+// retried with exponential backoff until NOTIFICATION_MAX_ATTEMPTS is
+// reached, then the row is marked DEAD.
+func (s *NotificationService) DispatchNotifications(ctx context.Context) error {
+	var (
+		log = helpers.Logger
+	)
+
+	maxAttempts, err := strconv.Atoi(helpers.GetEnv("NOTIFICATION_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse notification max attempts")
+	}
+
+	backoff, err := time.ParseDuration(helpers.GetEnv("NOTIFICATION_RETRY_BACKOFF", "30s"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse notification retry backoff")
+	}
+
+	maxBackoff, err := time.ParseDuration(helpers.GetEnv("NOTIFICATION_RETRY_MAX_BACKOFF", "1h"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse notification retry max backoff")
+	}
+
+	notifications, err := s.NotificationOutboxRepo.GetDueNotificationOutbox(ctx, 100)
+	if err != nil {
+		return errors.Wrap(err, "failed to get due notifications")
+	}
+
+	for i := range notifications {
+		notification := &notifications[i]
+
+		claimed, err := s.NotificationOutboxRepo.ClaimNotificationOutbox(ctx, notification, time.Minute)
+		if err != nil {
+			log.Error("failed to claim notification: ", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		attempts := notification.Attempts + 1
+		status := constants.NotificationOutboxStatusSent
+		nextAttemptAt := notification.NextAttemptAt
+		lastError := ""
+
+		err = s.External.SendNotification(ctx, notification.Recipient, notification.TemplateName, notification.Placeholders)
+		if err != nil {
+			lastError = err.Error()
+			status = constants.NotificationOutboxStatusPending
+			nextAttemptAt = time.Now().Add(retryBackoff(backoff, maxBackoff, attempts))
+			if attempts >= maxAttempts {
+				status = constants.NotificationOutboxStatusDead
+				log.Errorf("notification %d is dead after %d attempts: %v", notification.ID, attempts, err)
+			} else {
+				log.Warnf("failed to send notification %d, attempt %d: %v", notification.ID, attempts, err)
+			}
+		}
+
+		err = s.NotificationOutboxRepo.UpdateNotificationOutbox(ctx, notification.ID, status, attempts, nextAttemptAt, lastError)
+		if err != nil {
+			log.Error("failed to update notification: ", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *NotificationService) GetDeadLetters(ctx context.Context, limit, offset int) ([]models.NotificationOutbox, error) {
+	return s.NotificationOutboxRepo.GetNotificationOutboxByStatus(ctx, constants.NotificationOutboxStatusDead, limit, offset)
+}
+
+func (s *NotificationService) RedriveDeadLetter(ctx context.Context, id int) error {
+	ok, err := s.NotificationOutboxRepo.RedriveNotificationOutbox(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to redrive notification")
+	}
+	if !ok {
+		return constants.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// retryBackoff doubles base for every attempt after the first, up to max.
+func retryBackoff(base, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return backoff
+}
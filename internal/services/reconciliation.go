@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/external"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type ReconciliationService struct {
+	TransactionRepo              interfaces.ITransactionRepo
+	TransactionStatusHistoryRepo interfaces.ITransactionStatusHistoryRepo
+	External                     interfaces.IExternal
+	StateMachines                models.StateMachines
+}
+
+// expectedWalletEntry is a wallet entry that a settled transaction must have
+// produced.
+type expectedWalletEntry struct {
+	reference            string
+	transactionReference string
+	operation            string
+	amount               models.Money
+	currency             string
+}
+
+// expectedWalletEntries returns the wallet entries trx must have produced
+// through the status changes recorded in history. A transaction without
+// history, created before it was recorded, is assumed to have followed the
+// shortest path of its state machine.
+func expectedWalletEntries(machines models.StateMachines, trx models.Transaction, history []models.TransactionStatusHistory) []expectedWalletEntry {
+	var (
+		entries []expectedWalletEntry
+		path    []models.StateTransition
+	)
+
+	if len(history) == 0 {
+		path, _ = machines.Path(trx.TransactionType, trx.TransactionStatus)
+	}
+	for _, change := range history {
+		transition, err := machines.Transition(trx.TransactionType, change.FromStatus, change.ToStatus)
+		if err != nil {
+			helpers.Logger.Warnf("status change of %s from %q to %s is not in the state machine", trx.Reference, change.FromStatus, change.ToStatus)
+			continue
+		}
+		path = append(path, transition)
+	}
+
+	for _, transition := range path {
+		if transition.WalletOperation != "" {
+			entries = append(entries, expectedWalletEntry{
+				reference:            transition.WalletReferencePrefix + trx.Reference,
+				transactionReference: trx.Reference,
+				operation:            transition.WalletOperation,
+				amount:               trx.Amount,
+				currency:             trx.Currency,
+			})
+		}
+		if transition.CounterpartyWalletOperation != "" {
+			entries = append(entries, expectedWalletEntry{
+				reference:            transition.CounterpartyWalletReferencePrefix + trx.Reference,
+				transactionReference: trx.Reference,
+				operation:            transition.CounterpartyWalletOperation,
+				amount:               trx.Amount,
+				currency:             trx.Currency,
+			})
+		}
+		if transition.FeeWalletOperation != "" && trx.FeeAmount > 0 {
+			entries = append(entries, expectedWalletEntry{
+				reference:            transition.FeeWalletReferencePrefix + trx.Reference,
+				transactionReference: trx.Reference,
+				operation:            transition.FeeWalletOperation,
+				amount:               trx.FeeAmount,
+				currency:             trx.Currency,
+			})
+		}
+	}
+
+	return entries
+}
+
+// Reconcile checks the settled transactions created in [start, end) against
+// the wallet entries made in the same window. An expected entry not found
+// there is looked up by its reference, so a transaction settled after the
+// window is not reported as missing.
+func (s *ReconciliationService) Reconcile(ctx context.Context, start, end time.Time) (models.ReconciliationReport, error) {
+	var (
+		report = models.ReconciliationReport{
+			StartTime:      start,
+			EndTime:        end,
+			Missing:        []models.ReconciliationEntry{},
+			Duplicate:      []models.ReconciliationEntry{},
+			AmountMismatch: []models.ReconciliationEntry{},
+			Unexpected:     []models.ReconciliationEntry{},
+		}
+		token = helpers.GetEnv("WALLET_SYSTEM_TOKEN", "")
+	)
+
+	transactions, err := s.TransactionRepo.GetSettledTransactionsCreatedBetween(ctx, start, end)
+	if err != nil {
+		return report, errors.Wrap(err, "failed to get settled transactions")
+	}
+
+	history, err := s.External.GetWalletHistory(ctx, token, external.WalletHistoryRequest{
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil {
+		return report, errors.Wrap(err, "failed to get wallet history")
+	}
+
+	walletEntries := groupWalletEntries(history)
+
+	references := make([]string, len(transactions))
+	for i := range transactions {
+		references[i] = transactions[i].Reference
+	}
+	statusHistories, err := s.TransactionStatusHistoryRepo.GetTransactionStatusHistories(ctx, references)
+	if err != nil {
+		return report, errors.Wrap(err, "failed to get transaction status history")
+	}
+	statusHistory := map[string][]models.TransactionStatusHistory{}
+	for _, change := range statusHistories {
+		statusHistory[change.TransactionReference] = append(statusHistory[change.TransactionReference], change)
+	}
+
+	expected := map[string]expectedWalletEntry{}
+	for i := range transactions {
+		for _, entry := range expectedWalletEntries(s.StateMachines, transactions[i], statusHistory[transactions[i].Reference]) {
+			expected[entry.reference] = entry
+		}
+	}
+
+	for _, reference := range sortedKeys(expected) {
+		entries, ok := walletEntries[reference]
+		if !ok {
+			entries, err = s.getWalletEntriesSince(ctx, token, reference, start)
+			if err != nil {
+				return report, err
+			}
+		}
+
+		report.Checked++
+		matchWalletEntries(&report, expected[reference], entries)
+		delete(walletEntries, reference)
+	}
+
+	// what is left was either made for a transaction created before the
+	// window or does not belong to any transaction
+	for _, reference := range sortedKeys(walletEntries) {
+		entries := walletEntries[reference]
+
+		entry, found, err := s.findExpectedWalletEntry(ctx, reference)
+		if err != nil {
+			return report, err
+		}
+		if !found {
+			report.Unexpected = append(report.Unexpected, models.ReconciliationEntry{
+				Reference:       reference,
+				WalletOperation: entries[0].WalletTransactionType,
+				WalletAmount:    &entries[0].Amount,
+				Currency:        entries[0].Currency,
+				WalletCount:     len(entries),
+			})
+			continue
+		}
+
+		report.Checked++
+		matchWalletEntries(&report, entry, entries)
+	}
+
+	return report, nil
+}
+
+// ReconcileRecent reconciles the last RECONCILIATION_WINDOW that ended at
+// least RECONCILIATION_DELAY ago, giving in-flight operations time to settle,
+// and logs the report.
+func (s *ReconciliationService) ReconcileRecent(ctx context.Context) error {
+	var (
+		log = helpers.Logger
+	)
+
+	window, err := time.ParseDuration(helpers.GetEnv("RECONCILIATION_WINDOW", "1h"))
+	if err != nil || window <= 0 {
+		return errors.New("invalid RECONCILIATION_WINDOW")
+	}
+
+	delay, err := time.ParseDuration(helpers.GetEnv("RECONCILIATION_DELAY", "1h"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse reconciliation delay")
+	}
+
+	end := time.Now().Add(-delay).Truncate(window)
+	start := end.Add(-window)
+
+	report, err := s.Reconcile(ctx, start, end)
+	if err != nil {
+		return err
+	}
+
+	byteReport, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal reconciliation report")
+	}
+
+	if report.HasDiscrepancies() {
+		log.Warnf("reconciliation found discrepancies: %s", byteReport)
+	} else {
+		log.Infof("reconciliation checked %d wallet entries from %s to %s without discrepancies", report.Checked, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// getWalletEntriesSince returns the entries made under reference since
+// start, leaving out those that were compensated.
+func (s *ReconciliationService) getWalletEntriesSince(ctx context.Context, token, reference string, start time.Time) ([]external.WalletHistoryEntry, error) {
+	var (
+		history []external.WalletHistoryEntry
+	)
+
+	for _, ref := range []string{reference, "COMPENSATE-" + reference} {
+		entries, err := s.External.GetWalletHistory(ctx, token, external.WalletHistoryRequest{
+			StartTime: start,
+			EndTime:   time.Now(),
+			Reference: ref,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get wallet history")
+		}
+		history = append(history, entries...)
+	}
+
+	return groupWalletEntries(history)[reference], nil
+}
+
+// findExpectedWalletEntry looks up the transaction a wallet reference belongs
+// to and returns the entry it must have produced under that reference.
+func (s *ReconciliationService) findExpectedWalletEntry(ctx context.Context, reference string) (expectedWalletEntry, bool, error) {
+	prefixes := map[string]bool{"": true}
+	for _, transitions := range s.StateMachines {
+		for _, transition := range transitions {
+			prefixes[transition.WalletReferencePrefix] = true
+			prefixes[transition.CounterpartyWalletReferencePrefix] = true
+			prefixes[transition.FeeWalletReferencePrefix] = true
+		}
+	}
+
+	for prefix := range prefixes {
+		transactionReference, ok := strings.CutPrefix(reference, prefix)
+		if !ok {
+			continue
+		}
+
+		trx, err := s.TransactionRepo.GetTransactionByReference(ctx, transactionReference, true)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return expectedWalletEntry{}, false, errors.Wrap(err, "failed to get transaction")
+		}
+
+		history, err := s.TransactionStatusHistoryRepo.GetTransactionStatusHistory(ctx, trx.Reference)
+		if err != nil {
+			return expectedWalletEntry{}, false, errors.Wrap(err, "failed to get transaction status history")
+		}
+
+		for _, entry := range expectedWalletEntries(s.StateMachines, trx, history) {
+			if entry.reference == reference {
+				return entry, true, nil
+			}
+		}
+	}
+
+	return expectedWalletEntry{}, false, nil
+}
+
+// groupWalletEntries groups entries by reference. A wallet call that was
+// compensated cancels out with its COMPENSATE- entry, so both are left out.
+func groupWalletEntries(entries []external.WalletHistoryEntry) map[string][]external.WalletHistoryEntry {
+	grouped := map[string][]external.WalletHistoryEntry{}
+	for i := range entries {
+		if strings.HasPrefix(entries[i].Reference, "COMPENSATE-") {
+			continue
+		}
+		grouped[entries[i].Reference] = append(grouped[entries[i].Reference], entries[i])
+	}
+
+	for i := range entries {
+		reference, ok := strings.CutPrefix(entries[i].Reference, "COMPENSATE-")
+		if !ok || len(grouped[reference]) == 0 {
+			continue
+		}
+		grouped[reference] = grouped[reference][1:]
+		if len(grouped[reference]) == 0 {
+			delete(grouped, reference)
+		}
+	}
+
+	return grouped
+}
+
+func matchWalletEntries(report *models.ReconciliationReport, expected expectedWalletEntry, entries []external.WalletHistoryEntry) {
+	result := models.ReconciliationEntry{
+		Reference:            expected.reference,
+		TransactionReference: expected.transactionReference,
+		ExpectedOperation:    expected.operation,
+		ExpectedAmount:       &expected.amount,
+		Currency:             expected.currency,
+		WalletCount:          len(entries),
+	}
+
+	if len(entries) == 0 {
+		report.Missing = append(report.Missing, result)
+		return
+	}
+
+	result.WalletOperation = entries[0].WalletTransactionType
+	result.WalletAmount = &entries[0].Amount
+
+	if len(entries) > 1 {
+		report.Duplicate = append(report.Duplicate, result)
+		return
+	}
+
+	currency := entries[0].Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if entries[0].Amount != expected.amount || entries[0].WalletTransactionType != expected.operation || currency != expected.currency {
+		report.AmountMismatch = append(report.AmountMismatch, result)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
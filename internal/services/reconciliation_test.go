@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external"
+	"ewallet-transaction/internal/models"
+)
+
+// reconciliationResults lists the discrepancies of report as kind and
+// reference.
+func reconciliationResults(report models.ReconciliationReport) []string {
+	var resp []string
+	add := func(kind string, entries []models.ReconciliationEntry) {
+		for _, entry := range entries {
+			resp = append(resp, kind+" "+entry.Reference)
+		}
+	}
+	add("missing", report.Missing)
+	add("duplicate", report.Duplicate)
+	add("amount mismatch", report.AmountMismatch)
+	add("unexpected", report.Unexpected)
+	return resp
+}
+
+func TestReconcile(t *testing.T) {
+	var (
+		start = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		end   = start.Add(time.Hour)
+	)
+
+	type walletEntry struct {
+		reference string
+		amount    models.Money
+		at        time.Time
+	}
+
+	tests := []struct {
+		name        string
+		createdAt   time.Time
+		entries     []walletEntry
+		wantChecked int
+		want        []string
+	}{
+		{
+			name:        "matched",
+			createdAt:   start.Add(10 * time.Minute),
+			entries:     []walletEntry{{"REF0001", 10000, start.Add(20 * time.Minute)}},
+			wantChecked: 1,
+		},
+		{
+			name:        "missing",
+			createdAt:   start.Add(10 * time.Minute),
+			wantChecked: 1,
+			want:        []string{"missing REF0001"},
+		},
+		{
+			name:      "duplicate",
+			createdAt: start.Add(10 * time.Minute),
+			entries: []walletEntry{
+				{"REF0001", 10000, start.Add(20 * time.Minute)},
+				{"REF0001", 10000, start.Add(30 * time.Minute)},
+			},
+			wantChecked: 1,
+			want:        []string{"duplicate REF0001"},
+		},
+		{
+			name:        "amount mismatch",
+			createdAt:   start.Add(10 * time.Minute),
+			entries:     []walletEntry{{"REF0001", 5000, start.Add(20 * time.Minute)}},
+			wantChecked: 1,
+			want:        []string{"amount mismatch REF0001"},
+		},
+		{
+			name:        "settled after the window",
+			createdAt:   start.Add(50 * time.Minute),
+			entries:     []walletEntry{{"REF0001", 10000, end.Add(30 * time.Minute)}},
+			wantChecked: 1,
+		},
+		{
+			name:      "compensated and made again",
+			createdAt: start.Add(10 * time.Minute),
+			entries: []walletEntry{
+				{"REF0001", 10000, start.Add(20 * time.Minute)},
+				{"COMPENSATE-REF0001", 10000, start.Add(21 * time.Minute)},
+				{"REF0001", 10000, start.Add(30 * time.Minute)},
+			},
+			wantChecked: 1,
+		},
+		{
+			name:      "compensated and made again after the window",
+			createdAt: start.Add(10 * time.Minute),
+			entries: []walletEntry{
+				{"REF0001", 10000, start.Add(20 * time.Minute)},
+				{"COMPENSATE-REF0001", 10000, start.Add(21 * time.Minute)},
+				{"REF0001", 10000, end.Add(10 * time.Minute)},
+			},
+			wantChecked: 1,
+		},
+		{
+			name:        "transaction created before the window",
+			createdAt:   start.Add(-time.Hour),
+			entries:     []walletEntry{{"REF0001", 10000, start.Add(10 * time.Minute)}},
+			wantChecked: 1,
+		},
+		{
+			name:      "unexpected entry",
+			createdAt: start.Add(10 * time.Minute),
+			entries: []walletEntry{
+				{"REF0001", 10000, start.Add(20 * time.Minute)},
+				{"OTHER", 10000, start.Add(20 * time.Minute)},
+			},
+			wantChecked: 1,
+			want:        []string{"unexpected OTHER"},
+		},
+		{
+			name:      "unexpected entry after the window",
+			createdAt: start.Add(10 * time.Minute),
+			entries: []walletEntry{
+				{"REF0001", 10000, start.Add(20 * time.Minute)},
+				{"OTHER", 10000, end.Add(time.Minute)},
+			},
+			wantChecked: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			_ = f.transactions.CreateTransaction(ctx, &models.Transaction{
+				Reference:         "REF0001",
+				UserID:            int(testUser.UserID),
+				Amount:            10000,
+				Currency:          "IDR",
+				TransactionType:   constants.TransactionTypePurchase,
+				TransactionStatus: constants.TransactionStatusSuccess,
+				CreatedAt:         tt.createdAt,
+			})
+
+			for _, entry := range tt.entries {
+				operation := constants.BalanceOperationDebit
+				if entry.reference == "COMPENSATE-REF0001" {
+					operation = constants.BalanceOperationCredit
+				}
+				f.wallet.entries = append(f.wallet.entries, external.WalletHistoryEntry{
+					Reference:             entry.reference,
+					Amount:                entry.amount,
+					Currency:              "IDR",
+					WalletTransactionType: operation,
+					UserID:                int(testUser.UserID),
+					Date:                  entry.at,
+				})
+			}
+
+			s := &ReconciliationService{
+				TransactionRepo:              f.transactions,
+				TransactionStatusHistoryRepo: f.outbox,
+				External:                     f.wallet,
+				StateMachines:                DefaultStateMachines,
+			}
+			report, err := s.Reconcile(ctx, start, end)
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if report.Checked != tt.wantChecked {
+				t.Errorf("Reconcile() checked %d entries, want %d", report.Checked, tt.wantChecked)
+			}
+			assertEqual(t, "discrepancies", reconciliationResults(report), tt.want)
+		})
+	}
+}
+
+// The expected entries follow the status changes that were recorded, not the
+// shortest path of the state machine.
+func TestReconcileFollowsStatusHistory(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		start = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		end   = start.Add(time.Hour)
+		f     = newFakeTransactionService()
+	)
+
+	// approving a reviewed topup credits the wallet under another reference
+	machines := models.StateMachines{
+		constants.TransactionTypeTopup: {
+			{From: "", To: constants.TransactionStatusPending},
+			{From: constants.TransactionStatusPending, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationCredit},
+			{From: "", To: constants.TransactionStatusUnderReview},
+			{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationCredit, WalletReferencePrefix: "REVIEWED-"},
+		},
+	}
+
+	for _, reference := range []string{"REF0001", "REF0002"} {
+		_ = f.transactions.CreateTransaction(ctx, &models.Transaction{
+			Reference:         reference,
+			UserID:            int(testUser.UserID),
+			Amount:            10000,
+			Currency:          "IDR",
+			TransactionType:   constants.TransactionTypeTopup,
+			TransactionStatus: constants.TransactionStatusSuccess,
+			CreatedAt:         start.Add(10 * time.Minute),
+		})
+	}
+	for _, change := range [][3]string{
+		{"REF0001", "", constants.TransactionStatusPending},
+		{"REF0001", constants.TransactionStatusPending, constants.TransactionStatusSuccess},
+		{"REF0002", "", constants.TransactionStatusUnderReview},
+		{"REF0002", constants.TransactionStatusUnderReview, constants.TransactionStatusSuccess},
+	} {
+		_ = f.outbox.CreateTransactionStatusHistory(ctx, &models.TransactionStatusHistory{TransactionReference: change[0], FromStatus: change[1], ToStatus: change[2]})
+	}
+	for _, reference := range []string{"REF0001", "REVIEWED-REF0002"} {
+		f.wallet.entries = append(f.wallet.entries, external.WalletHistoryEntry{
+			Reference:             reference,
+			Amount:                10000,
+			Currency:              "IDR",
+			WalletTransactionType: constants.BalanceOperationCredit,
+			Date:                  start.Add(20 * time.Minute),
+		})
+	}
+
+	s := &ReconciliationService{
+		TransactionRepo:              f.transactions,
+		TransactionStatusHistoryRepo: f.outbox,
+		External:                     f.wallet,
+		StateMachines:                machines,
+	}
+	report, err := s.Reconcile(ctx, start, end)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Checked != 2 {
+		t.Errorf("Reconcile() checked %d entries, want 2", report.Checked)
+	}
+	assertEqual(t, "discrepancies", reconciliationResults(report), nil)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+)
+
+// A refund reserved while the wallet call of a reversal is in flight is
+// counted when the reversal is written, the reversal is compensated instead of
+// paying the refund twice.
+func TestReverseDuringRefund(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	_ = f.transactions.CreateTransaction(ctx, &models.Transaction{
+		Reference:         "REF0001",
+		UserID:            int(testUser.UserID),
+		Amount:            10000,
+		Currency:          "IDR",
+		TransactionType:   constants.TransactionTypePurchase,
+		TransactionStatus: constants.TransactionStatusSuccess,
+	})
+
+	refund, _ := json.Marshal(models.Transaction{Reference: "REFUND-REF0001-1", Amount: 4000, Currency: "IDR"})
+	f.wallet.onCall = func(reference string) {
+		f.wallet.onCall = nil
+		_ = f.balanceOperations.CreateBalanceOperation(ctx, &models.BalanceOperation{
+			TransactionReference: "REF0001",
+			WalletReference:      "REFUND-REF0001-1",
+			Action:               constants.BalanceOperationActionRefund,
+			Status:               constants.BalanceOperationStatusPending,
+			Payload:              string(refund),
+		})
+	}
+
+	err := f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+		Reference:         "REF0001",
+		TransactionStatus: constants.TransactionStatusReversed,
+	})
+	if err == nil {
+		t.Fatal("UpdateStatusTransaction() error = nil, want the refund to block the reversal")
+	}
+	assertTransactionStatus(t, f, "REF0001", constants.TransactionStatusSuccess)
+	assertEqual(t, "wallet entries", walletReferences(f.wallet), []string{"REVERSED-REF0001", "COMPENSATE-REVERSED-REF0001"})
+
+	// once the refund is journaled, the reversal is refused before any call
+	err = f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+		Reference:         "REF0001",
+		TransactionStatus: constants.TransactionStatusReversed,
+	})
+	if err == nil {
+		t.Fatal("UpdateStatusTransaction() error = nil, want the refund to block the reversal")
+	}
+	assertEqual(t, "wallet calls", f.wallet.calls, []string{"CREDIT REVERSED-REF0001", "DEBIT COMPENSATE-REVERSED-REF0001"})
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// screenTransaction runs the fraud rules on trx moving from fromStatus to
+// toStatus and returns the evaluation to record, nil when no rule applied. A
+// REVIEW decision is only kept when the state machine of the transaction
+// type can hold the transaction, other matches are just recorded.
+func (s *TransactionService) screenTransaction(ctx context.Context, trx models.Transaction, fromStatus, toStatus string) (*models.FraudEvaluation, error) {
+	evaluation, err := s.FraudService.Evaluate(ctx, trx, fromStatus, toStatus)
+	if err != nil || evaluation == nil {
+		return evaluation, err
+	}
+
+	if evaluation.Decision == constants.FraudDecisionReview {
+		if s.canHold(trx.TransactionType, fromStatus, toStatus) {
+			evaluation.ReviewStatus = constants.FraudReviewStatusPending
+		} else {
+			helpers.Logger.Warnf("%s transactions cannot be held from %s to %s, the state machine has no %s transitions", trx.TransactionType, fromStatus, toStatus, constants.TransactionStatusUnderReview)
+			evaluation.Decision = constants.FraudDecisionAllow
+		}
+	}
+
+	return evaluation, nil
+}
+
+// canHold reports whether the state machine of transactionType can move a
+// transaction from fromStatus to UNDER_REVIEW, and from there to toStatus
+// when approved or to FAILED when rejected.
+func (s *TransactionService) canHold(transactionType, fromStatus, toStatus string) bool {
+	for _, transition := range [][2]string{
+		{fromStatus, constants.TransactionStatusUnderReview},
+		{constants.TransactionStatusUnderReview, toStatus},
+		{constants.TransactionStatusUnderReview, constants.TransactionStatusFailed},
+	} {
+		if _, err := s.StateMachines.Transition(transactionType, transition[0], transition[1]); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func isHeld(evaluation *models.FraudEvaluation) bool {
+	return evaluation != nil && evaluation.Decision == constants.FraudDecisionReview
+}
+
+func (s *TransactionService) recordFraudEvaluation(ctx context.Context, evaluation *models.FraudEvaluation, reference string) error {
+	if evaluation == nil {
+		return nil
+	}
+
+	evaluation.TransactionReference = reference
+	err := s.FraudRepo.CreateFraudEvaluation(ctx, evaluation)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert fraud evaluation")
+	}
+	return nil
+}
+
+// changeStatus screens the status change of trx with the fraud rules and
+// makes it, or holds trx UNDER_REVIEW when they flag it. It reports whether
+// trx was held.
+func (s *TransactionService) changeStatus(ctx context.Context, tokenData models.TokenData, trx models.Transaction, req *models.UpdateStatusTransaction) (bool, error) {
+	_, err := s.StateMachines.Transition(trx.TransactionType, trx.TransactionStatus, req.TransactionStatus)
+	if err != nil {
+		return false, err
+	}
+
+	evaluation, err := s.screenTransaction(ctx, trx, trx.TransactionStatus, req.TransactionStatus)
+	if err != nil {
+		return false, err
+	}
+
+	if isHeld(evaluation) {
+		return true, s.holdTransaction(ctx, tokenData, trx, evaluation, req.AddtionalInfo)
+	}
+
+	err = s.recordFraudEvaluation(ctx, evaluation, trx.Reference)
+	if err != nil {
+		return false, err
+	}
+
+	return false, s.updateStatusTransaction(ctx, tokenData, trx, req, nil)
+}
+
+// holdTransaction moves trx to UNDER_REVIEW together with the evaluation that
+// flagged it. Holding never calls the wallet.
+func (s *TransactionService) holdTransaction(ctx context.Context, tokenData models.TokenData, trx models.Transaction, evaluation *models.FraudEvaluation, additionalInfo string) error {
+	transition, err := s.StateMachines.Transition(trx.TransactionType, trx.TransactionStatus, constants.TransactionStatusUnderReview)
+	if err != nil {
+		return err
+	}
+
+	byteAdditionalInfo, err := mergeAdditionalInfo(trx.AddtionalInfo, additionalInfo)
+	if err != nil {
+		return err
+	}
+
+	fromStatus := trx.TransactionStatus
+	trx.TransactionStatus = constants.TransactionStatusUnderReview
+	trx.AddtionalInfo = string(byteAdditionalInfo)
+
+	err = s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		held, err := s.TransactionRepo.UpdateStatusTransactionFrom(ctx, trx.Reference, fromStatus, trx.TransactionStatus, trx.AddtionalInfo, tokenData.Username)
+		if err != nil {
+			return err
+		}
+		if !held {
+			return errors.Errorf("transaction %s is no longer %s", trx.Reference, fromStatus)
+		}
+		err = s.recordFraudEvaluation(ctx, evaluation, trx.Reference)
+		if err != nil {
+			return err
+		}
+		err = s.recordStatusChange(ctx, tokenData, trx, fromStatus, additionalInfo)
+		if err != nil {
+			return err
+		}
+		return s.sendNotification(ctx, tokenData, trx, transition.NotificationTemplate)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to hold transaction for review")
+	}
+
+	return nil
+}
+
+// fraudReviewDecision is the decision on the pending review ID, recorded
+// with the status change it makes.
+type fraudReviewDecision struct {
+	ID         int    `json:"id"`
+	Status     string `json:"status"`
+	ReviewedBy string `json:"reviewed_by"`
+	Note       string `json:"note,omitempty"`
+}
+
+// decideFraudReview records decision on its review, once the limits of trx
+// are checked with the lock held when it is an approval. It runs in the
+// database transaction that writes the status, so only one decision is made
+// and it is only kept with the status.
+func (s *TransactionService) decideFraudReview(ctx context.Context, trx models.Transaction, decision *fraudReviewDecision) error {
+	// the limits may have been used up or lowered while trx was held
+	if decision.Status == constants.FraudReviewStatusApproved {
+		err := s.TransactionLimitService.CheckTransactionLimit(ctx, trx)
+		if err != nil {
+			return err
+		}
+	}
+
+	claimed, err := s.FraudRepo.ClaimFraudReview(ctx, decision.ID, decision.Status, decision.ReviewedBy, decision.Note)
+	if err != nil {
+		return errors.Wrap(err, "failed to claim fraud review")
+	}
+	if !claimed {
+		return constants.ErrFraudReviewNotFound
+	}
+	return nil
+}
+
+// ReviewTransaction approves or rejects a transaction held UNDER_REVIEW.
+// Approving makes the status change that was held, with its wallet call;
+// rejecting moves the transaction to FAILED.
+func (s *TransactionService) ReviewTransaction(ctx context.Context, tokenData models.TokenData, reference string, approve bool, note string) (models.CreateTransactionResponse, error) {
+	var (
+		resp models.CreateTransactionResponse
+	)
+
+	review, err := s.FraudRepo.GetPendingFraudReview(ctx, reference)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, constants.ErrFraudReviewNotFound
+	}
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to get fraud review")
+	}
+
+	trx, err := s.TransactionRepo.GetTransactionByReference(ctx, reference, false)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to get transaction")
+	}
+	if trx.TransactionStatus != constants.TransactionStatusUnderReview {
+		return resp, constants.ErrFraudReviewNotFound
+	}
+
+	reviewStatus, toStatus := constants.FraudReviewStatusApproved, review.ToStatus
+	if !approve {
+		reviewStatus, toStatus = constants.FraudReviewStatusRejected, constants.TransactionStatusFailed
+	}
+
+	// checked again with the lock held when the review is recorded, this
+	// first check refuses most approvals before any wallet call
+	if approve {
+		err = s.TransactionLimitService.CheckTransactionLimit(ctx, trx)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	reviewInfo, err := json.Marshal(map[string]interface{}{
+		"fraud_review": reviewStatus,
+	})
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to marshal fraud review info")
+	}
+
+	err = s.updateStatusTransaction(ctx, tokenData, trx, &models.UpdateStatusTransaction{
+		Reference:         reference,
+		TransactionStatus: toStatus,
+		AddtionalInfo:     string(reviewInfo),
+	}, &fraudReviewDecision{
+		ID:         review.ID,
+		Status:     reviewStatus,
+		ReviewedBy: tokenData.Username,
+		Note:       note,
+	})
+	if err != nil {
+		return resp, err
+	}
+	trx.TransactionStatus = toStatus
+
+	// a transfer approved at creation is settled straight away like any
+	// other, without being screened again for what was just approved
+	if trx.TransactionType == constants.TransactionTypeTransfer && toStatus == constants.TransactionStatusPending {
+		trx, err = s.TransactionRepo.GetTransactionByReference(ctx, reference, false)
+		if err != nil {
+			return resp, errors.Wrap(err, "failed to get transaction")
+		}
+		trx.TransactionStatus, err = s.settleTransfer(ctx, systemTokenData(), trx, false)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	resp.Reference = reference
+	resp.TransactionStatus = trx.TransactionStatus
+	resp.FeeAmount = trx.FeeAmount
+
+	return resp, nil
+}
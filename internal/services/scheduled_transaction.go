@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type ScheduledTransactionService struct {
+	ScheduledTransactionRepo interfaces.IScheduledTransactionRepo
+	TransactionService       interfaces.ITransactionService
+}
+
+func (s *ScheduledTransactionService) CreateSchedule(ctx context.Context, tokenData models.TokenData, req models.CreateScheduledTransaction) (models.ScheduledTransaction, error) {
+	var (
+		schedule models.ScheduledTransaction
+		now      = time.Now()
+	)
+
+	if !constants.MapTransactionType[req.TransactionType] || req.TransactionType == constants.TransactionTypeRefund {
+		return schedule, constants.ErrInvalidSchedule
+	}
+	if req.Currency == "" {
+		req.Currency = models.DefaultCurrency
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if !req.StartAt.After(now) || (req.EndAt != nil && !req.EndAt.After(req.StartAt)) {
+		return schedule, constants.ErrInvalidSchedule
+	}
+
+	// the recipient and the bank account are checked again by every run
+	isTransfer := req.TransactionType == constants.TransactionTypeTransfer
+	if isTransfer != (req.RecipientUserID != 0) || req.RecipientUserID < 0 || req.RecipientUserID == int(tokenData.UserID) {
+		return schedule, constants.ErrInvalidSchedule
+	}
+	isWithdrawal := req.TransactionType == constants.TransactionTypeWithdrawal
+	if isWithdrawal != (req.BankAccount != nil) || (req.BankAccount != nil && req.BankAccount.Validate() != nil) {
+		return schedule, constants.ErrInvalidSchedule
+	}
+
+	if req.AddtionalInfo != "" {
+		var additionalInfo map[string]interface{}
+		if json.Unmarshal([]byte(req.AddtionalInfo), &additionalInfo) != nil {
+			return schedule, constants.ErrInvalidSchedule
+		}
+	}
+
+	schedule = models.ScheduledTransaction{
+		UserID:          int(tokenData.UserID),
+		TransactionType: req.TransactionType,
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		Description:     req.Description,
+		AddtionalInfo:   req.AddtionalInfo,
+		RecipientUserID: req.RecipientUserID,
+		BankAccount:     req.BankAccount,
+		Recurrence:      req.Recurrence,
+		Timezone:        req.Timezone,
+		StartAt:         req.StartAt,
+		EndAt:           req.EndAt,
+		Status:          constants.ScheduleStatusActive,
+		UserEmail:       tokenData.Email,
+		UserFullName:    tokenData.FullName,
+		CreatedBy:       tokenData.Username,
+		UpdatedBy:       tokenData.Username,
+	}
+
+	next, err := nextScheduleRun(schedule, now)
+	if err != nil || next == nil {
+		return models.ScheduledTransaction{}, constants.ErrInvalidSchedule
+	}
+	schedule.NextRunAt = next
+
+	err = s.ScheduledTransactionRepo.CreateScheduledTransaction(ctx, &schedule)
+	if err != nil {
+		return models.ScheduledTransaction{}, errors.Wrap(err, "failed to insert scheduled transaction")
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduledTransactionService) GetSchedules(ctx context.Context, tokenData models.TokenData) ([]models.ScheduledTransaction, error) {
+	return s.ScheduledTransactionRepo.GetScheduledTransactionsByUserID(ctx, int(tokenData.UserID))
+}
+
+func (s *ScheduledTransactionService) GetSchedule(ctx context.Context, tokenData models.TokenData, id int) (models.ScheduledTransaction, error) {
+	return s.getOwnSchedule(ctx, tokenData, id)
+}
+
+func (s *ScheduledTransactionService) GetScheduleRuns(ctx context.Context, tokenData models.TokenData, id, limit, offset int) ([]models.ScheduledTransactionRun, error) {
+	schedule, err := s.getOwnSchedule(ctx, tokenData, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ScheduledTransactionRepo.GetScheduledTransactionRuns(ctx, schedule.ID, limit, offset)
+}
+
+func (s *ScheduledTransactionService) PauseSchedule(ctx context.Context, tokenData models.TokenData, id int) error {
+	schedule, err := s.getOwnSchedule(ctx, tokenData, id)
+	if err != nil {
+		return err
+	}
+
+	return s.updateScheduleStatus(ctx, tokenData, schedule, []string{constants.ScheduleStatusActive}, constants.ScheduleStatusPaused, nil)
+}
+
+// ResumeSchedule continues a paused schedule from now on. Recurring runs
+// missed while it was paused are skipped, a missed one-off run is made right
+// away.
+func (s *ScheduledTransactionService) ResumeSchedule(ctx context.Context, tokenData models.TokenData, id int) error {
+	schedule, err := s.getOwnSchedule(ctx, tokenData, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	next, err := nextScheduleRun(schedule, now)
+	if err != nil {
+		return errors.Wrap(err, "failed to get next run of scheduled transaction")
+	}
+	if schedule.Recurrence == "" && next == nil {
+		next = &now
+	}
+
+	status := constants.ScheduleStatusActive
+	if next == nil {
+		status = constants.ScheduleStatusCompleted
+	}
+
+	return s.updateScheduleStatus(ctx, tokenData, schedule, []string{constants.ScheduleStatusPaused}, status, next)
+}
+
+func (s *ScheduledTransactionService) CancelSchedule(ctx context.Context, tokenData models.TokenData, id int) error {
+	schedule, err := s.getOwnSchedule(ctx, tokenData, id)
+	if err != nil {
+		return err
+	}
+
+	return s.updateScheduleStatus(ctx, tokenData, schedule, []string{constants.ScheduleStatusActive, constants.ScheduleStatusPaused}, constants.ScheduleStatusCancelled, nil)
+}
+
+func (s *ScheduledTransactionService) updateScheduleStatus(ctx context.Context, tokenData models.TokenData, schedule models.ScheduledTransaction, fromStatuses []string, status string, nextRunAt *time.Time) error {
+	updated, err := s.ScheduledTransactionRepo.UpdateScheduledTransactionStatus(ctx, schedule.ID, fromStatuses, status, nextRunAt, tokenData.Username)
+	if err != nil {
+		return errors.Wrap(err, "failed to update scheduled transaction status")
+	}
+	if !updated {
+		return constants.ErrScheduleStatus
+	}
+	return nil
+}
+
+// getOwnSchedule returns schedule id if it belongs to tokenData. Schedules of
+// other users are reported as not found, except to operators and the system.
+func (s *ScheduledTransactionService) getOwnSchedule(ctx context.Context, tokenData models.TokenData, id int) (models.ScheduledTransaction, error) {
+	schedule, err := s.ScheduledTransactionRepo.GetScheduledTransaction(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return schedule, constants.ErrScheduleNotFound
+	}
+	if err != nil {
+		return schedule, errors.Wrap(err, "failed to get scheduled transaction")
+	}
+
+	if schedule.UserID != int(tokenData.UserID) && !tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+		return models.ScheduledTransaction{}, constants.ErrScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+// RunDueSchedules creates the transactions of the schedules that are due.
+// Each schedule is moved to its next run before its transaction is created,
+// so a crash in between skips the run instead of paying twice. Runs missed
+// while the worker was down are not caught up, which could pay several
+// times at once: the earliest due run is made and the later ones until now
+// are recorded as SKIPPED, so the owner can see them in the run history.
+func (s *ScheduledTransactionService) RunDueSchedules(ctx context.Context) error {
+	var (
+		log = helpers.Logger
+		now = time.Now()
+		ran = 0
+	)
+
+	schedules, err := s.ScheduledTransactionRepo.GetDueScheduledTransactions(ctx, now, constants.ScheduleBatchSize)
+	if err != nil {
+		return errors.Wrap(err, "failed to get due scheduled transactions")
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		next, err := nextScheduleRun(*schedule, now)
+		if err != nil {
+			log.Errorf("failed to get next run of scheduled transaction %d: %v", schedule.ID, err)
+			continue
+		}
+
+		status := constants.ScheduleStatusActive
+		if next == nil {
+			status = constants.ScheduleStatusCompleted
+		}
+
+		claimed, err := s.ScheduledTransactionRepo.ClaimScheduledTransaction(ctx, schedule, status, next)
+		if err != nil {
+			log.Error("failed to claim scheduled transaction: ", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		s.runSchedule(ctx, *schedule)
+		s.skipMissedRuns(ctx, *schedule, now)
+		ran++
+	}
+
+	log.Infof("ran %d scheduled transactions", ran)
+
+	return nil
+}
+
+// runSchedule creates the transaction of the due run of schedule and records
+// the outcome.
+func (s *ScheduledTransactionService) runSchedule(ctx context.Context, schedule models.ScheduledTransaction) {
+	var (
+		log = helpers.Logger
+		run = models.ScheduledTransactionRun{
+			ScheduledTransactionID: schedule.ID,
+			ScheduledAt:            *schedule.NextRunAt,
+			Status:                 constants.ScheduleRunStatusSuccess,
+		}
+	)
+
+	resp, err := s.createScheduledTransaction(ctx, schedule)
+	// a pending wallet call is finished by recovery, the run has its reference
+	if errors.Is(err, constants.ErrBalanceOperationPending) {
+		run.Error = constants.ErrBalanceOperationPending.Error()
+		err = nil
+	}
+	if err != nil {
+		log.Warnf("scheduled transaction %d failed to run: %v", schedule.ID, err)
+		run.Status = constants.ScheduleRunStatusFailed
+		run.Error = err.Error()
+	}
+	run.TransactionReference = resp.Reference
+	run.TransactionStatus = resp.TransactionStatus
+
+	err = s.ScheduledTransactionRepo.CreateScheduledTransactionRun(ctx, &run)
+	if err != nil {
+		log.Error("failed to insert scheduled transaction run: ", err)
+	}
+}
+
+// skipMissedRuns records the runs of schedule after its due run and up to
+// now as skipped.
+func (s *ScheduledTransactionService) skipMissedRuns(ctx context.Context, schedule models.ScheduledTransaction, now time.Time) {
+	var (
+		log = helpers.Logger
+	)
+
+	missed := *schedule.NextRunAt
+	for i := 0; i < constants.MaxSkippedScheduleRuns; i++ {
+		next, err := nextScheduleRun(schedule, missed)
+		if err != nil || next == nil || next.After(now) {
+			return
+		}
+		missed = *next
+
+		run := models.ScheduledTransactionRun{
+			ScheduledTransactionID: schedule.ID,
+			ScheduledAt:            missed,
+			Status:                 constants.ScheduleRunStatusSkipped,
+			Error:                  constants.ErrScheduleRunMissed.Error(),
+		}
+		err = s.ScheduledTransactionRepo.CreateScheduledTransactionRun(ctx, &run)
+		if err != nil {
+			log.Error("failed to insert skipped scheduled transaction run: ", err)
+			return
+		}
+	}
+}
+
+func (s *ScheduledTransactionService) createScheduledTransaction(ctx context.Context, schedule models.ScheduledTransaction) (models.CreateTransactionResponse, error) {
+	scheduleInfo, err := json.Marshal(map[string]interface{}{
+		"scheduled_transaction_id": schedule.ID,
+	})
+	if err != nil {
+		return models.CreateTransactionResponse{}, errors.Wrap(err, "failed to marshal schedule info")
+	}
+
+	additionalInfo, err := mergeAdditionalInfo(schedule.AddtionalInfo, string(scheduleInfo))
+	if err != nil {
+		return models.CreateTransactionResponse{}, err
+	}
+
+	trx := models.Transaction{
+		UserID:          schedule.UserID,
+		Amount:          schedule.Amount,
+		Currency:        schedule.Currency,
+		TransactionType: schedule.TransactionType,
+		Description:     schedule.Description,
+		AddtionalInfo:   string(additionalInfo),
+		RecipientUserID: schedule.RecipientUserID,
+		BankAccount:     schedule.BankAccount,
+		CreatedBy:       schedule.CreatedBy,
+		UpdatedBy:       schedule.CreatedBy,
+		UserEmail:       schedule.UserEmail,
+		UserFullName:    schedule.UserFullName,
+	}
+
+	err = trx.Validate()
+	if err != nil {
+		return models.CreateTransactionResponse{}, err
+	}
+
+	return s.TransactionService.CreateTransaction(ctx, scheduleOwnerTokenData(schedule), &trx)
+}
+
+// scheduleOwnerTokenData is the caller of a run of schedule: its owner, with
+// the system acting on their behalf. The owner's token is not kept, so the
+// wallet is called with the system one.
+func scheduleOwnerTokenData(schedule models.ScheduledTransaction) models.TokenData {
+	return models.TokenData{
+		UserID:   int64(schedule.UserID),
+		Username: schedule.CreatedBy,
+		FullName: schedule.UserFullName,
+		Email:    schedule.UserEmail,
+		Token:    helpers.GetEnv("WALLET_SYSTEM_TOKEN", ""),
+		Roles:    []string{constants.RoleUser},
+	}
+}
+
+// nextScheduleRun returns the first run of schedule after after, or nil when
+// it will not run again.
+func nextScheduleRun(schedule models.ScheduledTransaction, after time.Time) (*time.Time, error) {
+	var (
+		next time.Time
+	)
+
+	if schedule.Recurrence == "" {
+		if !schedule.StartAt.After(after) {
+			return nil, nil
+		}
+		next = schedule.StartAt
+	} else {
+		cron, err := helpers.ParseCron(schedule.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+
+		location, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return nil, err
+		}
+
+		if after.Before(schedule.StartAt) {
+			after = schedule.StartAt.Add(-time.Nanosecond)
+		}
+
+		next = cron.Next(after.In(location))
+		if next.IsZero() {
+			return nil, nil
+		}
+	}
+
+	if schedule.EndAt != nil && next.After(*schedule.EndAt) {
+		return nil, nil
+	}
+
+	return &next, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+)
+
+// fakeScheduledTransactionRepo keeps the schedules and their runs in memory.
+type fakeScheduledTransactionRepo struct {
+	interfaces.IScheduledTransactionRepo
+	schedules []models.ScheduledTransaction
+	runs      []models.ScheduledTransactionRun
+}
+
+func (r *fakeScheduledTransactionRepo) GetDueScheduledTransactions(ctx context.Context, now time.Time, limit int) ([]models.ScheduledTransaction, error) {
+	var resp []models.ScheduledTransaction
+	for _, schedule := range r.schedules {
+		if schedule.Status == constants.ScheduleStatusActive && schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			resp = append(resp, schedule)
+		}
+	}
+	return resp, nil
+}
+
+func (r *fakeScheduledTransactionRepo) ClaimScheduledTransaction(ctx context.Context, schedule *models.ScheduledTransaction, status string, nextRunAt *time.Time) (bool, error) {
+	for i := range r.schedules {
+		row := &r.schedules[i]
+		if row.ID == schedule.ID && row.Status == constants.ScheduleStatusActive && row.NextRunAt.Equal(*schedule.NextRunAt) {
+			row.Status = status
+			row.NextRunAt = nextRunAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeScheduledTransactionRepo) CreateScheduledTransactionRun(ctx context.Context, run *models.ScheduledTransactionRun) error {
+	run.ID = len(r.runs) + 1
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+// scheduleRuns lists the runs as status and scheduled time.
+func scheduleRuns(r *fakeScheduledTransactionRepo) []string {
+	var resp []string
+	for _, run := range r.runs {
+		resp = append(resp, run.Status+" "+run.ScheduledAt.UTC().Format(time.RFC3339))
+	}
+	return resp
+}
+
+// A due run is made as the owner of the schedule, and the runs missed while
+// the worker was down are recorded as skipped.
+func TestRunDueSchedules(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		f    = newFakeTransactionService()
+		hour = time.Now().UTC().Truncate(time.Hour)
+		due  = hour.Add(-3 * time.Hour)
+	)
+
+	repo := &fakeScheduledTransactionRepo{schedules: []models.ScheduledTransaction{{
+		ID:              1,
+		UserID:          int(testUser.UserID),
+		TransactionType: constants.TransactionTypePurchase,
+		Amount:          10000,
+		Currency:        "IDR",
+		Description:     "subscription",
+		Recurrence:      "0 * * * *",
+		Timezone:        "UTC",
+		StartAt:         due.Add(-24 * time.Hour),
+		NextRunAt:       &due,
+		Status:          constants.ScheduleStatusActive,
+		UserEmail:       testUser.Email,
+		UserFullName:    testUser.FullName,
+		CreatedBy:       testUser.Username,
+	}}}
+	s := &ScheduledTransactionService{
+		ScheduledTransactionRepo: repo,
+		TransactionService:       f.TransactionService,
+	}
+
+	err := s.RunDueSchedules(ctx)
+	if err != nil {
+		t.Fatalf("RunDueSchedules() error = %v", err)
+	}
+
+	assertEqual(t, "runs", scheduleRuns(repo), []string{
+		constants.ScheduleRunStatusSuccess + " " + due.Format(time.RFC3339),
+		constants.ScheduleRunStatusSkipped + " " + due.Add(time.Hour).Format(time.RFC3339),
+		constants.ScheduleRunStatusSkipped + " " + due.Add(2*time.Hour).Format(time.RFC3339),
+		constants.ScheduleRunStatusSkipped + " " + hour.Format(time.RFC3339),
+	})
+	if next := repo.schedules[0].NextRunAt; next == nil || !next.Equal(hour.Add(time.Hour)) {
+		t.Errorf("next run = %v, want %v", next, hour.Add(time.Hour))
+	}
+
+	assertTransactionStatus(t, f, repo.runs[0].TransactionReference, constants.TransactionStatusPending)
+	if len(f.outbox.history) != 1 {
+		t.Fatalf("status history has %d rows, want 1", len(f.outbox.history))
+	}
+	history := f.outbox.history[0]
+	if history.ActorUserID != int(testUser.UserID) || history.Actor != testUser.Username {
+		t.Errorf("status changed by %d %q, want the owner %d %q", history.ActorUserID, history.Actor, testUser.UserID, testUser.Username)
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultStateMachines is used when TRANSACTION_STATE_MACHINE_FILE is not
+// set. state_machine.example.json holds the same definition.
+var DefaultStateMachines = models.StateMachines{
+	constants.TransactionTypeTopup: {
+		{From: "", To: constants.TransactionStatusPending},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationCredit, NotificationTemplate: "topup_success", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusFailed, NotificationTemplate: "topup_failed"},
+		{From: constants.TransactionStatusFailed, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationCredit, NotificationTemplate: "topup_success", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusSuccess, To: constants.TransactionStatusReversed, WalletOperation: constants.BalanceOperationDebit, WalletReferencePrefix: "REVERSED-", FeeWalletOperation: constants.BalanceOperationCredit, FeeWalletReferencePrefix: "FEE-REVERSED-"},
+		{From: "", To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusPending},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationCredit, NotificationTemplate: "topup_success", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusFailed, NotificationTemplate: "topup_failed"},
+	},
+	constants.TransactionTypePurchase: {
+		{From: "", To: constants.TransactionStatusPending},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "purchase_success", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusFailed, NotificationTemplate: "purchase_failed"},
+		{From: constants.TransactionStatusFailed, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "purchase_success", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusSuccess, To: constants.TransactionStatusReversed, WalletOperation: constants.BalanceOperationCredit, WalletReferencePrefix: "REVERSED-", NotificationTemplate: "purchase_reversed", FeeWalletOperation: constants.BalanceOperationCredit, FeeWalletReferencePrefix: "FEE-REVERSED-"},
+		{From: "", To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusPending},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "purchase_success", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusFailed, NotificationTemplate: "purchase_failed"},
+	},
+	constants.TransactionTypeRefund: {
+		{From: "", To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationCredit, NotificationTemplate: "refund", FeeWalletOperation: constants.BalanceOperationCredit, FeeWalletReferencePrefix: "FEE-"},
+	},
+	constants.TransactionTypeTransfer: {
+		{From: "", To: constants.TransactionStatusPending},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "transfer_success", CounterpartyWalletOperation: constants.BalanceOperationCredit, CounterpartyWalletReferencePrefix: "TRANSFER-IN-", CounterpartyNotificationTemplate: "transfer_received", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusFailed, NotificationTemplate: "transfer_failed"},
+		{From: "", To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusPending},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusSuccess, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "transfer_success", CounterpartyWalletOperation: constants.BalanceOperationCredit, CounterpartyWalletReferencePrefix: "TRANSFER-IN-", CounterpartyNotificationTemplate: "transfer_received", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusFailed, NotificationTemplate: "transfer_failed"},
+	},
+	constants.TransactionTypeWithdrawal: {
+		// the fee is held with the amount and returned with it
+		{From: "", To: constants.TransactionStatusPending, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "withdrawal_pending", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusSuccess, NotificationTemplate: "withdrawal_success"},
+		{From: constants.TransactionStatusPending, To: constants.TransactionStatusFailed, WalletOperation: constants.BalanceOperationCredit, WalletReferencePrefix: "RETURNED-", NotificationTemplate: "withdrawal_failed", FeeWalletOperation: constants.BalanceOperationCredit, FeeWalletReferencePrefix: "FEE-RETURNED-"},
+		{From: "", To: constants.TransactionStatusUnderReview},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusPending, WalletOperation: constants.BalanceOperationDebit, NotificationTemplate: "withdrawal_pending", FeeWalletOperation: constants.BalanceOperationDebit, FeeWalletReferencePrefix: "FEE-"},
+		{From: constants.TransactionStatusUnderReview, To: constants.TransactionStatusFailed, NotificationTemplate: "withdrawal_failed"},
+	},
+}
+
+// LoadStateMachines reads the state machines from the JSON file at path, or
+// returns DefaultStateMachines when path is empty.
+func LoadStateMachines(path string) (models.StateMachines, error) {
+	if path == "" {
+		return DefaultStateMachines, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read state machine file")
+	}
+
+	var machines models.StateMachines
+	err = json.Unmarshal(b, &machines)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse state machine file")
+	}
+
+	err = validateStateMachines(machines)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid state machine file")
+	}
+
+	return machines, nil
+}
+
+func validateStateMachines(machines models.StateMachines) error {
+	for transactionType, transitions := range machines {
+		if !constants.MapTransactionType[transactionType] {
+			return fmt.Errorf("unknown transaction type %s", transactionType)
+		}
+
+		seen := map[[2]string]bool{}
+		created := false
+		for _, transition := range transitions {
+			if transition.From != "" && !constants.MapTransactionStatus[transition.From] {
+				return fmt.Errorf("%s: unknown status %s", transactionType, transition.From)
+			}
+			if !constants.MapTransactionStatus[transition.To] {
+				return fmt.Errorf("%s: unknown status %s", transactionType, transition.To)
+			}
+			if transition.WalletOperation != "" && transition.WalletOperation != constants.BalanceOperationCredit && transition.WalletOperation != constants.BalanceOperationDebit {
+				return fmt.Errorf("%s: unknown wallet operation %s", transactionType, transition.WalletOperation)
+			}
+			if transition.WalletOperation == "" && transition.WalletReferencePrefix != "" {
+				return fmt.Errorf("%s: wallet reference prefix without wallet operation from %s to %s", transactionType, transition.From, transition.To)
+			}
+			if transition.To == constants.TransactionStatusUnderReview && (transition.WalletOperation != "" || transition.FeeWalletOperation != "") {
+				return fmt.Errorf("%s: the transition from %s to %s holds the transaction and cannot have a wallet operation", transactionType, transition.From, transition.To)
+			}
+			if transition.CounterpartyWalletOperation != "" || transition.CounterpartyWalletReferencePrefix != "" || transition.CounterpartyNotificationTemplate != "" {
+				err := validateCounterparty(transactionType, transition)
+				if err != nil {
+					return err
+				}
+			}
+
+			if transition.FeeWalletOperation != "" || transition.FeeWalletReferencePrefix != "" {
+				err := validateFee(transactionType, transition)
+				if err != nil {
+					return err
+				}
+			}
+
+			key := [2]string{transition.From, transition.To}
+			if seen[key] {
+				return fmt.Errorf("%s: duplicate transition from %s to %s", transactionType, transition.From, transition.To)
+			}
+			seen[key] = true
+			created = created || transition.From == ""
+		}
+
+		if !created {
+			return fmt.Errorf("%s: no transition from \"\" to create the transaction", transactionType)
+		}
+	}
+
+	// refunds are always paid back through the wallet
+	refund, err := machines.Transition(constants.TransactionTypeRefund, "", constants.TransactionStatusSuccess)
+	if err != nil || refund.WalletOperation == "" {
+		return fmt.Errorf("%s: the transition from \"\" to %s must have a wallet operation", constants.TransactionTypeRefund, constants.TransactionStatusSuccess)
+	}
+
+	return nil
+}
+
+// validateCounterparty checks the counterparty fields of transition, which
+// only transfers have a recipient for.
+func validateCounterparty(transactionType string, transition models.StateTransition) error {
+	if transactionType != constants.TransactionTypeTransfer {
+		return fmt.Errorf("%s: only %s transitions have a counterparty", transactionType, constants.TransactionTypeTransfer)
+	}
+	if transition.From == "" {
+		return fmt.Errorf("%s: the transition that creates the transaction cannot have a counterparty", transactionType)
+	}
+	if transition.CounterpartyWalletOperation == "" {
+		if transition.CounterpartyWalletReferencePrefix != "" {
+			return fmt.Errorf("%s: counterparty wallet reference prefix without counterparty wallet operation from %s to %s", transactionType, transition.From, transition.To)
+		}
+		return nil
+	}
+	if transition.CounterpartyWalletOperation != constants.BalanceOperationCredit && transition.CounterpartyWalletOperation != constants.BalanceOperationDebit {
+		return fmt.Errorf("%s: unknown counterparty wallet operation %s", transactionType, transition.CounterpartyWalletOperation)
+	}
+	if transition.WalletOperation == "" {
+		return fmt.Errorf("%s: counterparty wallet operation without wallet operation from %s to %s", transactionType, transition.From, transition.To)
+	}
+	if transition.CounterpartyWalletReferencePrefix == transition.WalletReferencePrefix {
+		return fmt.Errorf("%s: the counterparty wallet reference prefix from %s to %s must differ from the wallet reference prefix", transactionType, transition.From, transition.To)
+	}
+	return nil
+}
+
+// validateFee checks the fee fields of transition. A transition creating
+// the transaction only makes wallet calls when it has a wallet operation, so
+// it needs one to have a fee.
+func validateFee(transactionType string, transition models.StateTransition) error {
+	if transition.FeeWalletOperation != constants.BalanceOperationCredit && transition.FeeWalletOperation != constants.BalanceOperationDebit {
+		return fmt.Errorf("%s: unknown fee wallet operation %q from %s to %s", transactionType, transition.FeeWalletOperation, transition.From, transition.To)
+	}
+	if transition.From == "" && transition.WalletOperation == "" {
+		return fmt.Errorf("%s: the transition that creates the transaction cannot have a fee wallet operation without a wallet operation", transactionType)
+	}
+	if transition.FeeWalletReferencePrefix == "" || transition.FeeWalletReferencePrefix == transition.WalletReferencePrefix || transition.FeeWalletReferencePrefix == transition.CounterpartyWalletReferencePrefix {
+		return fmt.Errorf("%s: the fee wallet reference prefix from %s to %s must be set and differ from the other wallet reference prefixes", transactionType, transition.From, transition.To)
+	}
+	return nil
+}
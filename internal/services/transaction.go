@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"ewallet-transaction/constants"
 	"ewallet-transaction/external"
@@ -9,23 +10,76 @@ import (
 	"ewallet-transaction/internal/interfaces"
 	"ewallet-transaction/internal/models"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 type TransactionService struct {
-	TransactionRepo interfaces.ITransactionRepo
-	External        interfaces.IExternal
+	TransactionRepo        interfaces.ITransactionRepo
+	BalanceOperationRepo   interfaces.IBalanceOperationRepo
+	NotificationOutboxRepo interfaces.INotificationOutboxRepo
+	External               interfaces.IExternal
+	ReferenceGenerator     interfaces.IReferenceGenerator
+
+	TransactionStatusHistoryRepo interfaces.ITransactionStatusHistoryRepo
+	WebhookRepo                  interfaces.IWebhookRepo
+	EventPublisher               interfaces.IEventPublisher
+	StateMachines                models.StateMachines
+	TransactionLimitService      interfaces.ITransactionLimitService
+	FraudService                 interfaces.IFraudService
+	FraudRepo                    interfaces.IFraudRepo
+	FeeRules                     []models.FeeRule
 }
 
-func (s *TransactionService) CreateTransaction(ctx context.Context, req *models.Transaction) (models.CreateTransactionResponse, error) {
+func (s *TransactionService) CreateTransaction(ctx context.Context, tokenData models.TokenData, req *models.Transaction) (models.CreateTransactionResponse, error) {
 	var (
 		resp models.CreateTransactionResponse
 	)
 
 	req.TransactionStatus = constants.TransactionStatusPending
-	req.Reference = helpers.GenerateReference()
+	req.ParentReference = ""
+	if req.Currency == "" {
+		req.Currency = models.DefaultCurrency
+	}
+
+	transition, err := s.StateMachines.Transition(req.TransactionType, "", req.TransactionStatus)
+	if err != nil {
+		return resp, err
+	}
+
+	err = s.resolveTransferRecipient(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	err = validateBankAccount(req)
+	if err != nil {
+		return resp, err
+	}
+
+	req.FeeAmount, req.FeeRule = calculateFee(s.FeeRules, *req)
+
+	// checked again with the lock held when the transaction is inserted,
+	// this first check refuses most requests before any wallet call
+	err = s.TransactionLimitService.CheckTransactionLimit(ctx, *req)
+	if err != nil {
+		return resp, err
+	}
+
+	evaluation, err := s.screenTransaction(ctx, *req, "", req.TransactionStatus)
+	if err != nil {
+		return resp, err
+	}
+	if isHeld(evaluation) {
+		req.TransactionStatus = constants.TransactionStatusUnderReview
+		transition, err = s.StateMachines.Transition(req.TransactionType, "", req.TransactionStatus)
+		if err != nil {
+			return resp, err
+		}
+	}
 
 	jsonAdditionalInfo := map[string]interface{}{}
 	if req.AddtionalInfo != "" {
@@ -35,249 +89,911 @@ func (s *TransactionService) CreateTransaction(ctx context.Context, req *models.
 		}
 	}
 
-	err := s.TransactionRepo.CreateTransaction(ctx, req)
+	for attempt := 1; attempt <= constants.MaxReferenceAttempts; attempt++ {
+		req.Reference = s.ReferenceGenerator.Generate()
+		insert := func(ctx context.Context) error {
+			err := s.TransactionLimitService.CheckTransactionLimit(ctx, *req)
+			if err != nil {
+				return err
+			}
+			err = s.TransactionRepo.CreateTransaction(ctx, req)
+			if err != nil {
+				return err
+			}
+			err = s.recordFraudEvaluation(ctx, evaluation, req.Reference)
+			if err != nil {
+				return err
+			}
+			err = s.recordStatusChange(ctx, tokenData, *req, "", req.AddtionalInfo)
+			if err != nil {
+				return err
+			}
+			return s.sendNotification(ctx, tokenData, *req, transition.NotificationTemplate)
+		}
+
+		if transition.WalletOperation == "" {
+			err = s.TransactionRepo.WithTransaction(ctx, insert)
+		} else {
+			err = s.createWithBalanceOperation(ctx, tokenData, req, transition, insert)
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			break
+		}
+		helpers.Logger.Warnf("reference %s already exists, retrying", req.Reference)
+	}
+	// the reference is returned with a pending wallet call so the caller can
+	// follow the transaction, which recovery creates once the call is known
+	if errors.Is(err, constants.ErrBalanceOperationPending) {
+		resp.Reference = req.Reference
+		resp.TransactionStatus = req.TransactionStatus
+		return resp, err
+	}
 	if err != nil {
 		return resp, errors.Wrap(err, "failed to insert create transaction")
 	}
 
+	if req.TransactionType == constants.TransactionTypeTransfer && req.TransactionStatus == constants.TransactionStatusPending {
+		req.TransactionStatus, err = s.settleTransfer(ctx, tokenData, *req, true)
+		if errors.Is(err, constants.ErrBalanceOperationPending) {
+			resp.Reference = req.Reference
+			resp.TransactionStatus = req.TransactionStatus
+			return resp, err
+		}
+		if err != nil {
+			return resp, err
+		}
+	}
+
 	resp.Reference = req.Reference
 	resp.TransactionStatus = req.TransactionStatus
+	resp.FeeAmount = req.FeeAmount
 
 	return resp, nil
 }
 
 func (s *TransactionService) UpdateStatusTransaction(ctx context.Context, tokenData models.TokenData, req *models.UpdateStatusTransaction) error {
 
+	// only operators and the system settle transactions
+	if !tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+		return constants.ErrTransactionForbidden
+	}
+
 	// get transaction by reference
 	trx, err := s.TransactionRepo.GetTransactionByReference(ctx, req.Reference, false)
 	if err != nil {
 		return errors.Wrap(err, "failed to get transaction")
 	}
 
-	// check transaction flow
-	isValid := false
-	mapStatusFlow := constants.MapTransactionStatusFlow[trx.TransactionStatus]
-	for i := range mapStatusFlow {
-		if mapStatusFlow[i] == req.TransactionStatus {
-			isValid = true
+	// held transactions only leave UNDER_REVIEW through ReviewTransaction
+	if trx.TransactionStatus == constants.TransactionStatusUnderReview {
+		return constants.ErrTransactionUnderReview
+	}
+	if req.TransactionStatus == constants.TransactionStatusUnderReview {
+		return &models.InvalidTransitionError{
+			TransactionType: trx.TransactionType,
+			From:            trx.TransactionStatus,
+			To:              req.TransactionStatus,
 		}
 	}
 
-	if !isValid {
-		return fmt.Errorf("transaction status flow invalid. request satus = %s", req.TransactionStatus)
+	_, err = s.changeStatus(ctx, tokenData, trx, req)
+	return err
+}
+
+// updateStatusTransaction moves trx to req.TransactionStatus, making the
+// wallet calls of the transition on the way. A non-nil review is recorded in
+// the same database transaction as the status.
+func (s *TransactionService) updateStatusTransaction(ctx context.Context, tokenData models.TokenData, trx models.Transaction, req *models.UpdateStatusTransaction, review *fraudReviewDecision) error {
+	// check transaction flow
+	transition, err := s.StateMachines.Transition(trx.TransactionType, trx.TransactionStatus, req.TransactionStatus)
+	if err != nil {
+		return err
 	}
 
 	// request update balance
 	reqUpdateBalance := external.UpdateBalance{
-		Reference: req.Reference,
+		Reference: transition.WalletReferencePrefix + req.Reference,
 		Amount:    trx.Amount,
+		Currency:  trx.Currency,
 	}
 
 	if req.TransactionStatus == constants.TransactionStatusReversed {
-		reqUpdateBalance.Reference = "REVERSED-" + req.Reference
 		now := time.Now()
 
 		expiredReversalTime := trx.CreatedAt.Add(constants.MaximumReversalDuration)
 		if now.After(expiredReversalTime) {
 			return errors.New("reversal duration is already expired")
 		}
+
+		// checked again with the purchase locked when the status is written,
+		// this first check refuses most reversals before any wallet call
+		err = s.checkNotRefunded(ctx, trx.Reference)
+		if err != nil {
+			return err
+		}
 	}
 
-	var (
-		errUpdateBalance error
-		jwt              = tokenData.Token
-	)
+	// update additional info
+	byteAdditionalInfo, err := mergeAdditionalInfo(trx.AddtionalInfo, req.AddtionalInfo)
+	if err != nil {
+		return err
+	}
 
-	switch trx.TransactionType {
-	case constants.TransactionTypeTopup:
-		switch req.TransactionStatus {
-		case constants.TransactionStatusSuccess:
-			_, errUpdateBalance = s.External.CreditBalance(ctx, jwt, reqUpdateBalance)
-		case constants.TransactionStatusReversed:
-			_, errUpdateBalance = s.External.DebitBalance(ctx, jwt, reqUpdateBalance)
+	currentStatus := trx.TransactionStatus
+	trx.TransactionStatus = req.TransactionStatus
+	trx.AddtionalInfo = string(byteAdditionalInfo)
+
+	// the status is only written while it is still the one the transition
+	// was checked from, a concurrent change such as expiry wins and the
+	// wallet calls of this one are compensated
+	updateStatus := func(ctx context.Context) error {
+		if req.TransactionStatus == constants.TransactionStatusReversed {
+			err := s.checkNotRefunded(ctx, req.Reference)
+			if err != nil {
+				return err
+			}
 		}
-	case constants.TransactionTypePurchase:
-		switch req.TransactionStatus {
-		case constants.TransactionStatusSuccess:
-			_, errUpdateBalance = s.External.DebitBalance(ctx, jwt, reqUpdateBalance)
-		case constants.TransactionStatusReversed:
-			_, errUpdateBalance = s.External.CreditBalance(ctx, jwt, reqUpdateBalance)
+		if review != nil {
+			err := s.decideFraudReview(ctx, trx, review)
+			if err != nil {
+				return err
+			}
+		}
+		updated, err := s.TransactionRepo.UpdateStatusTransactionFrom(ctx, req.Reference, currentStatus, req.TransactionStatus, string(byteAdditionalInfo), tokenData.Username)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errors.Wrapf(constants.ErrTransactionStatusChanged, "transaction %s is no longer %s", req.Reference, currentStatus)
+		}
+		err = s.recordStatusChange(ctx, tokenData, trx, currentStatus, req.AddtionalInfo)
+		if err != nil {
+			return err
+		}
+		err = s.sendNotification(ctx, tokenData, trx, transition.NotificationTemplate)
+		if err != nil {
+			return err
+		}
+		return s.sendNotification(ctx, tokenData, counterpartyView(trx), transition.CounterpartyNotificationTemplate)
+	}
+
+	ops, tokens := statusChangeOperations(tokenData, trx, transition, reqUpdateBalance)
+	ops, tokens, err = s.skipUnchargedFeeReturn(ctx, trx, transition, ops, tokens)
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		err = s.TransactionRepo.WithTransaction(ctx, updateStatus)
+		if err != nil {
+			return errors.Wrap(err, "failed to update status transaction")
 		}
+		return nil
 	}
 
-	if errUpdateBalance != nil {
-		return errors.Wrap(errUpdateBalance, "failed to update balance")
+	payload, err := json.Marshal(updateStatusPayload{
+		UpdateStatusTransaction: models.UpdateStatusTransaction{
+			Reference:         req.Reference,
+			TransactionStatus: req.TransactionStatus,
+			AddtionalInfo:     string(byteAdditionalInfo),
+		},
+		FromStatus:          currentStatus,
+		AdditionalInfoDelta: req.AddtionalInfo,
+		FraudReview:         review,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal balance operation payload")
+	}
+	for _, op := range ops {
+		op.Payload = string(payload)
 	}
 
-	// update additional info
+	if len(ops) == 1 {
+		err = s.runBalanceOperation(ctx, tokens[0], ops[0], updateStatus)
+	} else {
+		err = s.runBalanceOperations(ctx, tokens, ops, updateStatus)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update status transaction")
+	}
+
+	return nil
+}
+
+// updateStatusPayload is the payload of the balance operations of a status
+// change. AddtionalInfo holds the merged additional_info and
+// AdditionalInfoDelta what the change added, for the status history.
+// FraudReview is the review decided by the change, if any. FromStatus and
+// AdditionalInfoDelta are empty in payloads journaled before they were added.
+type updateStatusPayload struct {
+	models.UpdateStatusTransaction
+	FromStatus          string               `json:"from_status,omitempty"`
+	AdditionalInfoDelta string               `json:"additional_info_delta,omitempty"`
+	FraudReview         *fraudReviewDecision `json:"fraud_review,omitempty"`
+}
+
+// statusChangeOperations returns the wallet calls transition makes for trx
+// with the token of each: the owner's, the counterparty's and then the fee.
+// Ops made together share an action so recovery checks them as one change.
+func statusChangeOperations(tokenData models.TokenData, trx models.Transaction, transition models.StateTransition, reqUpdateBalance external.UpdateBalance) ([]*models.BalanceOperation, []string) {
 	var (
-		newAdditionalInfo     = map[string]interface{}{}
-		currentAdditionalInfo = map[string]interface{}{}
+		ops    []*models.BalanceOperation
+		tokens []string
+		action = constants.BalanceOperationActionUpdateStatus
 	)
 
-	if trx.AddtionalInfo != "" {
-		err = json.Unmarshal([]byte(trx.AddtionalInfo), &currentAdditionalInfo)
-		if err != nil {
-			return errors.Wrap(err, "failed to unmarshal current additional info")
+	if transition.WalletOperation != "" {
+		ops = append(ops, &models.BalanceOperation{
+			UserID:               trx.UserID,
+			TransactionReference: trx.Reference,
+			WalletReference:      reqUpdateBalance.Reference,
+			Operation:            transition.WalletOperation,
+			Amount:               reqUpdateBalance.Amount,
+			Currency:             reqUpdateBalance.Currency,
+		})
+		tokens = append(tokens, walletToken(tokenData, trx.UserID))
+	}
+
+	if transition.CounterpartyWalletOperation != "" {
+		action = constants.BalanceOperationActionTransfer
+		ops = append(ops, &models.BalanceOperation{
+			UserID:               trx.RecipientUserID,
+			TransactionReference: trx.Reference,
+			WalletReference:      transition.CounterpartyWalletReferencePrefix + trx.Reference,
+			Operation:            transition.CounterpartyWalletOperation,
+			Amount:               reqUpdateBalance.Amount,
+			Currency:             reqUpdateBalance.Currency,
+		})
+		tokens = append(tokens, walletToken(tokenData, trx.RecipientUserID))
+	}
+
+	if transition.FeeWalletOperation != "" && trx.FeeAmount > 0 {
+		if len(ops) > 0 && action == constants.BalanceOperationActionUpdateStatus {
+			action = constants.BalanceOperationActionFee
 		}
+		ops = append(ops, &models.BalanceOperation{
+			UserID:               trx.UserID,
+			TransactionReference: trx.Reference,
+			WalletReference:      transition.FeeWalletReferencePrefix + trx.Reference,
+			Operation:            transition.FeeWalletOperation,
+			Amount:               trx.FeeAmount,
+			Currency:             trx.Currency,
+		})
+		tokens = append(tokens, walletToken(tokenData, trx.UserID))
 	}
 
-	if req.AddtionalInfo != "" {
-		err = json.Unmarshal([]byte(req.AddtionalInfo), &newAdditionalInfo)
+	for _, op := range ops {
+		op.Action = action
+	}
+
+	return ops, tokens
+}
+
+// skipUnchargedFeeReturn leaves out the fee credit of transition when no fee
+// debit of trx was completed, like for a withdrawal created before its fee
+// was held with the amount.
+func (s *TransactionService) skipUnchargedFeeReturn(ctx context.Context, trx models.Transaction, transition models.StateTransition, ops []*models.BalanceOperation, tokens []string) ([]*models.BalanceOperation, []string, error) {
+	if transition.FeeWalletOperation != constants.BalanceOperationCredit || trx.FeeAmount == 0 {
+		return ops, tokens, nil
+	}
+
+	for _, charge := range s.StateMachines[trx.TransactionType] {
+		if charge.FeeWalletOperation != constants.BalanceOperationDebit {
+			continue
+		}
+		charged, err := s.BalanceOperationRepo.GetBalanceOperationsByWalletReference(ctx, charge.FeeWalletReferencePrefix+trx.Reference)
 		if err != nil {
-			return errors.Wrap(err, "failed to unmarshal new additional info")
+			return nil, nil, errors.Wrap(err, "failed to get fee balance operations")
+		}
+		for i := range charged {
+			if charged[i].Status == constants.BalanceOperationStatusCompleted {
+				return ops, tokens, nil
+			}
 		}
 	}
 
-	for key, val := range newAdditionalInfo {
-		currentAdditionalInfo[key] = val
+	helpers.Logger.Warnf("fee of %s was not charged, it is not returned", trx.Reference)
+	feeReference := transition.FeeWalletReferencePrefix + trx.Reference
+	for i, op := range ops {
+		if op.WalletReference == feeReference {
+			return append(ops[:i:i], ops[i+1:]...), append(tokens[:i:i], tokens[i+1:]...), nil
+		}
 	}
+	return ops, tokens, nil
+}
 
-	byteAdditionalInfo, err := json.Marshal(currentAdditionalInfo)
+func (s *TransactionService) GetTransaction(ctx context.Context, filter models.TransactionFilter) (models.TransactionList, error) {
+	var (
+		resp models.TransactionList
+	)
+
+	err := prepareTransactionFilter(&filter)
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal current additional info")
+		return resp, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = constants.DefaultTransactionPageSize
 	}
 
-	// update status transaction
-	err = s.TransactionRepo.UpdateStatusTransaction(ctx, req.Reference, req.TransactionStatus, string(byteAdditionalInfo))
+	// fetch one more row to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	transactions, err := s.TransactionRepo.GetTransaction(ctx, filter)
 	if err != nil {
-		return errors.Wrap(err, "failed to update status transaction")
+		return resp, errors.Wrap(err, "failed to get transaction")
 	}
 
-	trx.TransactionStatus = req.TransactionStatus
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		resp.NextCursor = encodeTransactionCursor(transactionCursor{
+			ID:   transactions[limit-1].ID,
+			Sort: filter.Sort,
+		})
+	}
+
+	for i := range transactions {
+		transactions[i] = transactionView(transactions[i], filter.UserID)
+	}
+
+	resp.Transactions = transactions
+
+	return resp, nil
+}
 
-	s.sendNotification(ctx, tokenData, trx)
+// ExportTransactions calls fn with every transaction matching filter, as the
+// list would return them page after page. The limit of the filter is not
+// used, an export of more than EXPORT_MAX_ROWS rows is refused before any
+// row is read and one running longer than EXPORT_TIMEOUT is cut off.
+func (s *TransactionService) ExportTransactions(ctx context.Context, filter models.TransactionFilter, fn func(models.Transaction) error) error {
+	err := prepareTransactionFilter(&filter)
+	if err != nil {
+		return err
+	}
+
+	maxRows, err := strconv.Atoi(helpers.GetEnv("EXPORT_MAX_ROWS", "100000"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse export max rows")
+	}
+	timeout, err := time.ParseDuration(helpers.GetEnv("EXPORT_TIMEOUT", "5m"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse export timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	count, err := s.TransactionRepo.CountTransactions(ctx, filter)
+	if err != nil {
+		return errors.Wrap(err, "failed to count transactions")
+	}
+	if count > int64(maxRows) {
+		return constants.ErrExportTooLarge
+	}
+
+	// rows created since the count do not grow the export past the cap
+	filter.Limit = maxRows
+	err = s.TransactionRepo.ExportTransactions(ctx, filter, func(trx models.Transaction) error {
+		return fn(transactionView(trx, filter.UserID))
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to export transactions")
+	}
+	return nil
+}
+
+// prepareTransactionFilter checks filter, defaults its sort order and
+// decodes its cursor.
+func prepareTransactionFilter(filter *models.TransactionFilter) error {
+	if filter.TransactionType != "" && !constants.MapTransactionType[filter.TransactionType] {
+		return constants.ErrInvalidTransactionFilter
+	}
+	if filter.TransactionStatus != "" && !constants.MapTransactionStatus[filter.TransactionStatus] {
+		return constants.ErrInvalidTransactionFilter
+	}
+	if _, ok := models.CurrencyMinorUnits[filter.Currency]; filter.Currency != "" && !ok {
+		return constants.ErrInvalidTransactionFilter
+	}
+	if filter.MaxAmount > 0 && filter.MinAmount > filter.MaxAmount {
+		return constants.ErrInvalidTransactionFilter
+	}
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() && filter.StartDate.After(filter.EndDate) {
+		return constants.ErrInvalidTransactionFilter
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = "desc"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeTransactionCursor(filter.Cursor)
+		if err != nil || cursor.Sort != filter.Sort {
+			return constants.ErrInvalidTransactionFilter
+		}
+		filter.CursorID = cursor.ID
+	}
 
 	return nil
 }
 
-func (s *TransactionService) GetTransaction(ctx context.Context, userID int) ([]models.Transaction, error) {
-	return s.TransactionRepo.GetTransaction(ctx, userID)
+// transactionCursor is the position in the transaction list, encoded as
+// base64 JSON so clients treat it as an opaque string.
+type transactionCursor struct {
+	ID   int    `json:"id"`
+	Sort string `json:"sort"`
 }
 
-func (s *TransactionService) GetTransactionDetail(ctx context.Context, reference string) (models.Transaction, error) {
-	return s.TransactionRepo.GetTransactionByReference(ctx, reference, true)
+func encodeTransactionCursor(cursor transactionCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
 }
 
-func (s *TransactionService) RefundTransaction(ctx context.Context, tokenData *models.TokenData, req *models.RefundTransaction) (models.CreateTransactionResponse, error) {
+func decodeTransactionCursor(s string) (transactionCursor, error) {
 	var (
-		resp models.CreateTransactionResponse
-		now  = time.Now()
+		cursor transactionCursor
 	)
 
-	trx, err := s.TransactionRepo.GetTransactionByReference(ctx, req.Reference, false)
+	b, err := base64.RawURLEncoding.DecodeString(s)
 	if err != nil {
-		return resp, errors.Wrap(err, "failed to get transaction")
+		return cursor, err
 	}
 
-	if trx.TransactionStatus != constants.TransactionStatusSuccess && trx.TransactionType != constants.TransactionTypePurchase {
-		return resp, errors.New("current transaction is not success or transaction type is not purchase")
+	err = json.Unmarshal(b, &cursor)
+	return cursor, err
+}
+
+func (s *TransactionService) GetTransactionDetail(ctx context.Context, tokenData models.TokenData, reference string) (models.Transaction, error) {
+	trx, err := s.TransactionRepo.GetTransactionByReference(ctx, reference, true)
+	if err != nil {
+		return trx, err
 	}
 
-	refundReference := "REFUND-" + req.Reference
-	reqCreditBalance := external.UpdateBalance{
-		Reference: refundReference,
-		Amount:    trx.Amount,
+	err = authorizeTransaction(tokenData, trx)
+	if err != nil {
+		return models.Transaction{}, err
 	}
 
-	_, err = s.External.CreditBalance(ctx, tokenData.Token, reqCreditBalance)
+	trx = transactionView(trx, int(tokenData.UserID))
+
+	if trx.TransactionType == constants.TransactionTypePurchase {
+		refunded, err := s.TransactionRepo.GetRefundedAmount(ctx, trx.Reference)
+		if err != nil {
+			return trx, errors.Wrap(err, "failed to get refunded amount")
+		}
+		remaining := trx.Amount - refunded
+		trx.RefundedAmount = &refunded
+		trx.RemainingAmount = &remaining
+	}
+
+	return trx, nil
+}
+
+// refundedAmount is the amount refunded from the purchase reference,
+// counting the refunds whose wallet calls are still in flight, and the number
+// of refunds ever journaled for it.
+func (s *TransactionService) refundedAmount(ctx context.Context, reference string) (models.Money, int, error) {
+	refunded, err := s.TransactionRepo.GetRefundedAmount(ctx, reference)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to get refunded amount")
+	}
+
+	refundOps, err := s.BalanceOperationRepo.GetBalanceOperationsByTransactionReference(ctx, reference, constants.BalanceOperationActionRefund)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to get refund balance operations")
+	}
+
+	// the ops of a refund, its amount and its fee, share the refund
+	// transaction as payload
+	refunds := map[string]bool{}
+	for i := range refundOps {
+		if refunds[refundOps[i].Payload] {
+			continue
+		}
+		refunds[refundOps[i].Payload] = true
+
+		if refundOps[i].Status == constants.BalanceOperationStatusPending || refundOps[i].Status == constants.BalanceOperationStatusApplied {
+			var inFlight models.Transaction
+			err = json.Unmarshal([]byte(refundOps[i].Payload), &inFlight)
+			if err != nil {
+				return 0, 0, errors.Wrap(err, "failed to unmarshal balance operation payload")
+			}
+			refunded += inFlight.Amount
+		}
+	}
+
+	return refunded, len(refunds), nil
+}
+
+// checkNotRefunded refuses to reverse the purchase reference once a refund of
+// it is made or in flight, the reversal returns the full amount and would pay
+// the refund twice. In a database transaction the purchase stays locked until
+// it commits, as RefundTransaction locks it to reserve a refund.
+func (s *TransactionService) checkNotRefunded(ctx context.Context, reference string) error {
+	_, err := s.TransactionRepo.GetTransactionByReferenceForUpdate(ctx, reference)
+	if err != nil {
+		return errors.Wrap(err, "failed to get transaction")
+	}
+
+	refunded, _, err := s.refundedAmount(ctx, reference)
 	if err != nil {
-		return resp, errors.Wrap(err, "failed to credit balance")
+		return err
 	}
+	if refunded > 0 {
+		return errors.New("transaction is already refunded")
+	}
+	return nil
+}
+
+func (s *TransactionService) RefundTransaction(ctx context.Context, tokenData *models.TokenData, req *models.RefundTransaction) (models.CreateTransactionResponse, error) {
+	var (
+		resp        models.CreateTransactionResponse
+		now         = time.Now()
+		transaction models.Transaction
+		ops         []*models.BalanceOperation
+		tokens      []string
+	)
+
+	// the original row stays locked while the refund is reserved in the
+	// balance operation journal, so concurrent refunds cannot exceed it
+	transition, err := s.StateMachines.Transition(constants.TransactionTypeRefund, "", constants.TransactionStatusSuccess)
+	if err != nil {
+		return resp, err
+	}
+
+	err = s.TransactionRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		trx, err := s.TransactionRepo.GetTransactionByReferenceForUpdate(ctx, req.Reference)
+		if err != nil {
+			return errors.Wrap(err, "failed to get transaction")
+		}
+
+		err = authorizeTransaction(*tokenData, trx)
+		if err != nil {
+			return err
+		}
 
-	transaction := models.Transaction{
-		UserID:            int(tokenData.UserID),
-		Amount:            trx.Amount,
-		TransactionType:   constants.TransactionTypeRefund,
-		TransactionStatus: constants.TransactionStatusSuccess,
-		Reference:         refundReference,
-		Description:       req.Description,
-		AddtionalInfo:     req.AddtionalInfo,
-		CreatedAt:         now,
-		CreatedBy:         tokenData.FullName,
-		UpdatedAt:         now,
-		UpdatedBy:         tokenData.FullName,
+		if trx.TransactionStatus != constants.TransactionStatusSuccess || trx.TransactionType != constants.TransactionTypePurchase {
+			return errors.New("current transaction is not success or transaction type is not purchase")
+		}
+
+		refunded, refunds, err := s.refundedAmount(ctx, trx.Reference)
+		if err != nil {
+			return err
+		}
+
+		remaining := trx.Amount - refunded
+		amount := req.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+
+		if amount <= 0 || amount > remaining {
+			return constants.ErrRefundAmountExceeded
+		}
+
+		// refunds are always made in the currency of the purchase
+		err = models.ValidateCurrencyAmount(trx.Currency, amount)
+		if err != nil {
+			return constants.ErrRefundAmountPrecision
+		}
+
+		transaction = models.Transaction{
+			UserID:            trx.UserID,
+			Amount:            amount,
+			Currency:          trx.Currency,
+			TransactionType:   constants.TransactionTypeRefund,
+			TransactionStatus: constants.TransactionStatusSuccess,
+			Reference:         fmt.Sprintf("REFUND-%s-%d", trx.Reference, refunds+1),
+			ParentReference:   trx.Reference,
+			Description:       req.Description,
+			AddtionalInfo:     req.AddtionalInfo,
+			CreatedAt:         now,
+			CreatedBy:         tokenData.FullName,
+			UpdatedAt:         now,
+			UpdatedBy:         tokenData.FullName,
+			UserEmail:         trx.UserEmail,
+			UserFullName:      trx.UserFullName,
+			FeeAmount:         returnedFee(trx, refunded+amount) - returnedFee(trx, refunded),
+		}
+
+		payload, err := json.Marshal(transaction)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal balance operation payload")
+		}
+
+		ops, tokens = statusChangeOperations(*tokenData, transaction, transition, external.UpdateBalance{
+			Reference: transition.WalletReferencePrefix + transaction.Reference,
+			Amount:    transaction.Amount,
+			Currency:  transaction.Currency,
+		})
+		for _, op := range ops {
+			op.TransactionReference = trx.Reference
+			op.Action = constants.BalanceOperationActionRefund
+			op.Payload = string(payload)
+		}
+
+		return s.journalBalanceOperations(ctx, ops)
+	})
+	if err != nil {
+		return resp, err
 	}
 
-	err = s.TransactionRepo.CreateTransaction(ctx, &transaction)
+	err = s.applyBalanceOperations(ctx, tokens, ops, func(ctx context.Context) error {
+		err := s.TransactionRepo.CreateTransaction(ctx, &transaction)
+		if err != nil {
+			return err
+		}
+		err = s.recordStatusChange(ctx, *tokenData, transaction, "", transaction.AddtionalInfo)
+		if err != nil {
+			return err
+		}
+		return s.sendNotification(ctx, *tokenData, transaction, transition.NotificationTemplate)
+	})
+	if errors.Is(err, constants.ErrBalanceOperationPending) {
+		resp.Reference = transaction.Reference
+		resp.TransactionStatus = transaction.TransactionStatus
+		return resp, err
+	}
 	if err != nil {
 		return resp, errors.Wrap(err, "failed to insert new transaction refund")
 	}
 
-	resp.Reference = refundReference
+	resp.Reference = transaction.Reference
 	resp.TransactionStatus = transaction.TransactionStatus
-
-	s.sendNotification(ctx, *tokenData, transaction)
+	resp.FeeAmount = transaction.FeeAmount
 
 	return resp, nil
 
 }
 
-func (s *TransactionService) sendNotification(ctx context.Context, tokenData models.TokenData, trx models.Transaction) {
-	if trx.TransactionType == constants.TransactionTypePurchase && trx.TransactionStatus == constants.TransactionStatusSuccess {
-		err := s.External.SendNotification(ctx, tokenData.Email, "purchase_success", map[string]string{
-			"full_name":   tokenData.FullName,
-			"amount":      fmt.Sprintf("%.0f", trx.Amount),
+// recordStatusChange records that tokenData moved trx from fromStatus to its
+// current status, queues the webhooks interested in it and publishes its
+// events. additionalInfo is the part of additional_info added by the change.
+func (s *TransactionService) recordStatusChange(ctx context.Context, tokenData models.TokenData, trx models.Transaction, fromStatus, additionalInfo string) error {
+	history := &models.TransactionStatusHistory{
+		TransactionReference: trx.Reference,
+		FromStatus:           fromStatus,
+		ToStatus:             trx.TransactionStatus,
+		ActorUserID:          int(tokenData.UserID),
+		Actor:                tokenData.Username,
+		AdditionalInfo:       additionalInfo,
+	}
+	err := s.TransactionStatusHistoryRepo.CreateTransactionStatusHistory(ctx, history)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert transaction status history")
+	}
+
+	err = enqueueWebhookDeliveries(ctx, s.WebhookRepo, trx, fromStatus, history.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.publishStatusChange(ctx, tokenData, trx, fromStatus, history.ID)
+}
+
+func (s *TransactionService) GetTransactionStatusHistory(ctx context.Context, tokenData models.TokenData, reference string) ([]models.TransactionStatusHistory, error) {
+	trx, err := s.TransactionRepo.GetTransactionByReference(ctx, reference, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeTransaction(tokenData, trx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.TransactionStatusHistoryRepo.GetTransactionStatusHistory(ctx, reference)
+}
+
+// mergeAdditionalInfo adds the keys of the JSON object update to the JSON
+// object current, overwriting the ones they share.
+func mergeAdditionalInfo(current, update string) ([]byte, error) {
+	var (
+		newAdditionalInfo     = map[string]interface{}{}
+		currentAdditionalInfo = map[string]interface{}{}
+	)
+
+	if current != "" {
+		err := json.Unmarshal([]byte(current), &currentAdditionalInfo)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal current additional info")
+		}
+	}
+
+	if update != "" {
+		err := json.Unmarshal([]byte(update), &newAdditionalInfo)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal new additional info")
+		}
+	}
+
+	for key, val := range newAdditionalInfo {
+		currentAdditionalInfo[key] = val
+	}
+
+	byteAdditionalInfo, err := json.Marshal(currentAdditionalInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal current additional info")
+	}
+
+	return byteAdditionalInfo, nil
+}
+
+// sendNotification writes the templateName notification for trx to the
+// outbox. It must be called with the context of the database transaction
+// that changes trx so both are committed together. Templates not known here
+// get every placeholder.
+func (s *TransactionService) sendNotification(ctx context.Context, tokenData models.TokenData, trx models.Transaction, templateName string) error {
+	var (
+		placeholder map[string]string
+		recipient   = notificationRecipient(tokenData, trx)
+	)
+
+	if templateName == "" {
+		return nil
+	}
+
+	switch templateName {
+	case "purchase_success":
+		placeholder = map[string]string{
+			"full_name":   recipient.FullName,
+			"amount":      trx.Amount.Format(trx.Currency),
+			"currency":    trx.Currency,
 			"reference":   trx.Reference,
 			"description": trx.Description,
 			"date":        trx.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
-		if err != nil {
-			helpers.Logger.Warn("Failed to send notification: ", err)
 		}
-	} else if trx.TransactionType == constants.TransactionTypePurchase && trx.TransactionStatus == constants.TransactionStatusFailed {
-		err := s.External.SendNotification(ctx, tokenData.Email, "purchase_failed", map[string]string{
-			"full_name": tokenData.FullName,
-			"amount":    fmt.Sprintf("%.0f", trx.Amount),
+	case "purchase_failed":
+		placeholder = map[string]string{
+			"full_name": recipient.FullName,
+			"amount":    trx.Amount.Format(trx.Currency),
+			"currency":  trx.Currency,
 			"status":    "Purchase Failed",
 			"reason":    trx.AddtionalInfo,
 			"date":      trx.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
-		if err != nil {
-			helpers.Logger.Warn("Failed to send notification: ", err)
 		}
-	} else if trx.TransactionType == constants.TransactionTypeTopup && trx.TransactionStatus == constants.TransactionStatusSuccess {
-		err := s.External.SendNotification(ctx, tokenData.Email, "topup_success", map[string]string{
-			"full_name": tokenData.FullName,
-			"amount":    fmt.Sprintf("%.0f", trx.Amount),
+	case "topup_success":
+		placeholder = map[string]string{
+			"full_name": recipient.FullName,
+			"amount":    trx.Amount.Format(trx.Currency),
+			"currency":  trx.Currency,
 			"reference": trx.Reference,
 			"date":      trx.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
-		if err != nil {
-			helpers.Logger.Warn("Failed to send notification: ", err)
 		}
-	} else if trx.TransactionType == constants.TransactionTypeTopup && trx.TransactionStatus == constants.TransactionStatusFailed {
-		err := s.External.SendNotification(ctx, tokenData.Email, "topup_failed", map[string]string{
-			"full_name": tokenData.FullName,
-			"amount":    fmt.Sprintf("%.0f", trx.Amount),
+	case "topup_failed":
+		placeholder = map[string]string{
+			"full_name": recipient.FullName,
+			"amount":    trx.Amount.Format(trx.Currency),
+			"currency":  trx.Currency,
 			"status":    "TopUp Failed",
 			"reason":    trx.AddtionalInfo,
 			"date":      trx.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
-		if err != nil {
-			helpers.Logger.Warn("Failed to send notification: ", err)
 		}
-	} else if trx.TransactionType == constants.TransactionTypeRefund && trx.TransactionStatus == constants.TransactionStatusSuccess {
-		err := s.External.SendNotification(ctx, tokenData.Email, "refund", map[string]string{
-			"full_name":   tokenData.FullName,
-			"amount":      fmt.Sprintf("%.0f", trx.Amount),
+	case "refund":
+		placeholder = map[string]string{
+			"full_name":   recipient.FullName,
+			"amount":      trx.Amount.Format(trx.Currency),
+			"currency":    trx.Currency,
 			"reference":   trx.Reference,
 			"description": trx.Description,
 			"date":        trx.UpdatedAt.Format("2006-01-02 15:04:05"),
-		})
-		if err != nil {
-			helpers.Logger.Warn("Failed to send notification: ", err)
 		}
-	} else if trx.TransactionType == constants.TransactionTypePurchase && trx.TransactionStatus == constants.TransactionStatusReversed {
-		err := s.External.SendNotification(ctx, tokenData.Email, "purchase_reversed", map[string]string{
-			"full_name": tokenData.FullName,
-			"amount":    fmt.Sprintf("%.0f", trx.Amount),
+	case "purchase_reversed":
+		placeholder = map[string]string{
+			"full_name": recipient.FullName,
+			"amount":    trx.Amount.Format(trx.Currency),
+			"currency":  trx.Currency,
 			"reference": trx.Reference,
 			"reason":    trx.AddtionalInfo,
 			"date":      trx.UpdatedAt.Format("2006-01-02 15:04:05"),
-		})
-		if err != nil {
-			helpers.Logger.Warn("Failed to send notification: ", err)
 		}
+	case "transfer_success", "transfer_received":
+		placeholder = map[string]string{
+			"full_name":         recipient.FullName,
+			"counterparty_name": trx.RecipientFullName,
+			"amount":            trx.Amount.Format(trx.Currency),
+			"currency":          trx.Currency,
+			"reference":         trx.Reference,
+			"description":       trx.Description,
+			"date":              trx.UpdatedAt.Format("2006-01-02 15:04:05"),
+		}
+	case "transfer_failed":
+		placeholder = map[string]string{
+			"full_name":         recipient.FullName,
+			"counterparty_name": trx.RecipientFullName,
+			"amount":            trx.Amount.Format(trx.Currency),
+			"currency":          trx.Currency,
+			"status":            "Transfer Failed",
+			"reason":            trx.AddtionalInfo,
+			"date":              trx.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+	case "withdrawal_pending", "withdrawal_success", "withdrawal_failed":
+		placeholder = map[string]string{
+			"full_name": recipient.FullName,
+			"amount":    trx.Amount.Format(trx.Currency),
+			"currency":  trx.Currency,
+			"reference": trx.Reference,
+			"status":    trx.TransactionStatus,
+			"reason":    trx.AddtionalInfo,
+			"date":      trx.UpdatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if trx.BankAccount != nil {
+			placeholder["bank_code"] = trx.BankAccount.BankCode
+			placeholder["account_number"] = maskAccountNumber(trx.BankAccount.AccountNumber)
+			placeholder["account_name"] = trx.BankAccount.AccountName
+		}
+	default:
+		placeholder = map[string]string{
+			"full_name":        recipient.FullName,
+			"amount":           trx.Amount.Format(trx.Currency),
+			"currency":         trx.Currency,
+			"reference":        trx.Reference,
+			"description":      trx.Description,
+			"transaction_type": trx.TransactionType,
+			"status":           trx.TransactionStatus,
+			"reason":           trx.AddtionalInfo,
+			"date":             trx.UpdatedAt.Format("2006-01-02 15:04:05"),
+		}
+	}
+
+	// every template can show the fee, zero when none was charged or returned
+	placeholder["fee_amount"] = trx.FeeAmount.Format(trx.Currency)
+
+	if recipient.Email == "" {
+		helpers.Logger.Warnf("no recipient for %s notification of transaction %s", templateName, trx.Reference)
+		return nil
+	}
+
+	return s.NotificationOutboxRepo.CreateNotificationOutbox(ctx, &models.NotificationOutbox{
+		Recipient:     recipient.Email,
+		TemplateName:  templateName,
+		Placeholders:  placeholder,
+		Status:        constants.NotificationOutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	})
+}
+
+// notificationRecipient returns the owner of trx. Rows created before the
+// owner's contact was stored fall back to the caller when it is the owner.
+func notificationRecipient(tokenData models.TokenData, trx models.Transaction) models.TokenData {
+	if trx.UserEmail != "" {
+		return models.TokenData{
+			UserID:   int64(trx.UserID),
+			Email:    trx.UserEmail,
+			FullName: trx.UserFullName,
+		}
+	}
+
+	if int(tokenData.UserID) == trx.UserID {
+		return tokenData
+	}
+
+	return models.TokenData{}
+}
+
+// systemTokenData is the caller used for changes made by background jobs.
+func systemTokenData() models.TokenData {
+	return models.TokenData{
+		Username: constants.SystemUsername,
+		Roles:    []string{constants.RoleSystem},
+	}
+}
+
+// authorizeTransaction lets the owner of trx, the recipient of a transfer,
+// operators and the system act on it.
+func authorizeTransaction(tokenData models.TokenData, trx models.Transaction) error {
+	if int(tokenData.UserID) == trx.UserID || tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+		return nil
+	}
+	if trx.RecipientUserID != 0 && int(tokenData.UserID) == trx.RecipientUserID {
+		return nil
+	}
+	return constants.ErrTransactionForbidden
+}
+
+// walletToken returns the token used to update the wallet of userID. Calls on
+// behalf of another user go through the system token.
+func walletToken(tokenData models.TokenData, userID int) string {
+	if int(tokenData.UserID) == userID {
+		return tokenData.Token
 	}
+	return helpers.GetEnv("WALLET_SYSTEM_TOKEN", "")
 }
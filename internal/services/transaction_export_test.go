@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+)
+
+func TestExportTransactionsMaxRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     int
+		wantErr  error
+		wantRows int
+	}{
+		{"under the cap", 2, nil, 2},
+		{"at the cap", 3, nil, 3},
+		{"over the cap", 4, constants.ErrExportTooLarge, 0},
+	}
+
+	helpers.Env["EXPORT_MAX_ROWS"] = "3"
+	defer delete(helpers.Env, "EXPORT_MAX_ROWS")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			for i := 0; i < tt.rows; i++ {
+				_ = f.transactions.CreateTransaction(ctx, &models.Transaction{Reference: fmt.Sprintf("REF%d", i), UserID: 1})
+			}
+
+			exported := 0
+			err := f.ExportTransactions(ctx, models.TransactionFilter{UserID: 1}, func(models.Transaction) error {
+				exported++
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ExportTransactions() error = %v, want %v", err, tt.wantErr)
+			}
+			if exported != tt.wantRows {
+				t.Errorf("ExportTransactions() exported %d rows, want %d", exported, tt.wantRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DefaultTransactionLimits is used when TRANSACTION_LIMIT_FILE is not set, no
+// transaction type is limited. transaction_limit.example.json shows the
+// format of the file.
+var DefaultTransactionLimits = models.TransactionLimits{}
+
+// LoadTransactionLimits reads the default limits from the JSON file at path,
+// or returns DefaultTransactionLimits when path is empty.
+func LoadTransactionLimits(path string) (models.TransactionLimits, error) {
+	if path == "" {
+		return DefaultTransactionLimits, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read transaction limit file")
+	}
+
+	var limits models.TransactionLimits
+	err = json.Unmarshal(b, &limits)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse transaction limit file")
+	}
+
+	for transactionType, limit := range limits {
+		if !isLimitedTransactionType(transactionType) {
+			return nil, fmt.Errorf("invalid transaction limit file: transaction type %s cannot be limited", transactionType)
+		}
+		if err := limit.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid transaction limit file: %s", transactionType)
+		}
+	}
+
+	return limits, nil
+}
+
+// isLimitedTransactionType reports whether transactionType is created through
+// CreateTransaction, where the limits are checked. Refunds are not.
+func isLimitedTransactionType(transactionType string) bool {
+	return constants.MapTransactionType[transactionType] && transactionType != constants.TransactionTypeRefund
+}
+
+type TransactionLimitService struct {
+	TransactionLimitRepo interfaces.ITransactionLimitRepo
+	TransactionRepo      interfaces.ITransactionRepo
+	Limits               models.TransactionLimits
+
+	// Location is where the daily and monthly windows start at midnight.
+	Location *time.Location
+}
+
+// GetUserLimits returns the limit applied to userID for every transaction
+// type that can be limited.
+func (s *TransactionLimitService) GetUserLimits(ctx context.Context, userID int) ([]models.EffectiveTransactionLimit, error) {
+	overrides, err := s.TransactionLimitRepo.GetUserTransactionLimits(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user transaction limits")
+	}
+
+	byType := map[string]models.TransactionLimit{}
+	for _, override := range overrides {
+		byType[override.TransactionType] = override.TransactionLimit
+	}
+
+	var transactionTypes []string
+	for transactionType := range constants.MapTransactionType {
+		if isLimitedTransactionType(transactionType) {
+			transactionTypes = append(transactionTypes, transactionType)
+		}
+	}
+	sort.Strings(transactionTypes)
+
+	resp := make([]models.EffectiveTransactionLimit, 0, len(transactionTypes))
+	for _, transactionType := range transactionTypes {
+		limit, override := byType[transactionType]
+		if !override {
+			limit = s.Limits[transactionType]
+		}
+		resp = append(resp, models.EffectiveTransactionLimit{
+			TransactionType:  transactionType,
+			TransactionLimit: limit,
+			Override:         override,
+		})
+	}
+
+	return resp, nil
+}
+
+// SetUserLimit replaces the default limit of transactionType for userID.
+func (s *TransactionLimitService) SetUserLimit(ctx context.Context, tokenData models.TokenData, userID int, transactionType string, limit models.TransactionLimit) (models.UserTransactionLimit, error) {
+	userLimit := models.UserTransactionLimit{
+		UserID:           userID,
+		TransactionType:  transactionType,
+		TransactionLimit: limit,
+		UpdatedBy:        tokenData.Username,
+	}
+
+	err := s.TransactionLimitRepo.UpsertUserTransactionLimit(ctx, &userLimit)
+	if err != nil {
+		return models.UserTransactionLimit{}, errors.Wrap(err, "failed to upsert user transaction limit")
+	}
+
+	return userLimit, nil
+}
+
+// DeleteUserLimit puts userID back on the default limit of transactionType.
+func (s *TransactionLimitService) DeleteUserLimit(ctx context.Context, userID int, transactionType string) error {
+	deleted, err := s.TransactionLimitRepo.DeleteUserTransactionLimit(ctx, userID, transactionType)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete user transaction limit")
+	}
+	if !deleted {
+		return constants.ErrTransactionLimitNotFound
+	}
+	return nil
+}
+
+// CheckTransactionLimit returns a *models.LimitExceededError when creating
+// trx, or approving it once held, would go over the limits of its user. trx
+// itself is not counted in the usage. Inside a database transaction the
+// limit row of the user is locked first, so when trx is inserted in the same
+// transaction concurrent requests of the user cannot overshoot a limit.
+func (s *TransactionLimitService) CheckTransactionLimit(ctx context.Context, trx models.Transaction) error {
+	limit, err := s.userLimit(ctx, trx.UserID, trx.TransactionType)
+	if err != nil {
+		return err
+	}
+	if limit == (models.TransactionLimit{}) {
+		return nil
+	}
+
+	err = s.TransactionLimitRepo.LockUserTransactionLimits(ctx, trx.UserID)
+	if err != nil {
+		return errors.Wrap(err, "failed to lock user transaction limits")
+	}
+
+	// amounts in another currency cannot be added to the limited ones
+	if limit.LimitsAmount() && trx.Currency != limit.LimitCurrency() {
+		return limitExceeded(trx.TransactionType, "currency", limit.LimitCurrency())
+	}
+
+	if limit.MaxAmount > 0 && trx.Amount > limit.MaxAmount {
+		return limitExceeded(trx.TransactionType, "max_amount", limit.MaxAmount.Format(trx.Currency))
+	}
+
+	now := time.Now().In(s.Location)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	err = s.checkWindow(ctx, trx, startOfDay, limit.DailyAmount, limit.DailyCount, "daily_amount", "daily_count")
+	if err != nil {
+		return err
+	}
+
+	return s.checkWindow(ctx, trx, startOfMonth, limit.MonthlyAmount, limit.MonthlyCount, "monthly_amount", "monthly_count")
+}
+
+// userLimit returns the limit set for userID, or the default one.
+func (s *TransactionLimitService) userLimit(ctx context.Context, userID int, transactionType string) (models.TransactionLimit, error) {
+	userLimit, err := s.TransactionLimitRepo.GetUserTransactionLimit(ctx, userID, transactionType)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Limits[transactionType], nil
+	}
+	if err != nil {
+		return models.TransactionLimit{}, errors.Wrap(err, "failed to get user transaction limit")
+	}
+	return userLimit.TransactionLimit, nil
+}
+
+// checkWindow checks trx against the amount and count limits of the window
+// that started at since. Zero limits are not checked.
+func (s *TransactionLimitService) checkWindow(ctx context.Context, trx models.Transaction, since time.Time, maxAmount models.Money, maxCount int, amountLimit, countLimit string) error {
+	if maxAmount == 0 && maxCount == 0 {
+		return nil
+	}
+
+	usage, err := s.TransactionRepo.GetTransactionUsage(ctx, trx.UserID, trx.TransactionType, trx.Currency, since, trx.Reference)
+	if err != nil {
+		return errors.Wrap(err, "failed to get transaction usage")
+	}
+
+	if maxCount > 0 && usage.Count+1 > maxCount {
+		return limitExceeded(trx.TransactionType, countLimit, fmt.Sprint(maxCount))
+	}
+	if maxAmount > 0 && usage.Amount+trx.Amount > maxAmount {
+		return limitExceeded(trx.TransactionType, amountLimit, maxAmount.Format(trx.Currency))
+	}
+
+	return nil
+}
+
+func limitExceeded(transactionType, limit, max string) *models.LimitExceededError {
+	return &models.LimitExceededError{
+		Code:            constants.ErrCodeLimitExceeded,
+		TransactionType: transactionType,
+		Limit:           limit,
+		Max:             max,
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCheckTransactionLimit(t *testing.T) {
+	existing := func(status, currency string, amount models.Money) *models.Transaction {
+		return &models.Transaction{UserID: 1, TransactionType: constants.TransactionTypeTopup, TransactionStatus: status, Currency: currency, Amount: amount}
+	}
+
+	tests := []struct {
+		name      string
+		limit     models.TransactionLimit
+		existing  []*models.Transaction
+		reference string
+		currency  string
+		amount    models.Money
+		wantLimit string
+	}{
+		{
+			name:     "within daily amount",
+			limit:    models.TransactionLimit{DailyAmount: 10000},
+			existing: []*models.Transaction{existing(constants.TransactionStatusSuccess, "IDR", 5000)},
+			currency: "IDR",
+			amount:   5000,
+		},
+		{
+			name:      "held transactions are counted",
+			limit:     models.TransactionLimit{DailyAmount: 10000},
+			existing:  []*models.Transaction{existing(constants.TransactionStatusUnderReview, "IDR", 8000)},
+			currency:  "IDR",
+			amount:    5000,
+			wantLimit: "daily_amount",
+		},
+		{
+			name:      "the held transaction itself is not counted",
+			limit:     models.TransactionLimit{DailyAmount: 10000},
+			existing:  []*models.Transaction{existing(constants.TransactionStatusUnderReview, "IDR", 8000)},
+			reference: "EXISTING0",
+			currency:  "IDR",
+			amount:    8000,
+		},
+		{
+			name:     "failed transactions are not counted",
+			limit:    models.TransactionLimit{DailyAmount: 10000},
+			existing: []*models.Transaction{existing(constants.TransactionStatusFailed, "IDR", 8000)},
+			currency: "IDR",
+			amount:   5000,
+		},
+		{
+			name:      "amounts in another currency are refused",
+			limit:     models.TransactionLimit{DailyAmount: 10000},
+			currency:  "USD",
+			amount:    100,
+			wantLimit: "currency",
+		},
+		{
+			name:     "limit in its own currency",
+			limit:    models.TransactionLimit{Currency: "USD", MaxAmount: 10000},
+			currency: "USD",
+			amount:   100,
+		},
+		{
+			name:      "counts include every currency",
+			limit:     models.TransactionLimit{DailyCount: 1},
+			existing:  []*models.Transaction{existing(constants.TransactionStatusSuccess, "USD", 100)},
+			currency:  "IDR",
+			amount:    100,
+			wantLimit: "daily_count",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeTransactionRepo()
+			for i, trx := range tt.existing {
+				trx.Reference = fmt.Sprintf("EXISTING%d", i)
+				_ = repo.CreateTransaction(context.Background(), trx)
+			}
+			s := &TransactionLimitService{
+				TransactionLimitRepo: fakeTransactionLimitRepo{},
+				TransactionRepo:      repo,
+				Limits:               models.TransactionLimits{constants.TransactionTypeTopup: tt.limit},
+				Location:             time.UTC,
+			}
+
+			err := s.CheckTransactionLimit(context.Background(), models.Transaction{
+				Reference:       tt.reference,
+				UserID:          1,
+				TransactionType: constants.TransactionTypeTopup,
+				Currency:        tt.currency,
+				Amount:          tt.amount,
+			})
+
+			var limitExceeded *models.LimitExceededError
+			switch {
+			case tt.wantLimit == "" && err != nil:
+				t.Errorf("CheckTransactionLimit() error = %v", err)
+			case tt.wantLimit != "" && !errors.As(err, &limitExceeded):
+				t.Errorf("CheckTransactionLimit() error = %v, want %s exceeded", err, tt.wantLimit)
+			case tt.wantLimit != "" && limitExceeded.Limit != tt.wantLimit:
+				t.Errorf("CheckTransactionLimit() limit = %s, want %s", limitExceeded.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+// Approving a held transaction checks the limits again, they may have been
+// used up while it was held.
+func TestReviewTransactionLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		spent      models.Money
+		approve    bool
+		wantLimit  string
+		wantStatus string
+		wantReview string
+	}{
+		{"approved within the limit", 2000, true, "", constants.TransactionStatusPending, constants.FraudReviewStatusApproved},
+		{"approved over the limit", 8000, true, "daily_amount", constants.TransactionStatusUnderReview, constants.FraudReviewStatusPending},
+		{"rejected over the limit", 8000, false, "", constants.TransactionStatusFailed, constants.FraudReviewStatusRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			f.TransactionLimitService.(*TransactionLimitService).Limits = models.TransactionLimits{
+				constants.TransactionTypeTopup: {DailyAmount: 10000},
+			}
+			_ = f.transactions.CreateTransaction(ctx, &models.Transaction{Reference: "SPENT", UserID: 1, TransactionType: constants.TransactionTypeTopup, TransactionStatus: constants.TransactionStatusSuccess, Currency: "IDR", Amount: tt.spent})
+			_ = f.transactions.CreateTransaction(ctx, &models.Transaction{Reference: "REF0001", UserID: 1, TransactionType: constants.TransactionTypeTopup, TransactionStatus: constants.TransactionStatusUnderReview, Currency: "IDR", Amount: 5000})
+			_ = f.fraud.CreateFraudEvaluation(ctx, &models.FraudEvaluation{TransactionReference: "REF0001", ToStatus: constants.TransactionStatusPending, Decision: constants.FraudDecisionReview, ReviewStatus: constants.FraudReviewStatusPending})
+
+			_, err := f.ReviewTransaction(ctx, testOperator, "REF0001", tt.approve, "")
+
+			var limitExceeded *models.LimitExceededError
+			switch {
+			case tt.wantLimit == "" && err != nil:
+				t.Fatalf("ReviewTransaction() error = %v", err)
+			case tt.wantLimit != "" && !errors.As(err, &limitExceeded):
+				t.Fatalf("ReviewTransaction() error = %v, want %s exceeded", err, tt.wantLimit)
+			case tt.wantLimit != "" && limitExceeded.Limit != tt.wantLimit:
+				t.Errorf("ReviewTransaction() limit = %s, want %s", limitExceeded.Limit, tt.wantLimit)
+			}
+			assertTransactionStatus(t, f, "REF0001", tt.wantStatus)
+			if got := f.fraud.evaluations[0].ReviewStatus; got != tt.wantReview {
+				t.Errorf("review is %s, want %s", got, tt.wantReview)
+			}
+		})
+	}
+}
+
+// The limits are checked again with the lock held when the approval is
+// recorded, a transaction made during the wallet call is counted.
+func TestReviewTransactionLimitLocked(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	f.TransactionLimitService.(*TransactionLimitService).Limits = models.TransactionLimits{
+		constants.TransactionTypePurchase: {DailyAmount: 10000},
+	}
+	_ = f.transactions.CreateTransaction(ctx, &models.Transaction{Reference: "REF0001", UserID: 1, TransactionType: constants.TransactionTypePurchase, TransactionStatus: constants.TransactionStatusUnderReview, Currency: "IDR", Amount: 5000})
+	_ = f.fraud.CreateFraudEvaluation(ctx, &models.FraudEvaluation{TransactionReference: "REF0001", ToStatus: constants.TransactionStatusSuccess, Decision: constants.FraudDecisionReview, ReviewStatus: constants.FraudReviewStatusPending})
+	f.wallet.onCall = func(reference string) {
+		if reference == "REF0001" {
+			_ = f.transactions.CreateTransaction(ctx, &models.Transaction{Reference: "SPENT", UserID: 1, TransactionType: constants.TransactionTypePurchase, TransactionStatus: constants.TransactionStatusSuccess, Currency: "IDR", Amount: 8000})
+		}
+	}
+
+	_, err := f.ReviewTransaction(ctx, testOperator, "REF0001", true, "")
+
+	var limitExceeded *models.LimitExceededError
+	if !errors.As(err, &limitExceeded) || limitExceeded.Limit != "daily_amount" {
+		t.Fatalf("ReviewTransaction() error = %v, want daily_amount exceeded", err)
+	}
+	assertTransactionStatus(t, f, "REF0001", constants.TransactionStatusUnderReview)
+	assertEqual(t, "wallet entries", walletReferences(f.wallet), []string{"REF0001", "COMPENSATE-REF0001"})
+	if got := f.fraud.evaluations[0].ReviewStatus; got != constants.FraudReviewStatusPending {
+		t.Errorf("review is %s, want %s", got, constants.FraudReviewStatusPending)
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// resolveTransferRecipient checks the recipient of a TRANSFER and keeps its
+// contact so it can be notified. Other types cannot have a recipient.
+func (s *TransactionService) resolveTransferRecipient(ctx context.Context, req *models.Transaction) error {
+	if req.TransactionType != constants.TransactionTypeTransfer {
+		if req.RecipientUserID != 0 {
+			return constants.ErrInvalidTransferRecipient
+		}
+		return nil
+	}
+
+	if req.RecipientUserID <= 0 || req.RecipientUserID == req.UserID {
+		return constants.ErrInvalidTransferRecipient
+	}
+
+	recipient, err := s.External.GetUser(ctx, req.RecipientUserID)
+	if errors.Is(err, constants.ErrUserNotFound) {
+		return constants.ErrInvalidTransferRecipient
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get transfer recipient")
+	}
+
+	req.RecipientEmail = recipient.Email
+	req.RecipientFullName = recipient.FullName
+
+	return nil
+}
+
+// settleTransfer moves the money of a transfer that was just created and
+// returns its new status. A transfer whose money cannot be moved is failed
+// right away, so the sender does not wait for it to expire. One whose wallet
+// calls have an unknown outcome stays PENDING until recovery settles it.
+// Unless screen is set, as for a transfer whose review was just approved, the
+// fraud rules are not run again.
+func (s *TransactionService) settleTransfer(ctx context.Context, tokenData models.TokenData, trx models.Transaction, screen bool) (string, error) {
+	var (
+		held bool
+		err  error
+		req  = &models.UpdateStatusTransaction{
+			Reference:         trx.Reference,
+			TransactionStatus: constants.TransactionStatusSuccess,
+		}
+	)
+
+	if screen {
+		held, err = s.changeStatus(ctx, tokenData, trx, req)
+	} else {
+		err = s.updateStatusTransaction(ctx, tokenData, trx, req, nil)
+	}
+	if err == nil && held {
+		return constants.TransactionStatusUnderReview, nil
+	}
+	if err == nil {
+		return constants.TransactionStatusSuccess, nil
+	}
+	if errors.Is(err, constants.ErrBalanceOperationPending) {
+		return trx.TransactionStatus, err
+	}
+	helpers.Logger.Errorf("failed to settle transfer %s: %v", trx.Reference, err)
+
+	reason, err := json.Marshal(map[string]interface{}{
+		"failure_reason": "the wallets of the transfer could not be updated",
+	})
+	if err != nil {
+		return trx.TransactionStatus, errors.Wrap(err, "failed to marshal transfer failure reason")
+	}
+
+	err = s.updateStatusTransaction(ctx, tokenData, trx, &models.UpdateStatusTransaction{
+		Reference:         trx.Reference,
+		TransactionStatus: constants.TransactionStatusFailed,
+		AddtionalInfo:     string(reason),
+	}, nil)
+	if err != nil {
+		return trx.TransactionStatus, errors.Wrap(err, "failed to fail transfer")
+	}
+
+	return constants.TransactionStatusFailed, nil
+}
+
+// counterpartyView returns trx as seen by the recipient of a transfer, whose
+// counterparty is the sender.
+func counterpartyView(trx models.Transaction) models.Transaction {
+	trx.UserID, trx.RecipientUserID = trx.RecipientUserID, trx.UserID
+	trx.UserEmail, trx.RecipientEmail = trx.RecipientEmail, trx.UserEmail
+	trx.UserFullName, trx.RecipientFullName = trx.RecipientFullName, trx.UserFullName
+	// the fee is paid by the sender
+	trx.FeeAmount, trx.FeeRule = 0, ""
+	return trx
+}
+
+// transactionView is trx as userID sees it in the list, the export and the
+// detail. The recipient of a transfer sees it from their side, without the
+// sender's fee and additional_info.
+func transactionView(trx models.Transaction, userID int) models.Transaction {
+	direction := transactionDirection(trx, userID)
+	if direction == constants.TransactionDirectionIn {
+		trx = counterpartyView(trx)
+		trx.AddtionalInfo = ""
+	}
+	trx.Direction = direction
+	return trx
+}
+
+// transactionDirection tells whether userID sent or received trx. Only
+// transfers have a direction.
+func transactionDirection(trx models.Transaction, userID int) string {
+	if trx.TransactionType != constants.TransactionTypeTransfer {
+		return ""
+	}
+	if trx.RecipientUserID == userID {
+		return constants.TransactionDirectionIn
+	}
+	return constants.TransactionDirectionOut
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+)
+
+func newTestTransfer() *models.Transaction {
+	return &models.Transaction{
+		UserID:          int(testUser.UserID),
+		RecipientUserID: 2,
+		Amount:          10000,
+		Currency:        "IDR",
+		TransactionType: constants.TransactionTypeTransfer,
+		Description:     "transfer",
+		UserEmail:       testUser.Email,
+		UserFullName:    testUser.FullName,
+	}
+}
+
+func TestTransferWalletOutcome(t *testing.T) {
+	tests := []struct {
+		name             string
+		faults           map[string]walletFault
+		wantErr          error
+		wantStatus       string
+		wantOps          []string
+		wantEntries      []string
+		wantStatusAfter  string
+		wantOpsAfter     []string
+		wantEntriesAfter []string
+	}{
+		{
+			name:             "credit applied but timed out",
+			faults:           map[string]walletFault{"TRANSFER-IN-REF0001": walletTimeoutApplied},
+			wantErr:          constants.ErrBalanceOperationPending,
+			wantStatus:       constants.TransactionStatusPending,
+			wantOps:          []string{"REF0001 APPLIED", "TRANSFER-IN-REF0001 PENDING"},
+			wantEntries:      []string{"REF0001", "TRANSFER-IN-REF0001"},
+			wantStatusAfter:  constants.TransactionStatusSuccess,
+			wantOpsAfter:     []string{"REF0001 COMPLETED", "TRANSFER-IN-REF0001 COMPLETED"},
+			wantEntriesAfter: []string{"REF0001", "TRANSFER-IN-REF0001"},
+		},
+		{
+			name:             "credit lost in a timeout",
+			faults:           map[string]walletFault{"TRANSFER-IN-REF0001": walletTimeoutLost},
+			wantErr:          constants.ErrBalanceOperationPending,
+			wantStatus:       constants.TransactionStatusPending,
+			wantOps:          []string{"REF0001 APPLIED", "TRANSFER-IN-REF0001 PENDING"},
+			wantEntries:      []string{"REF0001"},
+			wantStatusAfter:  constants.TransactionStatusSuccess,
+			wantOpsAfter:     []string{"REF0001 COMPLETED", "TRANSFER-IN-REF0001 COMPLETED"},
+			wantEntriesAfter: []string{"REF0001", "TRANSFER-IN-REF0001"},
+		},
+		{
+			name:             "credit rejected",
+			faults:           map[string]walletFault{"TRANSFER-IN-REF0001": walletReject},
+			wantStatus:       constants.TransactionStatusFailed,
+			wantOps:          []string{"REF0001 COMPENSATED", "TRANSFER-IN-REF0001 FAILED"},
+			wantEntries:      []string{"REF0001", "COMPENSATE-REF0001"},
+			wantStatusAfter:  constants.TransactionStatusFailed,
+			wantOpsAfter:     []string{"REF0001 COMPENSATED", "TRANSFER-IN-REF0001 FAILED"},
+			wantEntriesAfter: []string{"REF0001", "COMPENSATE-REF0001"},
+		},
+		{
+			name: "compensation applied but timed out",
+			faults: map[string]walletFault{
+				"TRANSFER-IN-REF0001": walletReject,
+				"COMPENSATE-REF0001":  walletTimeoutApplied,
+			},
+			wantStatus:       constants.TransactionStatusFailed,
+			wantOps:          []string{"REF0001 APPLIED", "TRANSFER-IN-REF0001 FAILED"},
+			wantEntries:      []string{"REF0001", "COMPENSATE-REF0001"},
+			wantStatusAfter:  constants.TransactionStatusFailed,
+			wantOpsAfter:     []string{"REF0001 COMPENSATED", "TRANSFER-IN-REF0001 FAILED"},
+			wantEntriesAfter: []string{"REF0001", "COMPENSATE-REF0001"},
+		},
+		{
+			name:             "debit lost in a timeout",
+			faults:           map[string]walletFault{"REF0001": walletTimeoutLost},
+			wantErr:          constants.ErrBalanceOperationPending,
+			wantStatus:       constants.TransactionStatusPending,
+			wantOps:          []string{"REF0001 PENDING", "TRANSFER-IN-REF0001 PENDING"},
+			wantEntries:      nil,
+			wantStatusAfter:  constants.TransactionStatusSuccess,
+			wantOpsAfter:     []string{"REF0001 COMPLETED", "TRANSFER-IN-REF0001 COMPLETED"},
+			wantEntriesAfter: []string{"REF0001", "TRANSFER-IN-REF0001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			f.wallet.faults = tt.faults
+
+			resp, err := f.CreateTransaction(ctx, testUser, newTestTransfer())
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("CreateTransaction() error = %v, want %v", err, tt.wantErr)
+			}
+			if resp.Reference != "REF0001" || resp.TransactionStatus != tt.wantStatus {
+				t.Errorf("CreateTransaction() = %s %s, want REF0001 %s", resp.Reference, resp.TransactionStatus, tt.wantStatus)
+			}
+			assertTransactionStatus(t, f, "REF0001", tt.wantStatus)
+			assertEqual(t, "balance operations", f.balanceOperations.statuses(), tt.wantOps)
+			assertEqual(t, "wallet entries", walletReferences(f.wallet), tt.wantEntries)
+
+			f.balanceOperations.age(time.Hour)
+			err = f.RecoverBalanceOperations(ctx)
+			if err != nil {
+				t.Fatalf("RecoverBalanceOperations() error = %v", err)
+			}
+			assertTransactionStatus(t, f, "REF0001", tt.wantStatusAfter)
+			assertEqual(t, "balance operations after recovery", f.balanceOperations.statuses(), tt.wantOpsAfter)
+			assertEqual(t, "wallet entries after recovery", walletReferences(f.wallet), tt.wantEntriesAfter)
+		})
+	}
+}
+
+// Recovery leaves a transfer alone while one of its legs is recent.
+func TestRecoverBalanceOperationsGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	f.wallet.faults["TRANSFER-IN-REF0001"] = walletTimeoutLost
+
+	_, err := f.CreateTransaction(ctx, testUser, newTestTransfer())
+	if !errors.Is(err, constants.ErrBalanceOperationPending) {
+		t.Fatalf("CreateTransaction() error = %v, want %v", err, constants.ErrBalanceOperationPending)
+	}
+
+	f.balanceOperations.ops[0].UpdatedAt = f.balanceOperations.ops[0].UpdatedAt.Add(-time.Hour)
+	err = f.RecoverBalanceOperations(ctx)
+	if err != nil {
+		t.Fatalf("RecoverBalanceOperations() error = %v", err)
+	}
+	assertEqual(t, "balance operations", f.balanceOperations.statuses(), []string{"REF0001 APPLIED", "TRANSFER-IN-REF0001 PENDING"})
+	assertEqual(t, "wallet calls", f.wallet.calls, []string{"DEBIT REF0001", "CREDIT TRANSFER-IN-REF0001"})
+}
+
+// An approved transfer is settled without being held again by the rule that
+// held it.
+func TestReviewTransactionTransfer(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	f.FraudService.(*FraudService).rules.Store(&[]models.FraudRule{{
+		Name:      "large transfer",
+		Condition: constants.FraudConditionAmountOver,
+		Action:    constants.FraudRuleActionReview,
+		Amount:    5000,
+	}})
+
+	resp, err := f.CreateTransaction(ctx, testUser, newTestTransfer())
+	if err != nil {
+		t.Fatalf("CreateTransaction() error = %v", err)
+	}
+	if resp.TransactionStatus != constants.TransactionStatusUnderReview {
+		t.Fatalf("CreateTransaction() status = %s, want %s", resp.TransactionStatus, constants.TransactionStatusUnderReview)
+	}
+
+	resp, err = f.ReviewTransaction(ctx, testOperator, "REF0001", true, "")
+	if err != nil {
+		t.Fatalf("ReviewTransaction() error = %v", err)
+	}
+	if resp.TransactionStatus != constants.TransactionStatusSuccess {
+		t.Errorf("ReviewTransaction() status = %s, want %s", resp.TransactionStatus, constants.TransactionStatusSuccess)
+	}
+	assertTransactionStatus(t, f, "REF0001", constants.TransactionStatusSuccess)
+	assertEqual(t, "wallet entries", walletReferences(f.wallet), []string{"REF0001", "TRANSFER-IN-REF0001"})
+	if len(f.fraud.evaluations) != 1 {
+		t.Errorf("%d fraud evaluations, want only the one that held the transfer", len(f.fraud.evaluations))
+	}
+}
+
+// The recipient of a transfer sees it from their side, without the sender's
+// fee and additional_info, in the detail and in the export.
+func TestTransferRecipientView(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	_ = f.transactions.CreateTransaction(ctx, &models.Transaction{
+		Reference:         "REF0001",
+		UserID:            1,
+		UserEmail:         "user1@example.com",
+		RecipientUserID:   2,
+		RecipientEmail:    "user2@example.com",
+		Amount:            10000,
+		Currency:          "IDR",
+		FeeAmount:         500,
+		FeeRule:           "transfer",
+		TransactionType:   constants.TransactionTypeTransfer,
+		TransactionStatus: constants.TransactionStatusSuccess,
+		AddtionalInfo:     `{"note":"rent"}`,
+	})
+	recipient := models.TokenData{UserID: 2, Username: "user2", Roles: []string{constants.RoleUser}}
+
+	detail, err := f.GetTransactionDetail(ctx, recipient, "REF0001")
+	if err != nil {
+		t.Fatalf("GetTransactionDetail() error = %v", err)
+	}
+	var exported []models.Transaction
+	err = f.ExportTransactions(ctx, models.TransactionFilter{UserID: 2}, func(trx models.Transaction) error {
+		exported = append(exported, trx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportTransactions() error = %v", err)
+	}
+	if len(exported) != 1 {
+		t.Fatalf("ExportTransactions() exported %d rows, want 1", len(exported))
+	}
+
+	for name, trx := range map[string]models.Transaction{"detail": detail, "export": exported[0]} {
+		if trx.Direction != constants.TransactionDirectionIn || trx.UserID != 2 || trx.RecipientUserID != 1 || trx.UserEmail != "user2@example.com" {
+			t.Errorf("%s = %s from %d to %d (%s), want IN from 2 to 1 (user2@example.com)", name, trx.Direction, trx.UserID, trx.RecipientUserID, trx.UserEmail)
+		}
+		if trx.FeeAmount != 0 || trx.FeeRule != "" || trx.AddtionalInfo != "" {
+			t.Errorf("%s shows fee %v %q and additional_info %q, want none", name, trx.FeeAmount, trx.FeeRule, trx.AddtionalInfo)
+		}
+	}
+
+	detail, err = f.GetTransactionDetail(ctx, testUser, "REF0001")
+	if err != nil {
+		t.Fatalf("GetTransactionDetail() error = %v", err)
+	}
+	if detail.Direction != constants.TransactionDirectionOut || detail.FeeAmount != 500 || detail.AddtionalInfo == "" {
+		t.Errorf("sender detail = %s with fee %v and additional_info %q, want OUT with both", detail.Direction, detail.FeeAmount, detail.AddtionalInfo)
+	}
+}
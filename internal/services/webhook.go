@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external"
+	"ewallet-transaction/helpers"
+	"ewallet-transaction/internal/interfaces"
+	"ewallet-transaction/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type WebhookService struct {
+	WebhookRepo interfaces.IWebhookRepo
+	External    interfaces.IExternal
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, tokenData models.TokenData, req models.CreateWebhook) (models.CreateWebhookResponse, error) {
+	var (
+		resp models.CreateWebhookResponse
+	)
+
+	for _, transactionType := range req.TransactionTypes {
+		if !constants.MapTransactionType[transactionType] {
+			return resp, constants.ErrInvalidWebhookFilter
+		}
+	}
+	for _, transactionStatus := range req.TransactionStatuses {
+		if !constants.MapTransactionStatus[transactionStatus] {
+			return resp, constants.ErrInvalidWebhookFilter
+		}
+	}
+
+	err := s.External.ValidateWebhookURL(ctx, req.URL)
+	if errors.Is(err, external.ErrWebhookURLNotAllowed) {
+		return resp, errors.Wrap(constants.ErrInvalidWebhookURL, err.Error())
+	}
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to validate webhook url")
+	}
+
+	userID := int(tokenData.UserID)
+	if req.AllUsers {
+		if !tokenData.HasRole(constants.RoleOperator, constants.RoleSystem) {
+			return resp, constants.ErrWebhookForbidden
+		}
+		userID = 0
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to generate webhook secret")
+	}
+
+	webhook := models.Webhook{
+		UserID:              userID,
+		URL:                 req.URL,
+		Secret:              "whsec_" + hex.EncodeToString(secret),
+		TransactionTypes:    req.TransactionTypes,
+		TransactionStatuses: req.TransactionStatuses,
+		Active:              true,
+		CreatedBy:           tokenData.Username,
+	}
+
+	err = s.WebhookRepo.CreateWebhook(ctx, &webhook)
+	if err != nil {
+		return resp, errors.Wrap(err, "failed to insert webhook")
+	}
+
+	resp.Webhook = webhook
+	resp.Secret = webhook.Secret
+
+	return resp, nil
+}
+
+func (s *WebhookService) GetWebhooks(ctx context.Context, tokenData models.TokenData) ([]models.Webhook, error) {
+	return s.WebhookRepo.GetWebhooksByUserID(ctx, int(tokenData.UserID))
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, tokenData models.TokenData, id int) error {
+	webhook, err := s.getOwnWebhook(ctx, tokenData, id)
+	if err != nil {
+		return err
+	}
+
+	return s.WebhookRepo.DeactivateWebhook(ctx, webhook.ID)
+}
+
+func (s *WebhookService) GetWebhookDeliveries(ctx context.Context, tokenData models.TokenData, id, limit, offset int) ([]models.WebhookDelivery, error) {
+	webhook, err := s.getOwnWebhook(ctx, tokenData, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.WebhookRepo.GetWebhookDeliveries(ctx, webhook.ID, limit, offset)
+}
+
+// getOwnWebhook returns webhook id if tokenData registered it. Webhooks of
+// other users are reported as not found. Webhooks for every user are
+// managed by operators and the system.
+func (s *WebhookService) getOwnWebhook(ctx context.Context, tokenData models.TokenData, id int) (models.Webhook, error) {
+	webhook, err := s.WebhookRepo.GetWebhook(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return webhook, constants.ErrWebhookNotFound
+	}
+	if err != nil {
+		return webhook, errors.Wrap(err, "failed to get webhook")
+	}
+
+	owned := webhook.UserID == int(tokenData.UserID)
+	if webhook.UserID == 0 {
+		owned = tokenData.HasRole(constants.RoleOperator, constants.RoleSystem)
+	}
+	if !owned {
+		return webhook, constants.ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+// DispatchWebhookDeliveries posts due deliveries. Failed deliveries are
+// retried with exponential backoff until WEBHOOK_MAX_ATTEMPTS is reached,
+// then the delivery is marked DEAD.
+func (s *WebhookService) DispatchWebhookDeliveries(ctx context.Context) error {
+	var (
+		log = helpers.Logger
+	)
+
+	maxAttempts, err := strconv.Atoi(helpers.GetEnv("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse webhook max attempts")
+	}
+
+	backoff, err := time.ParseDuration(helpers.GetEnv("WEBHOOK_RETRY_BACKOFF", "30s"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse webhook retry backoff")
+	}
+
+	maxBackoff, err := time.ParseDuration(helpers.GetEnv("WEBHOOK_RETRY_MAX_BACKOFF", "6h"))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse webhook retry max backoff")
+	}
+
+	deliveries, err := s.WebhookRepo.GetDueWebhookDeliveries(ctx, 100)
+	if err != nil {
+		return errors.Wrap(err, "failed to get due webhook deliveries")
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		claimed, err := s.WebhookRepo.ClaimWebhookDelivery(ctx, delivery, time.Minute)
+		if err != nil {
+			log.Error("failed to claim webhook delivery: ", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		attempts := delivery.Attempts + 1
+		status := constants.WebhookDeliveryStatusSent
+		nextAttemptAt := delivery.NextAttemptAt
+		lastError := ""
+
+		responseStatus, err := s.deliverWebhook(ctx, delivery)
+		if errors.Is(err, constants.ErrWebhookNotFound) {
+			lastError = "webhook is no longer active"
+			status = constants.WebhookDeliveryStatusDead
+		} else if errors.Is(err, external.ErrWebhookURLNotAllowed) {
+			lastError = err.Error()
+			status = constants.WebhookDeliveryStatusDead
+			log.Errorf("webhook delivery %d is dead: %v", delivery.ID, err)
+		} else if err != nil {
+			lastError = err.Error()
+			status = constants.WebhookDeliveryStatusPending
+			nextAttemptAt = time.Now().Add(retryBackoff(backoff, maxBackoff, attempts))
+			if attempts >= maxAttempts {
+				status = constants.WebhookDeliveryStatusDead
+				log.Errorf("webhook delivery %d is dead after %d attempts: %v", delivery.ID, attempts, err)
+			} else {
+				log.Warnf("failed to deliver webhook %d, attempt %d: %v", delivery.ID, attempts, err)
+			}
+		}
+
+		err = s.WebhookRepo.UpdateWebhookDelivery(ctx, delivery.ID, status, attempts, nextAttemptAt, responseStatus, lastError)
+		if err != nil {
+			log.Error("failed to update webhook delivery: ", err)
+		}
+	}
+
+	return nil
+}
+
+// deliverWebhook signs the payload with the current time, so a receiver can
+// reject old or replayed requests by checking the timestamp header.
+func (s *WebhookService) deliverWebhook(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	webhook, err := s.WebhookRepo.GetWebhook(ctx, delivery.WebhookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !webhook.Active) {
+		return 0, constants.ErrWebhookNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get webhook")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	return s.External.SendWebhook(ctx, webhook.URL, map[string]string{
+		constants.WebhookHeaderID:        strconv.Itoa(delivery.ID),
+		constants.WebhookHeaderTimestamp: timestamp,
+		constants.WebhookHeaderSignature: "v1=" + signWebhookPayload(webhook.Secret, timestamp, delivery.Payload),
+	}, []byte(delivery.Payload))
+}
+
+// signWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<payload>".
+func signWebhookPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueueWebhookDeliveries writes a delivery for every webhook interested in
+// trx having moved from fromStatus to its current status: the webhooks of its
+// owner, of the recipient of a transfer and the ones for every user. The
+// recipient is not sent the owner's additional_info. historyID is the status
+// history row of the change, a transaction can reach the same status twice.
+// Like sendNotification it must run in the database transaction of the change.
+func enqueueWebhookDeliveries(ctx context.Context, repo interfaces.IWebhookRepo, trx models.Transaction, fromStatus string, historyID int) error {
+	userIDs := []int{trx.UserID}
+	if trx.RecipientUserID != 0 && trx.RecipientUserID != trx.UserID {
+		userIDs = append(userIDs, trx.RecipientUserID)
+	}
+
+	webhooks, err := repo.GetWebhooksForUsers(ctx, userIDs)
+	if err != nil {
+		return errors.Wrap(err, "failed to get webhooks")
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	data := models.WebhookPayloadData{
+		Reference:         trx.Reference,
+		ParentReference:   trx.ParentReference,
+		UserID:            trx.UserID,
+		TransactionType:   trx.TransactionType,
+		PreviousStatus:    fromStatus,
+		TransactionStatus: trx.TransactionStatus,
+		Amount:            trx.Amount,
+		Currency:          trx.Currency,
+		Description:       trx.Description,
+		AdditionalInfo:    trx.AddtionalInfo,
+	}
+	eventID := trx.Reference + ":" + strconv.Itoa(historyID)
+	payload, err := marshalWebhookPayload(eventID, data)
+	if err != nil {
+		return err
+	}
+	data.AdditionalInfo = ""
+	recipientPayload, err := marshalWebhookPayload(eventID, data)
+	if err != nil {
+		return err
+	}
+
+	for i := range webhooks {
+		if !webhooks[i].Matches(trx) {
+			continue
+		}
+
+		deliveryPayload := payload
+		if webhooks[i].UserID != 0 && webhooks[i].UserID != trx.UserID {
+			deliveryPayload = recipientPayload
+		}
+
+		err = repo.CreateWebhookDelivery(ctx, &models.WebhookDelivery{
+			WebhookID:            webhooks[i].ID,
+			TransactionReference: trx.Reference,
+			Event:                constants.WebhookEventTransactionStatusChanged,
+			Payload:              string(deliveryPayload),
+			Status:               constants.WebhookDeliveryStatusPending,
+			NextAttemptAt:        time.Now(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to insert webhook delivery")
+		}
+	}
+
+	return nil
+}
+
+func marshalWebhookPayload(eventID string, data models.WebhookPayloadData) ([]byte, error) {
+	payload, err := json.Marshal(models.WebhookPayload{
+		EventID:   eventID,
+		Event:     constants.WebhookEventTransactionStatusChanged,
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal webhook payload")
+	}
+	return payload, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+	"testing"
+)
+
+// A transfer is delivered to the webhooks of both sides and to the ones for
+// every user, the recipient without the sender's additional_info.
+func TestEnqueueWebhookDeliveriesTransfer(t *testing.T) {
+	outbox := &fakeOutbox{webhooks: []models.Webhook{
+		{ID: 1, UserID: 1},
+		{ID: 2, UserID: 2},
+		{ID: 3, UserID: 3},
+		{ID: 4, UserID: 0},
+	}}
+	trx := models.Transaction{
+		Reference:         "REF0001",
+		UserID:            1,
+		RecipientUserID:   2,
+		TransactionType:   constants.TransactionTypeTransfer,
+		TransactionStatus: constants.TransactionStatusSuccess,
+		AddtionalInfo:     `{"note":"rent"}`,
+	}
+
+	err := enqueueWebhookDeliveries(context.Background(), outbox, trx, constants.TransactionStatusPending, 1)
+	if err != nil {
+		t.Fatalf("enqueueWebhookDeliveries() error = %v", err)
+	}
+
+	additionalInfo := map[int]string{}
+	var webhookIDs []int
+	for _, delivery := range outbox.deliveries {
+		var payload models.WebhookPayload
+		err := json.Unmarshal([]byte(delivery.Payload), &payload)
+		if err != nil {
+			t.Fatalf("failed to unmarshal payload: %v", err)
+		}
+		webhookIDs = append(webhookIDs, delivery.WebhookID)
+		additionalInfo[delivery.WebhookID] = payload.Data.AdditionalInfo
+	}
+	assertEqual(t, "webhooks", webhookIDs, []int{1, 2, 4})
+	if additionalInfo[1] != trx.AddtionalInfo || additionalInfo[4] != trx.AddtionalInfo {
+		t.Errorf("owner additional_info = %q, %q, want %q", additionalInfo[1], additionalInfo[4], trx.AddtionalInfo)
+	}
+	if additionalInfo[2] != "" {
+		t.Errorf("recipient additional_info = %q, want none", additionalInfo[2])
+	}
+}
+
+// A transaction reaching the same status twice is delivered under two event
+// ids, receivers dropping repeated deliveries keep both.
+func TestEnqueueWebhookDeliveriesEventID(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+	f.outbox.webhooks = []models.Webhook{{ID: 1, UserID: 0}}
+	trx := models.Transaction{
+		Reference:         "REF0001",
+		UserID:            1,
+		TransactionType:   constants.TransactionTypePurchase,
+		TransactionStatus: constants.TransactionStatusPending,
+	}
+
+	for _, fromStatus := range []string{"", constants.TransactionStatusUnderReview} {
+		err := f.recordStatusChange(ctx, testOperator, trx, fromStatus, "")
+		if err != nil {
+			t.Fatalf("recordStatusChange() error = %v", err)
+		}
+	}
+
+	var eventIDs []string
+	for _, delivery := range f.outbox.deliveries {
+		var payload models.WebhookPayload
+		err := json.Unmarshal([]byte(delivery.Payload), &payload)
+		if err != nil {
+			t.Fatalf("failed to unmarshal payload: %v", err)
+		}
+		eventIDs = append(eventIDs, payload.EventID)
+	}
+	assertEqual(t, "event ids", eventIDs, []string{"REF0001:1", "REF0001:2"})
+}
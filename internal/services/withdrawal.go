@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet-transaction/constants"
+	"ewallet-transaction/external"
+	"ewallet-transaction/internal/models"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// validateBankAccount checks the destination account of a WITHDRAWAL. Other
+// types cannot have one.
+func validateBankAccount(req *models.Transaction) error {
+	if req.TransactionType != constants.TransactionTypeWithdrawal {
+		if req.BankAccount != nil {
+			return constants.ErrInvalidBankAccount
+		}
+		return nil
+	}
+
+	if req.BankAccount == nil || req.BankAccount.Validate() != nil {
+		return constants.ErrInvalidBankAccount
+	}
+
+	return nil
+}
+
+// createWithBalanceOperation creates req once the wallet calls of its
+// creation transition are made, so a withdrawal only exists when its money
+// and its fee are held. The payload keeps the owner's contact, which is not
+// part of the JSON of a transaction, so recovery can notify them.
+func (s *TransactionService) createWithBalanceOperation(ctx context.Context, tokenData models.TokenData, req *models.Transaction, transition models.StateTransition, insert func(ctx context.Context) error) error {
+	payload, err := json.Marshal(createTransactionPayload{
+		Transaction:  *req,
+		UserEmail:    req.UserEmail,
+		UserFullName: req.UserFullName,
+		CreatedBy:    req.CreatedBy,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal balance operation payload")
+	}
+
+	ops, tokens := statusChangeOperations(tokenData, *req, transition, external.UpdateBalance{
+		Reference: transition.WalletReferencePrefix + req.Reference,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+	})
+	for _, op := range ops {
+		op.Action = constants.BalanceOperationActionCreate
+		op.Payload = string(payload)
+	}
+
+	return s.runBalanceOperations(ctx, tokens, ops, insert)
+}
+
+// createTransactionPayload is the payload of a CREATE balance operation.
+type createTransactionPayload struct {
+	Transaction  models.Transaction `json:"transaction"`
+	UserEmail    string             `json:"user_email"`
+	UserFullName string             `json:"user_full_name"`
+	CreatedBy    string             `json:"created_by"`
+}
+
+// maskAccountNumber hides all but the last four digits of a bank account
+// number.
+func maskAccountNumber(accountNumber string) string {
+	if len(accountNumber) <= 4 {
+		return accountNumber
+	}
+	return strings.Repeat("*", len(accountNumber)-4) + accountNumber[len(accountNumber)-4:]
+}
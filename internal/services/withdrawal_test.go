@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ewallet-transaction/constants"
+	"ewallet-transaction/internal/models"
+)
+
+// The fee of a withdrawal is held with its amount when it is created, and
+// both are returned when it fails.
+func TestWithdrawalFee(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		wantEntries []string
+	}{
+		{
+			name:        "success",
+			status:      constants.TransactionStatusSuccess,
+			wantEntries: []string{"DEBIT REF0001 100.00", "DEBIT FEE-REF0001 25.00"},
+		},
+		{
+			name:   "failed",
+			status: constants.TransactionStatusFailed,
+			wantEntries: []string{
+				"DEBIT REF0001 100.00", "DEBIT FEE-REF0001 25.00",
+				"CREDIT RETURNED-REF0001 100.00", "CREDIT FEE-RETURNED-REF0001 25.00",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeTransactionService()
+			f.FeeRules = []models.FeeRule{{Name: "withdrawal", TransactionType: constants.TransactionTypeWithdrawal, Flat: 2500}}
+
+			resp, err := f.CreateTransaction(ctx, testUser, newTestWithdrawal())
+			if err != nil {
+				t.Fatalf("CreateTransaction() error = %v", err)
+			}
+			if resp.FeeAmount != 2500 {
+				t.Errorf("CreateTransaction() fee = %s, want 25.00", resp.FeeAmount)
+			}
+
+			err = f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+				Reference:         resp.Reference,
+				TransactionStatus: tt.status,
+			})
+			if err != nil {
+				t.Fatalf("UpdateStatusTransaction() error = %v", err)
+			}
+			assertTransactionStatus(t, f, resp.Reference, tt.status)
+			assertEqual(t, "wallet entries", walletEntries(f.wallet), tt.wantEntries)
+		})
+	}
+}
+
+// A withdrawal created before its fee was held gets only its amount back.
+func TestWithdrawalFeeNotCharged(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+
+	trx := newTestWithdrawal()
+	trx.Reference = "LEGACY"
+	trx.TransactionStatus = constants.TransactionStatusPending
+	trx.FeeAmount = 2500
+	_ = f.transactions.CreateTransaction(ctx, trx)
+
+	err := f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+		Reference:         "LEGACY",
+		TransactionStatus: constants.TransactionStatusFailed,
+	})
+	if err != nil {
+		t.Fatalf("UpdateStatusTransaction() error = %v", err)
+	}
+	assertTransactionStatus(t, f, "LEGACY", constants.TransactionStatusFailed)
+	assertEqual(t, "wallet entries", walletEntries(f.wallet), []string{"CREDIT RETURNED-LEGACY 100.00"})
+}
+
+// A status change made while the wallet call of another one was in flight
+// wins, the call is compensated instead of overwriting it.
+func TestUpdateStatusTransactionConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+
+	resp, err := f.CreateTransaction(ctx, testUser, newTestWithdrawal())
+	if err != nil {
+		t.Fatalf("CreateTransaction() error = %v", err)
+	}
+
+	f.wallet.onCall = func(reference string) {
+		f.wallet.onCall = nil
+		_, _ = f.transactions.UpdateStatusTransactionFrom(ctx, resp.Reference, constants.TransactionStatusPending, constants.TransactionStatusSuccess, "", "other")
+	}
+	err = f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+		Reference:         resp.Reference,
+		TransactionStatus: constants.TransactionStatusFailed,
+	})
+	if !errors.Is(err, constants.ErrTransactionStatusChanged) {
+		t.Fatalf("UpdateStatusTransaction() error = %v, want %v", err, constants.ErrTransactionStatusChanged)
+	}
+	assertTransactionStatus(t, f, resp.Reference, constants.TransactionStatusSuccess)
+	assertEqual(t, "wallet entries", walletEntries(f.wallet), []string{"DEBIT REF0001 100.00", "CREDIT RETURNED-REF0001 100.00", "DEBIT COMPENSATE-RETURNED-REF0001 100.00"})
+}
+
+// Recovery only finishes a status change while the transaction is still in
+// the status it was made from.
+func TestRecoverStatusChangeAfterConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+
+	resp, err := f.CreateTransaction(ctx, testUser, newTestWithdrawal())
+	if err != nil {
+		t.Fatalf("CreateTransaction() error = %v", err)
+	}
+
+	f.wallet.faults["RETURNED-REF0001"] = walletTimeoutApplied
+	err = f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+		Reference:         resp.Reference,
+		TransactionStatus: constants.TransactionStatusFailed,
+	})
+	if !errors.Is(err, constants.ErrBalanceOperationPending) {
+		t.Fatalf("UpdateStatusTransaction() error = %v, want %v", err, constants.ErrBalanceOperationPending)
+	}
+	_, _ = f.transactions.UpdateStatusTransactionFrom(ctx, resp.Reference, constants.TransactionStatusPending, constants.TransactionStatusSuccess, "", "other")
+
+	f.balanceOperations.age(time.Hour)
+	err = f.RecoverBalanceOperations(ctx)
+	if err != nil {
+		t.Fatalf("RecoverBalanceOperations() error = %v", err)
+	}
+	assertTransactionStatus(t, f, resp.Reference, constants.TransactionStatusSuccess)
+	assertEqual(t, "wallet entries", walletEntries(f.wallet), []string{"DEBIT REF0001 100.00", "CREDIT RETURNED-REF0001 100.00", "DEBIT COMPENSATE-RETURNED-REF0001 100.00"})
+}
+
+// A status change finished by recovery records the additional_info it added,
+// like one finished by the request.
+func TestRecoverStatusChangeHistory(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeTransactionService()
+
+	resp, err := f.CreateTransaction(ctx, testUser, newTestWithdrawal())
+	if err != nil {
+		t.Fatalf("CreateTransaction() error = %v", err)
+	}
+
+	f.wallet.faults["RETURNED-REF0001"] = walletTimeoutApplied
+	err = f.UpdateStatusTransaction(ctx, testOperator, &models.UpdateStatusTransaction{
+		Reference:         resp.Reference,
+		TransactionStatus: constants.TransactionStatusFailed,
+		AddtionalInfo:     `{"reason":"account closed"}`,
+	})
+	if !errors.Is(err, constants.ErrBalanceOperationPending) {
+		t.Fatalf("UpdateStatusTransaction() error = %v, want %v", err, constants.ErrBalanceOperationPending)
+	}
+
+	f.balanceOperations.age(time.Hour)
+	err = f.RecoverBalanceOperations(ctx)
+	if err != nil {
+		t.Fatalf("RecoverBalanceOperations() error = %v", err)
+	}
+	assertTransactionStatus(t, f, resp.Reference, constants.TransactionStatusFailed)
+
+	history := f.outbox.history[len(f.outbox.history)-1]
+	if history.FromStatus != constants.TransactionStatusPending || history.ToStatus != constants.TransactionStatusFailed {
+		t.Fatalf("last status change is %s to %s, want %s to %s", history.FromStatus, history.ToStatus, constants.TransactionStatusPending, constants.TransactionStatusFailed)
+	}
+	if history.AdditionalInfo != `{"reason":"account closed"}` {
+		t.Errorf("status history additional_info = %s, want the change", history.AdditionalInfo)
+	}
+}
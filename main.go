@@ -3,6 +3,7 @@ package main
 import (
 	"ewallet-transaction/cmd"
 	"ewallet-transaction/helpers"
+	"os"
 )
 
 func main() {
@@ -16,6 +17,15 @@ func main() {
 	// load db
 	helpers.SetupMySQL()
 
+	// run a one-off command instead of the servers
+	if len(os.Args) > 1 {
+		cmd.RunCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// run background workers
+	cmd.ServeWorker()
+
 	// run grpc
 	go cmd.ServeGRPC()
 